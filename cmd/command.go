@@ -6,6 +6,8 @@ import (
 	"github.com/xh3b4sd/tracer"
 
 	"github.com/xh3b4sd/dsm/cmd/completion"
+	"github.com/xh3b4sd/dsm/cmd/delete"
+	"github.com/xh3b4sd/dsm/cmd/patch"
 	"github.com/xh3b4sd/dsm/cmd/search"
 	"github.com/xh3b4sd/dsm/cmd/update"
 	"github.com/xh3b4sd/dsm/cmd/verify"
@@ -42,6 +44,30 @@ func New(config Config) (*cobra.Command, error) {
 		}
 	}
 
+	var deleteCmd *cobra.Command
+	{
+		c := delete.Config{
+			Logger: config.Logger,
+		}
+
+		deleteCmd, err = delete.New(c)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	var patchCmd *cobra.Command
+	{
+		c := patch.Config{
+			Logger: config.Logger,
+		}
+
+		patchCmd, err = patch.New(c)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
 	var searchCmd *cobra.Command
 	{
 		c := search.Config{
@@ -110,6 +136,8 @@ func New(config Config) (*cobra.Command, error) {
 		}
 
 		c.AddCommand(completionCmd)
+		c.AddCommand(deleteCmd)
+		c.AddCommand(patchCmd)
 		c.AddCommand(searchCmd)
 		c.AddCommand(verifyCmd)
 		c.AddCommand(updateCmd)
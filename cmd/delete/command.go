@@ -0,0 +1,66 @@
+package delete
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/xh3b4sd/logger"
+	"github.com/xh3b4sd/tracer"
+)
+
+const (
+	name  = "delete"
+	short = "Delete values within YAML or JSON data structures."
+	long  = `Delete values within YAML or JSON data structures. Consider the following HelmRelease CR
+defining a suspended reconciliation in its spec
+
+    apiVersion: "helm.toolkit.fluxcd.io/v2beta1"
+    kind: "HelmRelease"
+    metadata:
+      name: "apiserver"
+    spec:
+      suspend: true
+      values:
+        image:
+          tag: "8469445410f8a74d72af0cf430ed8dd44fb6b8fa"
+
+The following example shows how to remove the suspend field from the YAML file.
+
+    dsm delete -r HelmRelease -n apiserver -k spec.suspend
+
+Files without kind and metadata name, like dotenv files and Java properties
+files, can be selected using a glob pattern instead, the same way dsm update
+selects them.
+
+    dsm delete -g .env -k DEBUG
+`
+)
+
+type Config struct {
+	Logger logger.Interface
+}
+
+func New(config Config) (*cobra.Command, error) {
+	if config.Logger == nil {
+		return nil, tracer.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	var c *cobra.Command
+	{
+		f := &flag{}
+
+		r := &runner{
+			flag:   f,
+			logger: config.Logger,
+		}
+
+		c = &cobra.Command{
+			Use:   name,
+			Short: short,
+			Long:  long,
+			RunE:  r.Run,
+		}
+
+		f.Init(c)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,128 @@
+package delete
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/xh3b4sd/dsm/pkg/path"
+)
+
+func Test_Delete_Runner(t *testing.T) {
+	testCases := []struct {
+		Files        []string
+		Key          string
+		Expected     []string
+		ErrorMatcher func(error) bool
+	}{
+		// Test case 1, ensure values are deleted from all files.
+		{
+			Files: []string{
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  suspend: true
+`,
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  suspend: false
+`,
+			},
+			Key: "spec.suspend",
+			Expected: []string{
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec: {}
+`,
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec: {}
+`,
+			},
+		},
+
+		// Test case 2, ensure no file is written if deleting fails for any of
+		// them.
+		{
+			Files: []string{
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  suspend: true
+`,
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec: {}
+`,
+			},
+			Key: "spec.suspend",
+			Expected: []string{
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  suspend: true
+`,
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec: {}
+`,
+			},
+			ErrorMatcher: path.IsNotFound,
+		},
+	}
+
+	for i, tc := range testCases {
+		d, err := ioutil.TempDir("", "dsm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(d)
+
+		for j, f := range tc.Files {
+			err = ioutil.WriteFile(filepath.Join(d, strconv.Itoa(j)+".yaml"), []byte(f), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		r := &runner{
+			flag: &flag{
+				Key:      tc.Key,
+				Name:     "apiserver",
+				Resource: "Deployment",
+				Source:   d,
+			},
+		}
+
+		err = r.run(context.Background(), nil, nil)
+		if tc.ErrorMatcher != nil {
+			if !tc.ErrorMatcher(err) {
+				t.Fatal("test", i+1, "expected", true, "got", err)
+			}
+		} else if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		for j, e := range tc.Expected {
+			b, err := ioutil.ReadFile(filepath.Join(d, strconv.Itoa(j)+".yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != e {
+				t.Fatal("test", i+1, "expected", e, "got", string(b))
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package patch
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/xh3b4sd/logger"
+	"github.com/xh3b4sd/tracer"
+)
+
+const (
+	name  = "patch"
+	short = "Apply JSON Patches to YAML or JSON data structures."
+	long  = `Apply JSON Patches to YAML or JSON data structures. Consider the following HelmRelease CR
+defining a Docker image tag in its spec
+
+    apiVersion: "helm.toolkit.fluxcd.io/v2beta1"
+    kind: "HelmRelease"
+    metadata:
+      name: "apiserver"
+    spec:
+      values:
+        image:
+          tag: "8469445410f8a74d72af0cf430ed8dd44fb6b8fa"
+
+Patches are JSON documents as specified in RFC 6902, addressing values using
+JSON Pointers as specified in RFC 6901. The supported operations are add,
+remove, replace, move, copy and test.
+
+    [
+      {"op": "test", "path": "/spec/values/image/tag", "value": "8469445410f8a74d72af0cf430ed8dd44fb6b8fa"},
+      {"op": "replace", "path": "/spec/values/image/tag", "value": "<new-sha>"}
+    ]
+
+The following example shows how to apply the patch above to the YAML file. The
+patch is applied to every document selected, either completely or not at all.
+Nothing is written if the patch fails for any document. Patches are read from
+stdin if the given file is "-".
+
+    dsm patch -r HelmRelease -n apiserver -f patch.json
+
+Files without kind and metadata name, like XML and HCL files, can be selected
+using a glob pattern instead, the same way dsm update selects them.
+
+    dsm patch -g pom.xml -f patch.json
+`
+)
+
+type Config struct {
+	Logger logger.Interface
+}
+
+func New(config Config) (*cobra.Command, error) {
+	if config.Logger == nil {
+		return nil, tracer.Maskf(invalidConfigError, "%T.Logger must not be empty", config)
+	}
+
+	var c *cobra.Command
+	{
+		f := &flag{}
+
+		r := &runner{
+			flag:   f,
+			logger: config.Logger,
+		}
+
+		c = &cobra.Command{
+			Use:   name,
+			Short: short,
+			Long:  long,
+			RunE:  r.Run,
+		}
+
+		f.Init(c)
+	}
+
+	return c, nil
+}
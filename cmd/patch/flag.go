@@ -0,0 +1,85 @@
+package patch
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xh3b4sd/tracer"
+
+	"github.com/xh3b4sd/dsm/pkg/path"
+)
+
+type flag struct {
+	Base64   bool
+	File     string
+	Format   string
+	Glob     string
+	Name     string
+	Resource string
+	SopsKeys []string
+	Source   string
+	Template bool
+}
+
+func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&f.Base64, "base64", "", false, "Decode base64 encoded documents in strings, like the data of Secrets.")
+	cmd.Flags().StringVarP(&f.File, "file", "f", "", "JSON Patch file to apply, or - for stdin.")
+	cmd.Flags().StringVarP(&f.Format, "format", "", "", "Format of the files to work with, e.g. json5 for tsconfig.json, instead of detecting it.")
+	cmd.Flags().StringVarP(&f.Glob, "glob", "g", "", "Glob pattern of the files to work with, e.g. '*.env', instead of or in addition to -r and -n.")
+	cmd.Flags().StringVarP(&f.Name, "name", "n", "", "Metadata name of the resources to work with.")
+	cmd.Flags().StringVarP(&f.Resource, "resource", "r", "", "Resource kind to work with.")
+	cmd.Flags().StringSliceVarP(&f.SopsKeys, "sops-key", "", nil, "File holding an age identity or PGP private key to decrypt SOPS documents with.")
+	cmd.Flags().StringVarP(&f.Source, "source", "s", ".", "Source directory to traverse.")
+	cmd.Flags().BoolVarP(&f.Template, "template", "", false, "Treat Go template actions like {{ .Values.x }} as opaque tokens, e.g. in Helm charts.")
+}
+
+func (f *flag) Validate() error {
+	{
+		if f.Format != "" {
+			var ok bool
+			for _, v := range path.Formats {
+				if f.Format == v {
+					ok = true
+				}
+			}
+
+			if !ok {
+				return tracer.Maskf(invalidFlagError, "--format must be one of %s", strings.Join(path.Formats, ", "))
+			}
+		}
+	}
+
+	{
+		if f.File == "" {
+			return tracer.Maskf(invalidFlagError, "-f/--file must not be empty")
+		}
+	}
+
+	{
+		if f.Glob == "" && f.Name == "" {
+			return tracer.Maskf(invalidFlagError, "-n/--name must not be empty without -g/--glob")
+		}
+	}
+
+	{
+		if f.Glob == "" && f.Resource == "" {
+			return tracer.Maskf(invalidFlagError, "-r/--resource must not be empty without -g/--glob")
+		}
+	}
+
+	{
+		for _, k := range f.SopsKeys {
+			if k == "" {
+				return tracer.Maskf(invalidFlagError, "--sops-key must not be empty")
+			}
+		}
+	}
+
+	{
+		if f.Source == "" {
+			return tracer.Maskf(invalidFlagError, "-s/--source must not be empty")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package patch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/xh3b4sd/logger"
+	"github.com/xh3b4sd/tracer"
+
+	"github.com/xh3b4sd/dsm/pkg/path"
+	"github.com/xh3b4sd/dsm/pkg/searcher"
+	"github.com/xh3b4sd/dsm/pkg/sops"
+	"github.com/xh3b4sd/dsm/pkg/stream"
+)
+
+type runner struct {
+	flag   *flag
+	logger logger.Interface
+}
+
+func (r *runner) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	err := r.flag.Validate()
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	err = r.run(ctx, cmd, args)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
+	var err error
+
+	var patch []byte
+	{
+		if r.flag.File == "-" {
+			patch, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			patch, err = ioutil.ReadFile(r.flag.File)
+		}
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	var k *sops.Sops
+	{
+		c := sops.Config{
+			KeyFiles: r.flag.SopsKeys,
+		}
+
+		k, err = sops.New(c)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	var s *searcher.Searcher
+	{
+		c := searcher.Config{
+			FileSystem: afero.NewOsFs(),
+
+			Format:   r.flag.Format,
+			Glob:     r.flag.Glob,
+			Name:     r.flag.Name,
+			Resource: r.flag.Resource,
+			Source:   r.flag.Source,
+			Template: r.flag.Template,
+		}
+
+		s, err = searcher.New(c)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	var files []string
+	var documents map[string][]searcher.Document
+	{
+		l, err := s.Search()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		documents = map[string][]searcher.Document{}
+		for _, d := range l {
+			_, ok := documents[d.File]
+			if !ok {
+				files = append(files, d.File)
+			}
+
+			documents[d.File] = append(documents[d.File], d)
+		}
+	}
+
+	// All documents are patched before any file is written, so that a patch
+	// failing for one document does not leave the others modified.
+	output := map[string][]byte{}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		l, err := s.Split(f, b)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		for _, d := range documents[f] {
+			var newPath *path.Path
+			{
+				c := path.Config{
+					Base64:   r.flag.Base64,
+					Bytes:    d.Bytes,
+					Format:   d.Format,
+					Sops:     k,
+					Template: r.flag.Template,
+				}
+
+				newPath, err = path.New(c)
+				if err != nil {
+					return tracer.Mask(err)
+				}
+			}
+
+			err := newPath.ApplyPatch(patch)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+
+			v, err := newPath.OutputBytes()
+			if err != nil {
+				return tracer.Mask(err)
+			}
+
+			l[d.Index] = v
+		}
+
+		output[f] = stream.Join(l)
+	}
+
+	for _, f := range files {
+		err = ioutil.WriteFile(f, output[f], 0600)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	return nil
+}
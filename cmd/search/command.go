@@ -25,6 +25,76 @@ The following example shows how to extract the image tag from the YAML file.
 
     $ dsm search -r HelmRelease -n apiserver -k spec.values.image.tag
     8469445410f8a74d72af0cf430ed8dd44fb6b8fa
+
+Keys may contain the wildcard "*", matching any key or list index on a single
+level, and the recursive wildcard "**", matching any amount of levels. The
+concrete path of every match is printed alongside its value.
+
+    $ dsm search -r HelmRelease -n apiserver -k '**.tag'
+    spec.values.image.tag 8469445410f8a74d72af0cf430ed8dd44fb6b8fa
+
+Keys may also be given as JSONPath queries as specified in RFC 9535, including
+filters, slices, unions and recursive descent. The paths of matches are printed
+for queries which may match more than one value.
+
+    $ dsm search -r HelmRelease -n apiserver -q jsonpath -k '$.spec.values..tag'
+    $['spec']['values']['image']['tag'] 8469445410f8a74d72af0cf430ed8dd44fb6b8fa
+
+Base64 encoded strings, like the data of Secrets, can be decoded using the
+[base64] segment. Documents encoded this way are entered by all keys if --base64
+is given, unless the string is marked using the [!base64] segment.
+
+    $ dsm search -r Secret -n apiserver -k 'data.password[base64]'
+    $ dsm search -r Secret -n apiserver -k 'data.config\.json.endpoint' --base64
+
+Helm chart templates and other files containing Go template actions can be
+searched using --template, which treats actions like {{ .Values.x }} as opaque
+tokens.
+
+    $ dsm search -r Deployment -n apiserver -k spec.replicas --template
+    {{ .Values.replicas }}
+
+TOML files are searched as well. Their resources are selected using the top
+level kind key and the name key of the metadata table.
+
+    $ dsm search -r Config -n app -k app.version
+    1.0.0
+
+Files without kind and metadata name, like dotenv files and Java properties
+files, can be selected using a glob pattern instead. Keys containing dots have
+to be escaped.
+
+    $ dsm search -g '*.properties' -k 'spring\.datasource\.url'
+    jdbc:h2:mem:db
+
+XML files like Maven POMs are selected the same way. Attributes are addressed
+using keys like @Version.
+
+    $ dsm search -g pom.xml -k project.version
+    1.0.0
+
+HCL files like Terraform variable files are selected the same way. Blocks are
+addressed using their type and labels.
+
+    $ dsm search -g prod.tfvars -k image_tag
+    v1.2.0
+
+JSON files with comments and trailing commas, like tsconfig.json, are read
+using --format json5. Files ending in .jsonc and .json5 are read this way
+without it.
+
+    $ dsm search -g tsconfig.json --format json5 -k compilerOptions.target
+    ES2020
+
+YAML and JSON files encrypted with SOPS are decrypted, so that their plaintext
+values are searched. Data keys are decrypted using the age identities SOPS
+finds in SOPS_AGE_KEY, SOPS_AGE_KEY_FILE and its age keys file, the age
+identities and PGP private keys given using --sops-key, or gpg. Kind and
+metadata name have to be unencrypted for -r and -n to match them, as with
+sops --encrypted-regex '^(data|stringData)$'.
+
+    $ dsm search -r Secret -n apiserver -k stringData.password
+    hunter2
 `
 )
 
@@ -1,25 +1,56 @@
 package search
 
 import (
+	"strings"
+
 	"github.com/spf13/cobra"
 	"github.com/xh3b4sd/tracer"
+
+	"github.com/xh3b4sd/dsm/pkg/path"
 )
 
 type flag struct {
-	Key      string
-	Name     string
-	Resource string
-	Source   string
+	Base64        bool
+	Format        string
+	Glob          string
+	Key           string
+	Name          string
+	QueryLanguage string
+	Resource      string
+	SopsKeys      []string
+	Source        string
+	Template      bool
 }
 
 func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&f.Base64, "base64", "", false, "Decode base64 encoded documents in strings, like the data of Secrets.")
+	cmd.Flags().StringVarP(&f.Format, "format", "", "", "Format of the files to work with, e.g. json5 for tsconfig.json, instead of detecting it.")
+	cmd.Flags().StringVarP(&f.Glob, "glob", "g", "", "Glob pattern of the files to work with, e.g. '*.env', instead of or in addition to -r and -n.")
 	cmd.Flags().StringVarP(&f.Key, "key", "k", "", "JSON path key to work with.")
 	cmd.Flags().StringVarP(&f.Name, "name", "n", "", "Metadata name of the resources to work with.")
+	cmd.Flags().StringVarP(&f.QueryLanguage, "query-language", "q", path.QueryLanguageDotted, "Query language of the key, either dotted or jsonpath.")
 	cmd.Flags().StringVarP(&f.Resource, "resource", "r", "", "Resource kind to work with.")
+	cmd.Flags().StringSliceVarP(&f.SopsKeys, "sops-key", "", nil, "File holding an age identity or PGP private key to decrypt SOPS documents with.")
 	cmd.Flags().StringVarP(&f.Source, "source", "s", ".", "Source directory to traverse.")
+	cmd.Flags().BoolVarP(&f.Template, "template", "", false, "Treat Go template actions like {{ .Values.x }} as opaque tokens, e.g. in Helm charts.")
 }
 
 func (f *flag) Validate() error {
+	{
+		if f.Format != "" {
+			var ok bool
+			for _, v := range path.Formats {
+				if f.Format == v {
+					ok = true
+				}
+			}
+
+			if !ok {
+				return tracer.Maskf(invalidFlagError, "--format must be one of %s", strings.Join(path.Formats, ", "))
+			}
+		}
+	}
+
 	{
 		if f.Key == "" {
 			return tracer.Maskf(invalidFlagError, "-k/--key must not be empty")
@@ -27,14 +58,28 @@ func (f *flag) Validate() error {
 	}
 
 	{
-		if f.Name == "" {
-			return tracer.Maskf(invalidFlagError, "-n/--name must not be empty")
+		if f.Glob == "" && f.Name == "" {
+			return tracer.Maskf(invalidFlagError, "-n/--name must not be empty without -g/--glob")
+		}
+	}
+
+	{
+		if f.QueryLanguage != path.QueryLanguageDotted && f.QueryLanguage != path.QueryLanguageJSONPath {
+			return tracer.Maskf(invalidFlagError, "-q/--query-language must be one of %s or %s", path.QueryLanguageDotted, path.QueryLanguageJSONPath)
+		}
+	}
+
+	{
+		if f.Glob == "" && f.Resource == "" {
+			return tracer.Maskf(invalidFlagError, "-r/--resource must not be empty without -g/--glob")
 		}
 	}
 
 	{
-		if f.Resource == "" {
-			return tracer.Maskf(invalidFlagError, "-r/--resource must not be empty")
+		for _, k := range f.SopsKeys {
+			if k == "" {
+				return tracer.Maskf(invalidFlagError, "--sops-key must not be empty")
+			}
 		}
 	}
 
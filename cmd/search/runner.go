@@ -3,7 +3,6 @@ package search
 import (
 	"context"
 	"fmt"
-	"sort"
 
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
@@ -12,6 +11,7 @@ import (
 
 	"github.com/xh3b4sd/dsm/pkg/path"
 	"github.com/xh3b4sd/dsm/pkg/searcher"
+	"github.com/xh3b4sd/dsm/pkg/sops"
 )
 
 type runner struct {
@@ -38,14 +38,29 @@ func (r *runner) Run(cmd *cobra.Command, args []string) error {
 func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
 	var err error
 
+	var k *sops.Sops
+	{
+		c := sops.Config{
+			KeyFiles: r.flag.SopsKeys,
+		}
+
+		k, err = sops.New(c)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
 	var s *searcher.Searcher
 	{
 		c := searcher.Config{
 			FileSystem: afero.NewOsFs(),
 
+			Format:   r.flag.Format,
+			Glob:     r.flag.Glob,
 			Name:     r.flag.Name,
 			Resource: r.flag.Resource,
 			Source:   r.flag.Source,
+			Template: r.flag.Template,
 		}
 
 		s, err = searcher.New(c)
@@ -54,28 +69,24 @@ func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) err
 		}
 	}
 
-	var f map[string][]byte
-	var l []string
+	var l []searcher.Document
 	{
-		f, err = s.Search()
+		l, err = s.Search()
 		if err != nil {
 			return tracer.Mask(err)
 		}
-
-		for k := range f {
-			l = append(l, k)
-		}
-
-		sort.Strings(l)
 	}
 
-	for _, p := range l {
-		b := f[p]
-
+	for _, d := range l {
 		var newPath *path.Path
 		{
 			c := path.Config{
-				Bytes: b,
+				Base64:        r.flag.Base64,
+				Bytes:         d.Bytes,
+				Format:        d.Format,
+				QueryLanguage: r.flag.QueryLanguage,
+				Sops:          k,
+				Template:      r.flag.Template,
 			}
 
 			newPath, err = path.New(c)
@@ -84,12 +95,26 @@ func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) err
 			}
 		}
 
-		v, err := newPath.Get(r.flag.Key)
+		matches, err := newPath.GetAll(r.flag.Key)
 		if err != nil {
 			return tracer.Mask(err)
 		}
 
-		fmt.Printf("%s\n", v)
+		// Only the values of keys which may match any amount of values are
+		// printed alongside their paths, so that the single values of other
+		// keys can be used as they are, e.g. in $(dsm search ...).
+		pattern, err := newPath.IsPattern(r.flag.Key)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		for _, m := range matches {
+			if pattern {
+				fmt.Printf("%s %s\n", m.Path, m.Value)
+			} else {
+				fmt.Printf("%s\n", m.Value)
+			}
+		}
 	}
 
 	return nil
@@ -24,6 +24,119 @@ defining a Docker image tag in its spec
 The following example shows how to modify the image tag of the YAML file.
 
     dsm update -r HelmRelease -n apiserver -k spec.values.image.tag -v <new-sha>
+
+Values keep the type of the values they replace, so that numbers, booleans and
+nulls stay typed. Values can also be typed explicitly using -t/--type, where
+json and yaml allow to write whole objects and lists.
+
+    dsm update -r Deployment -n apiserver -k spec.replicas -v 3
+    dsm update -r Deployment -n apiserver -k spec.template.metadata.labels -t json -v '{"app": "apiserver"}'
+
+Whole objects and lists can also be read from YAML or JSON files, or from stdin
+using --value-file -, and given inline using --value-json.
+
+    dsm update -r Deployment -n apiserver -k spec.template.spec.tolerations --value-file tolerations.yaml
+    kubectl get deploy apiserver -o json | jq .spec.template.spec.affinity | dsm update -r Deployment -n apiserver -k spec.template.spec.affinity --value-file -
+
+Values given using -v/--value may refer to environment variables like
+${GIT_SHA}, and to the values of other paths like {{ path "spec.x" }}, which are
+looked up in the updated document first and in all other matched documents
+otherwise. $${GIT_SHA} is written as ${GIT_SHA}. Values of the types json and
+yaml are parsed before their strings are expanded, so that references cannot
+add keys or items. Whole values are copied using --value-from-path, keeping
+their type. References which cannot be resolved fail the command without
+updating any file.
+
+    dsm update -r HelmRelease -n apiserver -k spec.values.image.tag -v '${GIT_SHA}'
+    dsm update -r HelmRelease -n apiserver -k metadata.annotations.version -v 'v{{ path "spec.values.image.tag" }}'
+    dsm update -r HelmRelease -n apiserver -k metadata.annotations.version --value-from-path spec.values.image.tag
+
+YAML anchors, aliases and merge keys are kept. Setting a value reached through
+an alias or a merge key modifies the anchored value shared by all of its
+aliases, unless --split-aliases is given, which writes a copy of the anchored
+value in place of the alias instead.
+
+    dsm update -r HelmRelease -n apiserver -k spec.values.apiserver.resources.limits.cpu -v 2 --split-aliases
+
+List items can be selected by the value of one of their fields instead of their
+index. Missing list items are created.
+
+    dsm update -r Deployment -n apiserver -k 'spec.template.spec.containers[name=app].image' -v <new-image>
+
+New list items can be appended using [+] or [append], and inserted at a given
+index using e.g. [+0]. The last list item can be addressed using [-1].
+
+    dsm update -r Deployment -n apiserver -k 'spec.template.spec.imagePullSecrets[+].name' -v <secret>
+
+Keys may also be given as JSONPath queries as specified in RFC 9535. Queries
+addressing a single location create missing keys, all other queries change
+existing values only.
+
+    dsm update -r Deployment -n apiserver -q jsonpath -k "$.spec.template.spec.containers[?@.name=='app'].image" -v <new-image>
+
+Whole blocks can be deep merged using YAML or JSON fragments read from a file
+or from stdin. Null values delete keys as specified in RFC 7386. Lists are
+replaced, unless they are appended or merged by the value of a key like name.
+
+    echo '{"limits": {"memory": "256Mi"}}' | dsm update -r Deployment -n apiserver -k 'spec.template.spec.containers[name=app].resources' -m -
+    dsm update -r Deployment -n apiserver -k spec.template.spec.containers -m containers.yaml --list-strategy merge
+
+Base64 encoded strings, like the data of Secrets, are encoded again after being
+modified. The [base64] segment sets the string to the base64 encoding of the
+value. Documents encoded this way are entered by all keys if --base64 is given.
+
+    dsm update -r Secret -n apiserver -k 'data.password[base64]' -v <new-password>
+    dsm update -r Secret -n apiserver -k 'data.config\.json.endpoint' -v <new-endpoint> --base64
+
+Helm chart templates and other files containing Go template actions can be
+updated using --template. The static parts of such files are modified, while
+all actions like {{ .Values.x }} are written back unchanged.
+
+    dsm update -r Deployment -n apiserver -k spec.template.spec.containers.[0].ports.[0].containerPort -v 9090 --template
+
+TOML files are updated as well, keeping their tables, comments and the quotes of
+modified strings. Their resources are selected using the top level kind key and
+the name key of the metadata table.
+
+    dsm update -r Config -n app -k app.version -v 1.1.0
+
+Files without kind and metadata name, like dotenv files and Java properties
+files, can be selected using a glob pattern instead, which is matched against
+the path of the files relative to the source directory and their file names.
+Keys containing dots have to be escaped.
+
+    dsm update -g .env -k IMAGE_TAG -v <new-tag>
+    dsm update -g 'src/main/resources/*.properties' -k 'spring\.datasource\.url' -v <new-url>
+
+XML files like Maven POMs and .NET project files are selected the same way,
+keeping their namespaces, comments and whitespace. Attributes are addressed
+using keys like @Version. Child elements occurring more than once are lists.
+
+    dsm update -g pom.xml -k 'project.dependencies.dependency[artifactId=foo].version' -v <new-version>
+    dsm update -g '*.csproj' -k 'Project.ItemGroup.PackageReference[@Include=Serilog].@Version' -v <new-version>
+
+HCL files like Terraform variable files are selected the same way, keeping their
+comments and the formatting of all unmodified attributes. Blocks are addressed
+using their type and labels.
+
+    dsm update -g '*.tfvars' -k image_tag -v <new-tag>
+    dsm update -g terragrunt.hcl -k inputs.cidr -v 10.0.0.0/16
+
+JSON files with comments and trailing commas, like tsconfig.json and VS Code
+settings, are updated using --format json5, keeping their comments. Files
+ending in .jsonc and .json5, like renovate.json5, are updated this way without
+it.
+
+    dsm update -g tsconfig.json --format json5 -k compilerOptions.target -v ES2022
+    dsm update -g renovate.json5 -k 'packageRules.[0].enabled' -v true
+
+YAML and JSON files encrypted with SOPS are decrypted the same way dsm search
+decrypts them, and encrypted again using their data key. Only modified values
+are encrypted anew, and the MAC is recomputed, so that the files can still be
+decrypted by sops. Keys are only ever held in memory.
+
+    dsm update -r Secret -n apiserver -k stringData.password -v <new-password>
+    dsm update -g secrets.enc.yaml -k database.password -v <new-password> --sops-key ~/keys/age.txt
 `
 )
 
@@ -21,3 +21,11 @@ var invalidFlagError = &tracer.Error{
 func IsInvalidFlag(err error) bool {
 	return errors.Is(err, invalidFlagError)
 }
+
+var unresolvedReferenceError = &tracer.Error{
+	Kind: "unresolvedReferenceError",
+}
+
+func IsUnresolvedReference(err error) bool {
+	return errors.Is(err, unresolvedReferenceError)
+}
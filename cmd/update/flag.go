@@ -1,42 +1,128 @@
 package update
 
 import (
+	"strings"
+
 	"github.com/spf13/cobra"
 	"github.com/xh3b4sd/tracer"
+
+	"github.com/xh3b4sd/dsm/pkg/path"
 )
 
 type flag struct {
-	Key      string
-	Name     string
-	Resource string
-	Source   string
-	Value    string
+	Base64        bool
+	Format        string
+	Glob          string
+	Key           string
+	ListStrategy  string
+	Merge         string
+	MergeKey      string
+	Name          string
+	QueryLanguage string
+	Resource      string
+	SopsKeys      []string
+	Source        string
+	SplitAliases  bool
+	Template      bool
+	Type          string
+	Value         string
+	ValueFile     string
+	ValueFromPath string
+	ValueJSON     string
 }
 
 func (f *flag) Init(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&f.Base64, "base64", "", false, "Decode base64 encoded documents in strings, like the data of Secrets.")
+	cmd.Flags().StringVarP(&f.Format, "format", "", "", "Format of the files to work with, e.g. json5 for tsconfig.json, instead of detecting it.")
+	cmd.Flags().StringVarP(&f.Glob, "glob", "g", "", "Glob pattern of the files to work with, e.g. '*.env', instead of or in addition to -r and -n.")
 	cmd.Flags().StringVarP(&f.Key, "key", "k", "", "JSON path key to work with.")
+	cmd.Flags().StringVarP(&f.ListStrategy, "list-strategy", "", path.ListStrategyReplace, "Strategy for merging lists, either replace, append or merge.")
+	cmd.Flags().StringVarP(&f.Merge, "merge", "m", "", "YAML or JSON fragment file to deep merge under the key, - for stdin.")
+	cmd.Flags().StringVarP(&f.MergeKey, "merge-key", "", "name", "Key identifying list items for the merge list strategy.")
 	cmd.Flags().StringVarP(&f.Name, "name", "n", "", "Metadata name of the resources to work with.")
+	cmd.Flags().StringVarP(&f.QueryLanguage, "query-language", "q", path.QueryLanguageDotted, "Query language of the key, either dotted or jsonpath.")
 	cmd.Flags().StringVarP(&f.Resource, "resource", "r", "", "Resource kind to work with.")
+	cmd.Flags().StringSliceVarP(&f.SopsKeys, "sops-key", "", nil, "File holding an age identity or PGP private key to decrypt SOPS documents with.")
 	cmd.Flags().StringVarP(&f.Source, "source", "s", ".", "Source directory to traverse.")
-	cmd.Flags().StringVarP(&f.Value, "value", "v", "", "JSON path value to work with.")
+	cmd.Flags().BoolVarP(&f.SplitAliases, "split-aliases", "", false, "Write copies of aliased YAML nodes instead of modifying their anchors.")
+	cmd.Flags().BoolVarP(&f.Template, "template", "", false, "Treat Go template actions like {{ .Values.x }} as opaque tokens, e.g. in Helm charts.")
+	cmd.Flags().StringVarP(&f.Type, "type", "t", path.TypeAuto, "Type of the value, one of auto, string, int, float, bool, null, json or yaml.")
+	cmd.Flags().StringVarP(&f.Value, "value", "v", "", "JSON path value to work with, expanding references like ${GIT_SHA} and {{ path \"spec.x\" }}.")
+	cmd.Flags().StringVarP(&f.ValueFile, "value-file", "", "", "YAML or JSON file containing the value to work with, - for stdin.")
+	cmd.Flags().StringVarP(&f.ValueFromPath, "value-from-path", "", "", "Path of the value to copy from the same or another matched document.")
+	cmd.Flags().StringVarP(&f.ValueJSON, "value-json", "", "", "JSON object, list or scalar to work with.")
 }
 
 func (f *flag) Validate() error {
 	{
-		if f.Key == "" {
+		if f.Format != "" {
+			var ok bool
+			for _, v := range path.Formats {
+				if f.Format == v {
+					ok = true
+				}
+			}
+
+			if !ok {
+				return tracer.Maskf(invalidFlagError, "--format must be one of %s", strings.Join(path.Formats, ", "))
+			}
+		}
+	}
+
+	{
+		if f.Key == "" && f.Merge == "" {
 			return tracer.Maskf(invalidFlagError, "-k/--key must not be empty")
 		}
 	}
 
 	{
-		if f.Name == "" {
-			return tracer.Maskf(invalidFlagError, "-n/--name must not be empty")
+		if f.ListStrategy != path.ListStrategyAppend && f.ListStrategy != path.ListStrategyMerge && f.ListStrategy != path.ListStrategyReplace {
+			return tracer.Maskf(invalidFlagError, "--list-strategy must be one of %s, %s or %s", path.ListStrategyReplace, path.ListStrategyAppend, path.ListStrategyMerge)
+		}
+	}
+
+	{
+		var n int
+		for _, v := range []string{f.Merge, f.Value, f.ValueFile, f.ValueFromPath, f.ValueJSON} {
+			if v != "" {
+				n++
+			}
+		}
+
+		if n > 1 {
+			return tracer.Maskf(invalidFlagError, "only one of -m/--merge, -v/--value, --value-file, --value-from-path and --value-json must be given")
+		}
+	}
+
+	{
+		if f.MergeKey == "" {
+			return tracer.Maskf(invalidFlagError, "--merge-key must not be empty")
 		}
 	}
 
 	{
-		if f.Resource == "" {
-			return tracer.Maskf(invalidFlagError, "-r/--resource must not be empty")
+		if f.Glob == "" && f.Name == "" {
+			return tracer.Maskf(invalidFlagError, "-n/--name must not be empty without -g/--glob")
+		}
+	}
+
+	{
+		if f.QueryLanguage != path.QueryLanguageDotted && f.QueryLanguage != path.QueryLanguageJSONPath {
+			return tracer.Maskf(invalidFlagError, "-q/--query-language must be one of %s or %s", path.QueryLanguageDotted, path.QueryLanguageJSONPath)
+		}
+	}
+
+	{
+		if f.Glob == "" && f.Resource == "" {
+			return tracer.Maskf(invalidFlagError, "-r/--resource must not be empty without -g/--glob")
+		}
+	}
+
+	{
+		for _, k := range f.SopsKeys {
+			if k == "" {
+				return tracer.Maskf(invalidFlagError, "--sops-key must not be empty")
+			}
 		}
 	}
 
@@ -47,8 +133,27 @@ func (f *flag) Validate() error {
 	}
 
 	{
-		if f.Value == "" {
-			return tracer.Maskf(invalidFlagError, "-v/--value must not be empty")
+		var ok bool
+		for _, t := range path.Types {
+			if f.Type == t {
+				ok = true
+			}
+		}
+
+		if !ok {
+			return tracer.Maskf(invalidFlagError, "-t/--type must be one of %s", strings.Join(path.Types, ", "))
+		}
+	}
+
+	{
+		if f.ValueFromPath != "" && f.Type != path.TypeAuto {
+			return tracer.Maskf(invalidFlagError, "-t/--type must be %s with --value-from-path", path.TypeAuto)
+		}
+	}
+
+	{
+		if f.Value == "" && f.ValueFile == "" && f.ValueFromPath == "" && f.ValueJSON == "" && f.Merge == "" && f.Type != path.TypeNull {
+			return tracer.Maskf(invalidFlagError, "one of -m/--merge, -v/--value, --value-file, --value-from-path or --value-json must not be empty")
 		}
 	}
 
@@ -2,16 +2,20 @@ package update
 
 import (
 	"context"
+	"encoding/json"
 	"io/ioutil"
-	"sort"
+	"os"
 
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/xh3b4sd/logger"
 	"github.com/xh3b4sd/tracer"
 
+	"github.com/xh3b4sd/dsm/pkg/interpolate"
 	"github.com/xh3b4sd/dsm/pkg/path"
 	"github.com/xh3b4sd/dsm/pkg/searcher"
+	"github.com/xh3b4sd/dsm/pkg/sops"
+	"github.com/xh3b4sd/dsm/pkg/stream"
 )
 
 type runner struct {
@@ -22,7 +26,12 @@ type runner struct {
 func (r *runner) Run(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
-	err := r.run(ctx, cmd, args)
+	err := r.flag.Validate()
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	err = r.run(ctx, cmd, args)
 	if err != nil {
 		return tracer.Mask(err)
 	}
@@ -33,14 +42,64 @@ func (r *runner) Run(cmd *cobra.Command, args []string) error {
 func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
 	var err error
 
+	var fragment []byte
+	if r.flag.Merge != "" {
+		if r.flag.Merge == "-" {
+			fragment, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			fragment, err = ioutil.ReadFile(r.flag.Merge)
+		}
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	value, t := r.flag.Value, r.flag.Type
+	if r.flag.ValueJSON != "" {
+		value, t = r.flag.ValueJSON, path.TypeJSON
+	}
+	if r.flag.ValueFile != "" {
+		var b []byte
+		if r.flag.ValueFile == "-" {
+			b, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			b, err = ioutil.ReadFile(r.flag.ValueFile)
+		}
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		// Value files are parsed as YAML, which covers JSON as well, unless
+		// their type is given explicitly.
+		value = string(b)
+		if t == path.TypeAuto {
+			t = path.TypeYAML
+		}
+	}
+
+	var k *sops.Sops
+	{
+		c := sops.Config{
+			KeyFiles: r.flag.SopsKeys,
+		}
+
+		k, err = sops.New(c)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
 	var s *searcher.Searcher
 	{
 		c := searcher.Config{
 			FileSystem: afero.NewOsFs(),
 
+			Format:   r.flag.Format,
+			Glob:     r.flag.Glob,
 			Name:     r.flag.Name,
 			Resource: r.flag.Resource,
 			Source:   r.flag.Source,
+			Template: r.flag.Template,
 		}
 
 		s, err = searcher.New(c)
@@ -49,47 +108,103 @@ func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) err
 		}
 	}
 
-	var f map[string][]byte
-	var l []string
+	var files []string
+	var matched []searcher.Document
+	var documents map[string][]searcher.Document
 	{
-		f, err = s.Search()
+		matched, err = s.Search()
 		if err != nil {
 			return tracer.Mask(err)
 		}
 
-		for k := range f {
-			l = append(l, k)
-		}
+		documents = map[string][]searcher.Document{}
+		for _, d := range matched {
+			_, ok := documents[d.File]
+			if !ok {
+				files = append(files, d.File)
+			}
 
-		sort.Strings(l)
+			documents[d.File] = append(documents[d.File], d)
+		}
 	}
 
-	for _, p := range l {
-		b := f[p]
+	// Files are only written once all of their documents could be updated, so
+	// that e.g. unresolved references do not leave any file half updated.
+	var outputs [][]byte
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return tracer.Mask(err)
+		}
 
-		var newPath *path.Path
-		{
-			c := path.Config{
-				Bytes: b,
+		l, err := s.Split(f, b)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		for _, d := range documents[f] {
+			var newPath *path.Path
+			{
+				c := path.Config{
+					Base64:        r.flag.Base64,
+					Bytes:         d.Bytes,
+					Format:        d.Format,
+					QueryLanguage: r.flag.QueryLanguage,
+					Sops:          k,
+					SplitAliases:  r.flag.SplitAliases,
+					Template:      r.flag.Template,
+				}
+
+				newPath, err = path.New(c)
+				if err != nil {
+					return tracer.Mask(err)
+				}
 			}
 
-			newPath, err = path.New(c)
+			lookup := r.lookup(newPath, matched, k)
+
+			if fragment != nil {
+				c := path.MergeConfig{
+					Fragment:     fragment,
+					ListStrategy: r.flag.ListStrategy,
+					MergeKey:     r.flag.MergeKey,
+				}
+
+				err = newPath.Merge(r.flag.Key, c)
+			} else if r.flag.ValueFromPath != "" {
+				var v string
+				v, err = r.copied(lookup)
+				if err == nil {
+					err = newPath.SetTyped(r.flag.Key, v, path.TypeJSON)
+				}
+			} else if r.flag.Value != "" {
+				// Only values given using -v/--value are expanded, so that
+				// documents read from files are written as given.
+				expand := func(s string) (string, error) {
+					return interpolate.Expand(s, lookup)
+				}
+
+				err = newPath.SetExpanded(r.flag.Key, value, t, expand)
+			} else {
+				err = newPath.SetTyped(r.flag.Key, value, t)
+			}
 			if err != nil {
 				return tracer.Mask(err)
 			}
-		}
 
-		err := newPath.Set(r.flag.Key, r.flag.Value)
-		if err != nil {
-			return tracer.Mask(err)
-		}
+			v, err := newPath.OutputBytes()
+			if err != nil {
+				return tracer.Mask(err)
+			}
 
-		v, err := newPath.OutputBytes()
-		if err != nil {
-			return tracer.Mask(err)
+			l[d.Index] = v
 		}
 
-		err = ioutil.WriteFile(p, v, 0600)
+		outputs = append(outputs, stream.Join(l))
+	}
+
+	for i, f := range files {
+		err := ioutil.WriteFile(f, outputs[i], 0600)
 		if err != nil {
 			return tracer.Mask(err)
 		}
@@ -97,3 +212,64 @@ func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) err
 
 	return nil
 }
+
+// copied returns the value found under the path given using --value-from-path
+// as JSON, so that the value is copied as a whole, keeping its type.
+func (r *runner) copied(lookup interpolate.Lookup) (string, error) {
+	v, ok, err := lookup(r.flag.ValueFromPath)
+	if err != nil {
+		return "", tracer.Mask(err)
+	}
+	if !ok {
+		return "", tracer.Maskf(unresolvedReferenceError, "--value-from-path %s must be defined in a matched document", r.flag.ValueFromPath)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", tracer.Mask(err)
+	}
+
+	return string(b), nil
+}
+
+// lookup returns the function resolving the paths values refer to. Paths are
+// looked up in the given document first, and in all other matched documents
+// in the order they were found otherwise. Matched documents encrypted with SOPS
+// are decrypted using the given keys.
+func (r *runner) lookup(current *path.Path, matched []searcher.Document, k *sops.Sops) interpolate.Lookup {
+	return func(key string) (interface{}, bool, error) {
+		v, err := current.Get(key)
+		if err == nil {
+			return v, true, nil
+		} else if !path.IsNotFound(err) {
+			return nil, false, tracer.Mask(err)
+		}
+
+		for _, d := range matched {
+			c := path.Config{
+				Base64:        r.flag.Base64,
+				Bytes:         d.Bytes,
+				Format:        d.Format,
+				QueryLanguage: r.flag.QueryLanguage,
+				Sops:          k,
+				Template:      r.flag.Template,
+			}
+
+			p, err := path.New(c)
+			if err != nil {
+				return nil, false, tracer.Mask(err)
+			}
+
+			v, err := p.Get(key)
+			if path.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return nil, false, tracer.Mask(err)
+			}
+
+			return v, true, nil
+		}
+
+		return nil, false, nil
+	}
+}
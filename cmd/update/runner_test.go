@@ -0,0 +1,225 @@
+package update
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/xh3b4sd/dsm/pkg/path"
+)
+
+func Test_Update_Runner(t *testing.T) {
+	testCases := []struct {
+		Files    []string
+		Key      string
+		Type     string
+		Value    string
+		Expected []string
+	}{
+		// Test case 1, ensure updating one document of a multi-document file
+		// keeps all other documents and separators as they are.
+		{
+			Files: []string{
+				`# services of the apiserver
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: apiserver   # same name, other kind
+spec:
+  ports: [80]
+--- # deployment
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  replicas: 1
+---
+
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: apiserver
+data:
+  key: |
+    ---
+    not a separator
+...
+`,
+			},
+			Key:   "spec.replicas",
+			Value: "3",
+			Expected: []string{
+				`# services of the apiserver
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: apiserver   # same name, other kind
+spec:
+  ports: [80]
+--- # deployment
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  replicas: 3
+---
+
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: apiserver
+data:
+  key: |
+    ---
+    not a separator
+...
+`,
+			},
+		},
+
+		// Test case 2, ensure documents of the same kind having other names are
+		// kept as they are.
+		{
+			Files: []string{
+				`kind: Deployment
+metadata:
+  name: worker
+spec:
+  replicas: 1
+---
+kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  replicas: 1
+`,
+			},
+			Key:   "spec.replicas",
+			Value: "2",
+			Expected: []string{
+				`kind: Deployment
+metadata:
+  name: worker
+spec:
+  replicas: 1
+---
+kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  replicas: 2
+`,
+			},
+		},
+
+		// Test case 3, ensure references in JSON values are expanded within
+		// their strings, so that expanded text cannot add keys.
+		{
+			Files: []string{
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  replicas: 1
+`,
+			},
+			Key:   "spec.obj",
+			Type:  path.TypeJSON,
+			Value: `{"user": "${DSM_TEST_USER}"}`,
+			Expected: []string{
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  replicas: 1
+  obj:
+    user: "a\", \"admin\": \"true"
+`,
+			},
+		},
+
+		// Test case 4, ensure references in YAML values are expanded within
+		// their strings, so that expanded text cannot add keys.
+		{
+			Files: []string{
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  replicas: 1
+`,
+			},
+			Key:   "spec.obj",
+			Type:  path.TypeYAML,
+			Value: "user: ${DSM_TEST_KEY}",
+			Expected: []string{
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  replicas: 1
+  obj:
+    user: |-
+      x
+      admin: true
+`,
+			},
+		},
+	}
+
+	os.Setenv("DSM_TEST_KEY", "x\nadmin: true")
+	os.Setenv("DSM_TEST_USER", `a", "admin": "true`)
+	defer os.Unsetenv("DSM_TEST_KEY")
+	defer os.Unsetenv("DSM_TEST_USER")
+
+	for i, tc := range testCases {
+		d, err := ioutil.TempDir("", "dsm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(d)
+
+		for j, f := range tc.Files {
+			err = ioutil.WriteFile(filepath.Join(d, strconv.Itoa(j)+".yaml"), []byte(f), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		r := &runner{
+			flag: &flag{
+				Key:      tc.Key,
+				Name:     "apiserver",
+				Resource: "Deployment",
+				Source:   d,
+				Type:     tc.Type,
+				Value:    tc.Value,
+			},
+		}
+		if r.flag.Type == "" {
+			r.flag.Type = path.TypeAuto
+		}
+
+		err = r.run(context.Background(), nil, nil)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		for j, e := range tc.Expected {
+			b, err := ioutil.ReadFile(filepath.Join(d, strconv.Itoa(j)+".yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != e {
+				t.Fatal("test", i+1, "expected", e, "got", string(b))
+			}
+		}
+	}
+}
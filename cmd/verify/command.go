@@ -40,6 +40,12 @@ code 1 and with the output of a stack trace.
         "type": "*tracer.Error"
     }
 
+Files without kind and metadata name, like dotenv files and Java properties
+files, can be selected using a glob pattern instead, the same way dsm update
+selects them.
+
+    dsm verify -g 'envs/*/.env' -k IMAGE_TAG
+
 `
 )
 
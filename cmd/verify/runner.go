@@ -2,6 +2,7 @@ package verify
 
 import (
 	"context"
+	"reflect"
 
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/xh3b4sd/dsm/pkg/path"
 	"github.com/xh3b4sd/dsm/pkg/searcher"
+	"github.com/xh3b4sd/dsm/pkg/sops"
 )
 
 type runner struct {
@@ -36,14 +38,29 @@ func (r *runner) Run(cmd *cobra.Command, args []string) error {
 func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) error {
 	var err error
 
+	var k *sops.Sops
+	{
+		c := sops.Config{
+			KeyFiles: r.flag.SopsKeys,
+		}
+
+		k, err = sops.New(c)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
 	var s *searcher.Searcher
 	{
 		c := searcher.Config{
 			FileSystem: afero.NewOsFs(),
 
+			Format:   r.flag.Format,
+			Glob:     r.flag.Glob,
 			Name:     r.flag.Name,
 			Resource: r.flag.Resource,
 			Source:   r.flag.Source,
+			Template: r.flag.Template,
 		}
 
 		s, err = searcher.New(c)
@@ -52,24 +69,29 @@ func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) err
 		}
 	}
 
-	var f map[string][]byte
+	var l []searcher.Document
 	{
-		f, err = s.Search()
+		l, err = s.Search()
 		if err != nil {
 			return tracer.Mask(err)
 		}
 	}
 
-	if len(f) == 0 {
+	if len(l) == 0 {
 		return tracer.Mask(notFoundError)
 	}
 
 	var x interface{}
-	for _, b := range f {
+	for _, d := range l {
 		var newPath *path.Path
 		{
 			c := path.Config{
-				Bytes: b,
+				Base64:        r.flag.Base64,
+				Bytes:         d.Bytes,
+				Format:        d.Format,
+				QueryLanguage: r.flag.QueryLanguage,
+				Sops:          k,
+				Template:      r.flag.Template,
 			}
 
 			newPath, err = path.New(c)
@@ -78,17 +100,21 @@ func (r *runner) run(ctx context.Context, cmd *cobra.Command, args []string) err
 			}
 		}
 
-		v, err := newPath.Get(r.flag.Key)
+		matches, err := newPath.GetAll(r.flag.Key)
 		if err != nil {
 			return tracer.Mask(err)
 		}
 
-		if x == nil {
-			x = v
-		}
+		for _, m := range matches {
+			if x == nil {
+				x = m.Value
+			}
 
-		if x != v {
-			return tracer.Mask(invalidValueError)
+			// Values matched by wildcards may be whole objects or lists,
+			// which cannot be compared using the equality operator.
+			if !reflect.DeepEqual(x, m.Value) {
+				return tracer.Mask(invalidValueError)
+			}
 		}
 	}
 
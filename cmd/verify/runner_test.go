@@ -0,0 +1,182 @@
+package verify
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func Test_Verify_Runner(t *testing.T) {
+	testCases := []struct {
+		Files        []string
+		Glob         string
+		Key          string
+		Names        []string
+		ErrorMatcher func(error) bool
+	}{
+		// Test case 1, ensure equal values across files are verified.
+		{
+			Files: []string{
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  image:
+    tag: v1
+`,
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  image:
+    tag: v1
+`,
+			},
+			Key: "spec.image.tag",
+		},
+
+		// Test case 2, ensure different values across files cause an error.
+		{
+			Files: []string{
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  image:
+    tag: v1
+`,
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  image:
+    tag: v2
+`,
+			},
+			Key:          "spec.image.tag",
+			ErrorMatcher: IsInvalidValue,
+		},
+
+		// Test case 3, ensure equal objects matched by wildcards are verified.
+		{
+			Files: []string{
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  containers:
+    - image: apiserver
+      ports: [80, 443]
+    - image: apiserver
+      ports: [80, 443]
+`,
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  containers:
+    - ports: [80, 443]
+      image: apiserver
+`,
+			},
+			Key: "spec.containers.*",
+		},
+
+		// Test case 4, ensure different objects matched by wildcards cause an
+		// error.
+		{
+			Files: []string{
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  containers:
+    - image: apiserver
+      ports: [80, 443]
+`,
+				`kind: Deployment
+metadata:
+  name: apiserver
+spec:
+  containers:
+    - image: apiserver
+      ports: [80]
+`,
+			},
+			Key:          "spec.containers.*",
+			ErrorMatcher: IsInvalidValue,
+		},
+
+		// Test case 5, ensure values of files selected by glob are verified.
+		{
+			Files: []string{
+				"IMAGE_TAG=v1\nDEBUG=true\n",
+				"IMAGE_TAG=v1\n",
+			},
+			Glob:  "*.env",
+			Key:   "IMAGE_TAG",
+			Names: []string{"a.env", "b.env"},
+		},
+
+		// Test case 6, ensure different values of files selected by glob cause
+		// an error.
+		{
+			Files: []string{
+				"IMAGE_TAG=v1\n",
+				"IMAGE_TAG=v2\n",
+			},
+			Glob:         "*.env",
+			Key:          "IMAGE_TAG",
+			Names:        []string{"a.env", "b.env"},
+			ErrorMatcher: IsInvalidValue,
+		},
+	}
+
+	for i, tc := range testCases {
+		d, err := ioutil.TempDir("", "dsm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(d)
+
+		for j, f := range tc.Files {
+			n := strconv.Itoa(j) + ".yaml"
+			if tc.Names != nil {
+				n = tc.Names[j]
+			}
+
+			err = ioutil.WriteFile(filepath.Join(d, n), []byte(f), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		r := &runner{
+			flag: &flag{
+				Glob:     tc.Glob,
+				Key:      tc.Key,
+				Name:     "apiserver",
+				Resource: "Deployment",
+				Source:   d,
+			},
+		}
+		if tc.Glob != "" {
+			r.flag.Name = ""
+			r.flag.Resource = ""
+		}
+
+		err = r.run(context.Background(), nil, nil)
+		if tc.ErrorMatcher != nil {
+			if !tc.ErrorMatcher(err) {
+				t.Fatal("test", i+1, "expected", true, "got", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+	}
+}
@@ -3,11 +3,13 @@ module github.com/xh3b4sd/dsm
 go 1.16
 
 require (
-	github.com/ghodss/yaml v1.0.0
+	filippo.io/age v1.0.0
+	github.com/ProtonMail/go-crypto v1.0.0
+	github.com/hashicorp/hcl/v2 v2.13.0
 	github.com/spf13/afero v1.6.0
-	github.com/spf13/cast v1.3.1
 	github.com/spf13/cobra v1.2.1
 	github.com/xh3b4sd/logger v0.2.0
 	github.com/xh3b4sd/tracer v0.4.0
-	gopkg.in/yaml.v2 v2.4.0
+	github.com/zclconf/go-cty v1.8.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,23 @@
+package interpolate
+
+import (
+	"errors"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+var invalidReferenceError = &tracer.Error{
+	Kind: "invalidReferenceError",
+}
+
+func IsInvalidReference(err error) bool {
+	return errors.Is(err, invalidReferenceError)
+}
+
+var unresolvedReferenceError = &tracer.Error{
+	Kind: "unresolvedReferenceError",
+}
+
+func IsUnresolvedReference(err error) bool {
+	return errors.Is(err, unresolvedReferenceError)
+}
@@ -0,0 +1,119 @@
+package interpolate
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+var (
+	nameExpression      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	pathExpression      = regexp.MustCompile("^\\{\\{-?\\s*path\\s+(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`)\\s*-?\\}\\}$")
+	referenceExpression = regexp.MustCompile(`(?s)\$\$\{|\$\{[^}]*\}?|\{\{-?\s*path\s.*?\}\}`)
+)
+
+// Lookup returns the value found under the given path, and whether the path
+// was found at all.
+type Lookup func(path string) (interface{}, bool, error)
+
+// Expand returns the given value having its references replaced. Environment
+// variables are referenced like ${GIT_SHA}. The values of paths are
+// referenced like {{ path "spec.x" }} and resolved using the given lookup,
+// which may be nil if paths must not be referenced. Replaced text is not
+// expanded again, and $${GIT_SHA} is written as ${GIT_SHA}. References which
+// cannot be resolved cause an error, so that values are never written with
+// parts of them missing.
+func Expand(value string, lookup Lookup) (string, error) {
+	var b strings.Builder
+
+	var o int
+	for _, s := range referenceExpression.FindAllStringIndex(value, -1) {
+		b.WriteString(value[o:s[0]])
+		o = s[1]
+
+		r := value[s[0]:s[1]]
+
+		switch {
+		case r == "$${":
+			b.WriteString("${")
+		case strings.HasPrefix(r, "${"):
+			v, err := env(r)
+			if err != nil {
+				return "", tracer.Mask(err)
+			}
+
+			b.WriteString(v)
+		default:
+			v, err := path(r, lookup)
+			if err != nil {
+				return "", tracer.Mask(err)
+			}
+
+			b.WriteString(v)
+		}
+	}
+
+	b.WriteString(value[o:])
+
+	return b.String(), nil
+}
+
+// env returns the value of the environment variable referenced like
+// ${GIT_SHA}. Variables which are set to the empty string resolve to the
+// empty string.
+func env(r string) (string, error) {
+	if !strings.HasSuffix(r, "}") {
+		return "", tracer.Maskf(invalidReferenceError, "%s must be closed with }", r)
+	}
+
+	n := r[2 : len(r)-1]
+	if !nameExpression.MatchString(n) {
+		return "", tracer.Maskf(invalidReferenceError, "%s must reference an environment variable name matching %s", r, nameExpression)
+	}
+
+	v, ok := os.LookupEnv(n)
+	if !ok {
+		return "", tracer.Maskf(unresolvedReferenceError, "environment variable %s must be set", n)
+	}
+
+	return v, nil
+}
+
+// path returns the value of the path referenced like {{ path "spec.x" }},
+// which must be a scalar.
+func path(r string, lookup Lookup) (string, error) {
+	m := pathExpression.FindStringSubmatch(r)
+	if m == nil {
+		return "", tracer.Maskf(invalidReferenceError, "%s must reference a path as quoted string", r)
+	}
+
+	p, err := strconv.Unquote(m[1])
+	if err != nil {
+		return "", tracer.Maskf(invalidReferenceError, "%s must reference a path as quoted string", r)
+	}
+
+	if lookup == nil {
+		return "", tracer.Maskf(invalidReferenceError, "%s must not reference a path here", r)
+	}
+
+	v, ok, err := lookup(p)
+	if err != nil {
+		return "", tracer.Mask(err)
+	}
+	if !ok {
+		return "", tracer.Maskf(unresolvedReferenceError, "path %s must be defined", p)
+	}
+
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return "", tracer.Maskf(invalidReferenceError, "path %s must refer to a scalar", p)
+	case nil:
+		return "null", nil
+	}
+
+	return fmt.Sprint(v), nil
+}
@@ -0,0 +1,132 @@
+package interpolate
+
+import (
+	"os"
+	"testing"
+)
+
+func Test_Interpolate_Expand(t *testing.T) {
+	testCases := []struct {
+		Input    string
+		Values   map[string]interface{}
+		Expected string
+	}{
+		// Test case 1, ensure values without references are returned as is.
+		{
+			Input:    "v1.2.0",
+			Expected: "v1.2.0",
+		},
+
+		// Test case 2, ensure environment variables are replaced.
+		{
+			Input:    "sha-${DSM_TEST_SHA}",
+			Expected: "sha-abc123",
+		},
+
+		// Test case 3, ensure escaped references are written without being
+		// expanded, and that replaced text is not expanded again.
+		{
+			Input:    "$${DSM_TEST_SHA} ${DSM_TEST_REFERENCE}",
+			Expected: "${DSM_TEST_SHA} ${DSM_TEST_SHA}",
+		},
+
+		// Test case 4, ensure paths are replaced with their scalar values.
+		{
+			Input: `{{ path "spec.values.image.tag" }}-{{ path "spec.replicas" }}`,
+			Values: map[string]interface{}{
+				"spec.values.image.tag": "1.0.0",
+				"spec.replicas":         3,
+			},
+			Expected: "1.0.0-3",
+		},
+
+		// Test case 5, ensure dollar signs and template actions other than
+		// references are kept.
+		{
+			Input:    "$HOME {{ .Values.image }}",
+			Expected: "$HOME {{ .Values.image }}",
+		},
+	}
+
+	os.Setenv("DSM_TEST_SHA", "abc123")
+	os.Setenv("DSM_TEST_REFERENCE", "${DSM_TEST_SHA}")
+	defer os.Unsetenv("DSM_TEST_SHA")
+	defer os.Unsetenv("DSM_TEST_REFERENCE")
+
+	for i, tc := range testCases {
+		lookup := func(path string) (interface{}, bool, error) {
+			v, ok := tc.Values[path]
+			return v, ok, nil
+		}
+
+		output, err := Expand(tc.Input, lookup)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if output != tc.Expected {
+			t.Fatal("test", i+1, "expected", tc.Expected, "got", output)
+		}
+	}
+}
+
+func Test_Interpolate_Expand_Error(t *testing.T) {
+	testCases := []struct {
+		Input        string
+		Values       map[string]interface{}
+		ErrorMatcher func(error) bool
+	}{
+		// Test case 1, ensure unset environment variables cause an error.
+		{
+			Input:        "${DSM_TEST_UNSET}",
+			ErrorMatcher: IsUnresolvedReference,
+		},
+
+		// Test case 2, ensure undefined paths cause an error.
+		{
+			Input:        `{{ path "spec.x" }}`,
+			ErrorMatcher: IsUnresolvedReference,
+		},
+
+		// Test case 3, ensure references which are not closed cause an error.
+		{
+			Input:        "${DSM_TEST_UNSET",
+			ErrorMatcher: IsInvalidReference,
+		},
+
+		// Test case 4, ensure references to invalid environment variable names
+		// cause an error.
+		{
+			Input:        "${DSM_TEST_UNSET:-default}",
+			ErrorMatcher: IsInvalidReference,
+		},
+
+		// Test case 5, ensure paths which are not quoted cause an error.
+		{
+			Input:        "{{ path spec.x }}",
+			ErrorMatcher: IsInvalidReference,
+		},
+
+		// Test case 6, ensure paths referring to objects cause an error.
+		{
+			Input: `{{ path "spec" }}`,
+			Values: map[string]interface{}{
+				"spec": map[string]interface{}{"x": "y"},
+			},
+			ErrorMatcher: IsInvalidReference,
+		},
+	}
+
+	os.Unsetenv("DSM_TEST_UNSET")
+
+	for i, tc := range testCases {
+		lookup := func(path string) (interface{}, bool, error) {
+			v, ok := tc.Values[path]
+			return v, ok, nil
+		}
+
+		_, err := Expand(tc.Input, lookup)
+		if !tc.ErrorMatcher(err) {
+			t.Fatal("test", i+1, "expected", true, "got", false)
+		}
+	}
+}
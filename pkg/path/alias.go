@@ -0,0 +1,123 @@
+package path
+
+import (
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// detach replaces the aliases and merged keys found along the given path
+// segments with copies of the nodes they refer to, so that setting the path
+// does not modify the anchors shared with other parts of the document. Aliases
+// addressed by the last segment are left alone, since setting them replaces
+// them anyway.
+func (p *Path) detach(split []string) error {
+	for {
+		p.edits = nil
+
+		ok, err := p.detachFromNode(split, p.root())
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		if !ok {
+			return nil
+		}
+
+		err = p.apply()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+}
+
+// detachFromNode detaches the first alias or merged key found along the given
+// path segments and returns whether it did so.
+func (p *Path) detachFromNode(split []string, n *yaml.Node) (bool, error) {
+	if len(split) == 0 {
+		return false, nil
+	}
+
+	if n.Kind == yaml.AliasNode {
+		err := p.replace(n, clone(n))
+		if err != nil {
+			return false, tracer.Mask(err)
+		}
+
+		return true, nil
+	}
+
+	key := p.unescapeKey(split[0])
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		i := index(n, key)
+		if i == -1 {
+			v := lookup(n, key)
+			if v == nil {
+				return false, nil
+			}
+
+			err := p.insert(n, key, clone(v))
+			if err != nil {
+				return false, tracer.Mask(err)
+			}
+
+			return true, nil
+		}
+
+		return p.detachFromNode(split[1:], n.Content[i+1])
+	case yaml.SequenceNode:
+		var l []int
+		if pr, ok := predicateFromKey(key); ok {
+			l = pr.indices(n)
+		} else if i, err := indexFromKey(key); err == nil {
+			if i < 0 {
+				i += len(n.Content)
+			}
+			if i >= 0 && i < len(n.Content) {
+				l = []int{i}
+			}
+		}
+
+		for _, i := range l {
+			ok, err := p.detachFromNode(split[1:], n.Content[i])
+			if err != nil {
+				return false, tracer.Mask(err)
+			}
+
+			if ok {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// origin returns the mapping providing the given key to the given mapping
+// using merge keys, or nil if the key is not merged into the given mapping.
+func origin(m *yaml.Node, key string) *yaml.Node {
+	if index(m, key) != -1 {
+		return nil
+	}
+
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Tag != "!!merge" {
+			continue
+		}
+
+		l := []*yaml.Node{resolve(m.Content[i+1])}
+		if l[0].Kind == yaml.SequenceNode {
+			l = l[0].Content
+		}
+
+		for _, v := range l {
+			v = resolve(v)
+			if v.Kind == yaml.MappingNode && lookup(v, key) != nil {
+				return v
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package path
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// base64Marker is the path segment forcing the string addressed by the
+	// previous segments to be decoded as base64, e.g. data.token[base64] or
+	// data.config\.json[base64].endpoint.
+	base64Marker = "[base64]"
+	// plainMarker is the path segment preventing the string addressed by the
+	// previous segments from being decoded as base64, even if Config.Base64
+	// is set.
+	plainMarker = "[!base64]"
+)
+
+// deleteMarked deletes the given path segments from the document embedded in
+// the given string node, as described by the marker segment the path segments
+// start with.
+func (p *Path) deleteMarked(split []string, n *yaml.Node) error {
+	if len(split) == 1 {
+		return tracer.Maskf(notFoundError, "key '%s'", split[0])
+	}
+
+	e, err := p.embeddedAs(n, split[0] == base64Marker)
+	if err != nil {
+		return tracer.Maskf(notFoundError, "key '%s'", strings.Join(split, p.separator))
+	}
+
+	modify := func(e *Path) error {
+		return e.delete(split[1:])
+	}
+
+	err = p.writeEmbedded(n, e, modify)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+// matchMarked returns the matches of the given path segments in the given
+// string node, as described by the marker segment the path segments start
+// with. The base64 marker addressing a string itself matches the decoded
+// string.
+func (p *Path) matchMarked(split []string, n *yaml.Node, prefix []string) []match {
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!str" {
+		return nil
+	}
+
+	prefix = with(prefix, split[0])
+
+	if len(split) == 1 {
+		if split[0] == plainMarker {
+			return []match{{node: n, split: prefix}}
+		}
+
+		b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(n.Value), ""))
+		if err != nil {
+			return nil
+		}
+
+		return []match{{node: &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(b)}, split: prefix}}
+	}
+
+	e, err := p.embeddedAs(n, split[0] == base64Marker)
+	if err != nil {
+		return nil
+	}
+
+	return e.matchFromNode(split[1:], e.root(), prefix)
+}
+
+// setMarked sets the given value under the given path segments in the given
+// string node, as described by the marker segment the path segments start
+// with. The base64 marker addressing a string itself sets the string to the
+// base64 encoding of the given scalar value.
+func (p *Path) setMarked(split []string, value *yaml.Node, n *yaml.Node) error {
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!str" {
+		return tracer.Maskf(notFoundError, "key '%s'", split[0])
+	}
+
+	if len(split) == 1 {
+		if split[0] == plainMarker {
+			err := p.replace(n, value)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+
+			return nil
+		}
+
+		if value.Kind != yaml.ScalarNode {
+			return tracer.Maskf(invalidFormatError, "base64 encoded values must be scalars")
+		}
+
+		var s yaml.Node
+		err := s.Encode(base64.StdEncoding.EncodeToString([]byte(value.Value)))
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		err = p.replace(n, &s)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	}
+
+	e, err := p.embeddedAs(n, split[0] == base64Marker)
+	if err != nil {
+		return tracer.Maskf(notFoundError, "key '%s'", strings.Join(split, p.separator))
+	}
+
+	modify := func(e *Path) error {
+		return e.set(split[1:], value)
+	}
+
+	err = p.writeEmbedded(n, e, modify)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+func isMarker(key string) bool {
+	return key == base64Marker || key == plainMarker
+}
@@ -22,6 +22,22 @@ func IsInvalidFormat(err error) bool {
 	return errors.Is(err, invalidFormatError)
 }
 
+var invalidPatchError = &tracer.Error{
+	Kind: "invalidPatchError",
+}
+
+func IsInvalidPatch(err error) bool {
+	return errors.Is(err, invalidPatchError)
+}
+
+var invalidQueryError = &tracer.Error{
+	Kind: "invalidQueryError",
+}
+
+func IsInvalidQuery(err error) bool {
+	return errors.Is(err, invalidQueryError)
+}
+
 var notFoundError = &tracer.Error{
 	Kind: "notFoundError",
 }
@@ -29,3 +45,11 @@ var notFoundError = &tracer.Error{
 func IsNotFound(err error) bool {
 	return errors.Is(err, notFoundError)
 }
+
+var testFailedError = &tracer.Error{
+	Kind: "testFailedError",
+}
+
+func IsTestFailed(err error) bool {
+	return errors.Is(err, testFailedError)
+}
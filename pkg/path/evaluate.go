@@ -0,0 +1,425 @@
+package path
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"unicode/utf8"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+type functionType int
+
+const (
+	valueType functionType = iota
+	logicalType
+	nodesType
+)
+
+// expression is a logical expression of a filter selector, evaluated for every
+// child of the filter's input node.
+type expression interface {
+	test(e *evaluation, current match) bool
+}
+
+// operand is a literal, a query or a function expression producing a single
+// value. The returned bool is false if no value could be produced, which is
+// what RFC 9535 calls Nothing.
+type operand interface {
+	value(e *evaluation, current match) (interface{}, bool)
+}
+
+type andExpression []expression
+
+func (a andExpression) test(e *evaluation, current match) bool {
+	for _, x := range a {
+		if !x.test(e, current) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type orExpression []expression
+
+func (o orExpression) test(e *evaluation, current match) bool {
+	for _, x := range o {
+		if x.test(e, current) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type notExpression struct {
+	expression expression
+}
+
+func (n notExpression) test(e *evaluation, current match) bool {
+	return !n.expression.test(e, current)
+}
+
+type comparison struct {
+	left  operand
+	op    string
+	right operand
+}
+
+func (c comparison) test(e *evaluation, current match) bool {
+	a, aok := c.left.value(e, current)
+	b, bok := c.right.value(e, current)
+
+	switch c.op {
+	case "==":
+		return equal(a, aok, b, bok)
+	case "!=":
+		return !equal(a, aok, b, bok)
+	case "<":
+		return less(a, aok, b, bok)
+	case ">":
+		return less(b, bok, a, aok)
+	case "<=":
+		return less(a, aok, b, bok) || equal(a, aok, b, bok)
+	case ">=":
+		return less(b, bok, a, aok) || equal(a, aok, b, bok)
+	}
+
+	return false
+}
+
+// existence tests whether a query selects at least one node.
+type existence struct {
+	query *query
+}
+
+func (x existence) test(e *evaluation, current match) bool {
+	return len(e.nodes(x.query, current)) != 0
+}
+
+type literal struct {
+	v interface{}
+}
+
+func (l literal) value(e *evaluation, current match) (interface{}, bool) {
+	return l.v, true
+}
+
+func (q *query) value(e *evaluation, current match) (interface{}, bool) {
+	l := e.nodes(q, current)
+	if len(l) != 1 {
+		return nil, false
+	}
+
+	return decode(l[0].node)
+}
+
+// function is one of the function extensions defined in RFC 9535.
+type function struct {
+	name string
+	args []operand
+}
+
+func (f *function) result() functionType {
+	if f.name == "match" || f.name == "search" {
+		return logicalType
+	}
+
+	return valueType
+}
+
+func (f *function) test(e *evaluation, current match) bool {
+	a, aok := f.args[0].value(e, current)
+	b, bok := f.args[1].value(e, current)
+	if !aok || !bok {
+		return false
+	}
+
+	s, ok := a.(string)
+	if !ok {
+		return false
+	}
+	r, ok := b.(string)
+	if !ok {
+		return false
+	}
+
+	if f.name == "match" {
+		r = "^(?:" + r + ")$"
+	}
+
+	re, err := regexp.Compile(r)
+	if err != nil {
+		return false
+	}
+
+	return re.MatchString(s)
+}
+
+func (f *function) value(e *evaluation, current match) (interface{}, bool) {
+	switch f.name {
+	case "count":
+		return float64(len(e.nodes(f.args[0].(*query), current))), true
+	case "length":
+		v, ok := f.args[0].value(e, current)
+		if !ok {
+			return nil, false
+		}
+
+		switch v := v.(type) {
+		case string:
+			return float64(utf8.RuneCountInString(v)), true
+		case []interface{}:
+			return float64(len(v)), true
+		case map[string]interface{}:
+			return float64(len(v)), true
+		}
+	case "value":
+		l := e.nodes(f.args[0].(*query), current)
+		if len(l) == 1 {
+			return decode(l[0].node)
+		}
+	}
+
+	return nil, false
+}
+
+// evaluation evaluates JSONPath queries against the document of a Path. The
+// matches produced carry the path segments of the dotted syntax, so that they
+// can be modified like any other path.
+type evaluation struct {
+	path *Path
+	root match
+}
+
+func (p *Path) evaluate(q *query) []match {
+	e := &evaluation{
+		path: p,
+		root: match{node: p.root()},
+	}
+
+	return e.nodes(q, e.root)
+}
+
+// descendants returns the given node and all of its descendants in document
+// order.
+func (e *evaluation) descendants(m match) []match {
+	l := []match{m}
+
+	keys, values := e.path.children(resolve(m.node))
+	for i := range keys {
+		l = append(l, e.descendants(match{node: values[i], split: with(m.split, keys[i])})...)
+	}
+
+	return l
+}
+
+func (e *evaluation) nodes(q *query, current match) []match {
+	l := []match{e.root}
+	if q.relative {
+		l = []match{current}
+	}
+
+	for _, s := range q.segments {
+		var next []match
+		for _, m := range l {
+			if s.descendant {
+				for _, d := range e.descendants(m) {
+					next = append(next, e.apply(s.selectors, d, false)...)
+				}
+			} else {
+				next = append(next, e.apply(s.selectors, m, true)...)
+			}
+		}
+
+		l = next
+	}
+
+	return l
+}
+
+// apply applies the given selectors to the given node. Strings containing
+// embedded documents are entered by child segments, just like they are
+// entered by segments of the dotted syntax.
+func (e *evaluation) apply(selectors []selector, m match, embedded bool) []match {
+	p := e.path
+	n := resolve(m.node)
+
+	if embedded && n.Kind == yaml.ScalarNode {
+		x, err := p.embedded(n)
+		if err == nil {
+			p = x
+			n = x.root()
+		}
+	}
+
+	var l []match
+	for _, s := range selectors {
+		l = append(l, e.choose(p, s, match{node: n, split: m.split})...)
+	}
+
+	return l
+}
+
+// choose returns the nodes selected by the given selector from the given node.
+func (e *evaluation) choose(p *Path, s selector, m match) []match {
+	var l []match
+
+	n := m.node
+
+	switch s.kind {
+	case nameSelector:
+		if n.Kind == yaml.MappingNode {
+			v := lookup(n, s.name)
+			if v != nil {
+				l = append(l, match{node: v, split: with(m.split, p.escapeSeparator(s.name))})
+			}
+		}
+	case wildcardSelector:
+		keys, values := p.children(n)
+		for i := range keys {
+			l = append(l, match{node: values[i], split: with(m.split, keys[i])})
+		}
+	case indexSelector:
+		if n.Kind == yaml.SequenceNode {
+			i := s.index
+			if i < 0 {
+				i += len(n.Content)
+			}
+			if i >= 0 && i < len(n.Content) {
+				l = append(l, match{node: n.Content[i], split: with(m.split, fmt.Sprintf("[%d]", i))})
+			}
+		}
+	case sliceSelector:
+		if n.Kind == yaml.SequenceNode {
+			for _, i := range slice(s, len(n.Content)) {
+				l = append(l, match{node: n.Content[i], split: with(m.split, fmt.Sprintf("[%d]", i))})
+			}
+		}
+	case filterSelector:
+		keys, values := p.children(n)
+		for i := range keys {
+			c := match{node: values[i], split: with(m.split, keys[i])}
+			if s.filter.test(e, c) {
+				l = append(l, c)
+			}
+		}
+	}
+
+	return l
+}
+
+// decode returns the value of the given node, with all numbers converted to
+// float64 so that they can be compared regardless of their original type.
+func decode(n *yaml.Node) (interface{}, bool) {
+	var v interface{}
+	err := resolve(n).Decode(&v)
+	if err != nil {
+		return nil, false
+	}
+
+	return numbers(v), true
+}
+
+func equal(a interface{}, aok bool, b interface{}, bok bool) bool {
+	if !aok || !bok {
+		return aok == bok
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func less(a interface{}, aok bool, b interface{}, bok bool) bool {
+	if !aok || !bok {
+		return false
+	}
+
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		return ok && a < b
+	case string:
+		b, ok := b.(string)
+		return ok && a < b
+	}
+
+	return false
+}
+
+func numbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = numbers(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = numbers(v[k])
+		}
+	case map[interface{}]interface{}:
+		for k := range v {
+			v[k] = numbers(v[k])
+		}
+	}
+
+	return v
+}
+
+// slice returns the indices selected by the given slice selector from a list
+// of the given length, as specified in RFC 9535.
+func slice(s selector, l int) []int {
+	if s.step == 0 {
+		return nil
+	}
+
+	normalize := func(i int) int {
+		if i < 0 {
+			return l + i
+		}
+		return i
+	}
+	bound := func(i, lower, upper int) int {
+		if i < lower {
+			return lower
+		}
+		if i > upper {
+			return upper
+		}
+		return i
+	}
+
+	var r []int
+	if s.step > 0 {
+		start, end := 0, l
+		if s.start != nil {
+			start = bound(normalize(*s.start), 0, l)
+		}
+		if s.end != nil {
+			end = bound(normalize(*s.end), 0, l)
+		}
+		for i := start; i < end; i += s.step {
+			r = append(r, i)
+		}
+	} else {
+		start, end := l-1, -1
+		if s.start != nil {
+			start = bound(normalize(*s.start), -1, l-1)
+		}
+		if s.end != nil {
+			end = bound(normalize(*s.end), -1, l-1)
+		}
+		for i := start; i > end; i += s.step {
+			r = append(r, i)
+		}
+	}
+
+	return r
+}
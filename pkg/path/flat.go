@@ -0,0 +1,479 @@
+package path
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	dotenvKeyExpression   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+	dotenvPlainExpression = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+)
+
+// flat describes where the keys and values of a flat key value document, like
+// a dotenv file or a Java properties file, are written, so that modifications
+// can be spliced into the original bytes, keeping comments and the order of
+// the keys.
+type flat struct {
+	format string
+	// lines are the byte ranges of the key value pairs defining the given
+	// values, from the start of the line of the key to the line break
+	// following the value.
+	lines map[*yaml.Node]span
+	// prefix is written in front of the keys of new key value pairs, like the
+	// export keyword of the last key value pair of a dotenv file.
+	prefix string
+	// separator is written between the keys and values of new key value
+	// pairs, like the separator of the last key value pair of the document.
+	separator string
+	// values are the byte ranges of the values, including their quotes.
+	values map[*yaml.Node]span
+}
+
+// parseFlat returns the given flat key value document of the given format as
+// YAML node tree, together with the description of where its values are
+// written. All values are strings. Keys defined twice have the value of their
+// last definition.
+func parseFlat(b []byte, format string) (*yaml.Node, *flat, error) {
+	f := &flat{
+		format:    format,
+		lines:     map[*yaml.Node]span{},
+		separator: "=",
+		values:    map[*yaml.Node]span{},
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+
+	var err error
+	if format == FormatDotenv {
+		err = f.parseDotenv(b, root)
+	} else {
+		err = f.parseProperties(b, root)
+	}
+	if err != nil {
+		return nil, nil, tracer.Mask(err)
+	}
+
+	n := &yaml.Node{
+		Kind:    yaml.DocumentNode,
+		Content: []*yaml.Node{root},
+	}
+
+	return n, f, nil
+}
+
+// add adds the given key and value to the given mapping, replacing the value
+// of a key defined before.
+func (f *flat) add(m *yaml.Node, key string, value *yaml.Node, line span, s span) {
+	f.lines[value] = line
+	f.values[value] = s
+
+	for i := 0; i < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1] = value
+			return
+		}
+	}
+
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// fail returns the invalid format error for the given reason, naming the line
+// of the given offset.
+func (f *flat) fail(b []byte, o int, reason string) error {
+	return tracer.Maskf(invalidFormatError, "%s: %s at line %d", f.format, reason, bytes.Count(b[:o], []byte("\n"))+1)
+}
+
+// parseDotenv reads the key value pairs of the given dotenv file into the
+// given mapping. Values may be double quoted, supporting escape sequences,
+// single quoted, or unquoted, ending at a comment.
+func (f *flat) parseDotenv(b []byte, m *yaml.Node) error {
+	o := 0
+	for o < len(b) {
+		l := o
+		i := skip(b, o, " \t")
+
+		if i >= len(b) {
+			break
+		}
+		if b[i] == '\n' || b[i] == '\r' || b[i] == '#' {
+			o = nextLine(b, i)
+			continue
+		}
+
+		k := i
+		if bytes.HasPrefix(b[i:], []byte("export ")) || bytes.HasPrefix(b[i:], []byte("export\t")) {
+			k = skip(b, i+len("export"), " \t")
+		}
+
+		j := k
+		for j < len(b) && b[j] != '=' && b[j] != '\n' {
+			j++
+		}
+		if j >= len(b) || b[j] != '=' {
+			return f.fail(b, k, "missing =")
+		}
+
+		key := strings.TrimRight(string(b[k:j]), " \t")
+		if !dotenvKeyExpression.MatchString(key) {
+			return f.fail(b, k, fmt.Sprintf("invalid key %q", key))
+		}
+
+		v := skip(b, j+1, " \t")
+
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str"}
+		s := span{start: v, end: v}
+
+		switch {
+		case v < len(b) && (b[v] == '"' || b[v] == '\''):
+			e := v + 1
+			for e < len(b) && b[e] != b[v] {
+				if b[v] == '"' && b[e] == '\\' {
+					e++
+				}
+				e++
+			}
+			if e >= len(b) {
+				return f.fail(b, v, "unterminated string")
+			}
+
+			if b[v] == '"' {
+				value.Style = yaml.DoubleQuotedStyle
+				value.Value = unescapeDotenv(string(b[v+1 : e]))
+			} else {
+				value.Style = yaml.SingleQuotedStyle
+				value.Value = string(b[v+1 : e])
+			}
+
+			s.end = e + 1
+
+			r := skip(b, s.end, " \t")
+			if r < len(b) && b[r] != '\n' && b[r] != '\r' && b[r] != '#' {
+				return f.fail(b, r, fmt.Sprintf("unexpected %q", b[r]))
+			}
+		default:
+			e := v
+			for e < len(b) && b[e] != '\n' && b[e] != '\r' {
+				if b[e] == '#' && (b[e-1] == ' ' || b[e-1] == '\t') {
+					break
+				}
+				e++
+			}
+
+			value.Value = strings.TrimRight(string(b[v:e]), " \t")
+			s.end = v + len(value.Value)
+		}
+
+		o = nextLine(b, s.end)
+
+		f.prefix = string(b[i:k])
+		f.separator = string(b[k+len(key) : v])
+		f.add(m, key, value, span{start: l, end: o}, s)
+	}
+
+	return nil
+}
+
+// parseProperties reads the key value pairs of the given Java properties file
+// into the given mapping, as specified for java.util.Properties. Keys end at
+// the first unescaped separator, which is either =, : or whitespace. Lines
+// ending with an unescaped backslash are continued on the next line.
+func (f *flat) parseProperties(b []byte, m *yaml.Node) error {
+	o := 0
+	for o < len(b) {
+		l := o
+		i := skip(b, o, " \t\f")
+
+		if i >= len(b) {
+			break
+		}
+		if b[i] == '\n' || b[i] == '\r' || b[i] == '#' || b[i] == '!' {
+			o = nextLine(b, i)
+			continue
+		}
+
+		key, k, err := f.readProperty(b, i, true)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		v := skip(b, k, " \t\f")
+		if v < len(b) && (b[v] == '=' || b[v] == ':') {
+			v = skip(b, v+1, " \t\f")
+		}
+
+		value, e, err := f.readProperty(b, v, false)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		o = nextLine(b, e)
+
+		if v > k {
+			f.separator = string(b[k:v])
+		}
+		f.add(m, key, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}, span{start: l, end: o}, span{start: v, end: e})
+	}
+
+	return nil
+}
+
+// readProperty returns the key or value of a Java properties file starting at
+// the given offset, together with the offset it ends at. Escape sequences are
+// resolved and continued lines are joined.
+func (f *flat) readProperty(b []byte, o int, key bool) (string, int, error) {
+	var s strings.Builder
+	for o < len(b) {
+		c := b[o]
+		if c == '\n' || c == '\r' || (key && (c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f')) {
+			break
+		}
+		if c != '\\' {
+			s.WriteByte(c)
+			o++
+			continue
+		}
+
+		if o+1 >= len(b) {
+			o++
+			break
+		}
+
+		o += 2
+		switch b[o-1] {
+		case '\r', '\n':
+			if b[o-1] == '\r' && o < len(b) && b[o] == '\n' {
+				o++
+			}
+			o = skip(b, o, " \t\f")
+		case 't':
+			s.WriteByte('\t')
+		case 'n':
+			s.WriteByte('\n')
+		case 'r':
+			s.WriteByte('\r')
+		case 'f':
+			s.WriteByte('\f')
+		case 'u':
+			if o+4 > len(b) {
+				return "", 0, f.fail(b, o, "invalid unicode escape")
+			}
+			r, err := strconv.ParseUint(string(b[o:o+4]), 16, 32)
+			if err != nil {
+				return "", 0, f.fail(b, o, "invalid unicode escape")
+			}
+			o += 4
+
+			// Characters outside of the basic multilingual plane are
+			// written as surrogate pairs, like \uD83D\uDE00.
+			c := rune(r)
+			if utf16.IsSurrogate(c) && o+6 <= len(b) && b[o] == '\\' && b[o+1] == 'u' {
+				l, err := strconv.ParseUint(string(b[o+2:o+6]), 16, 32)
+				if err == nil && utf16.DecodeRune(c, rune(l)) != utf8.RuneError {
+					c = utf16.DecodeRune(c, rune(l))
+					o += 6
+				}
+			}
+			s.WriteRune(c)
+		default:
+			s.WriteByte(b[o-1])
+		}
+	}
+
+	return s.String(), o, nil
+}
+
+// addFlatEdit adds the given edit to the edits of a flat document. Text
+// inserted at the same offset is written in the order it was inserted.
+func (p *Path) addFlatEdit(e edit) {
+	var l []edit
+	for _, x := range p.edits {
+		if e.start == e.end && x.start == x.end && e.start == x.start {
+			e.text = x.text + e.text
+			continue
+		}
+
+		l = append(l, x)
+	}
+
+	p.edits = append(l, e)
+}
+
+// flatValue returns the given scalar written as value of a flat document,
+// using the given quoting style if possible. Null values are written as empty
+// strings.
+func (p *Path) flatValue(n *yaml.Node, style yaml.Style) string {
+	v := n.Value
+	if n.Tag == "!!null" {
+		v = ""
+	}
+
+	if p.flat.format == FormatProperties {
+		return escapeProperty(v, false)
+	}
+
+	// Variables like $HOME are expanded within double quotes by e.g. docker
+	// compose and shells, so that values containing $ are single quoted if
+	// possible, and have their $ escaped otherwise.
+	switch {
+	case style&yaml.SingleQuotedStyle != 0 && !strings.ContainsAny(v, "'\n"):
+		return "'" + v + "'"
+	case style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle) == 0 && dotenvPlainExpression.MatchString(v):
+		return v
+	case strings.Contains(v, "$") && !strings.ContainsAny(v, "'\n"):
+		return "'" + v + "'"
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`)
+
+	return `"` + r.Replace(v) + `"`
+}
+
+// insertFlat writes the given key and value added to a flat document as new
+// line at the end of the document.
+func (p *Path) insertFlat(m *yaml.Node, key *yaml.Node, value *yaml.Node) error {
+	if m != p.root() || value.Kind != yaml.ScalarNode {
+		return tracer.Maskf(invalidFormatError, "%s documents cannot contain nested values", p.flat.format)
+	}
+
+	k := key.Value
+	if p.flat.format == FormatDotenv && !dotenvKeyExpression.MatchString(k) {
+		return tracer.Maskf(invalidFormatError, "dotenv key %q must match %s", k, dotenvKeyExpression)
+	} else if p.flat.format == FormatProperties {
+		k = escapeProperty(k, true)
+	}
+
+	e := edit{
+		start: len(p.bytes),
+		end:   len(p.bytes),
+		text:  p.flat.prefix + k + p.flat.separator + p.flatValue(value, 0) + "\n",
+	}
+
+	if e.start > 0 && p.bytes[e.start-1] != '\n' {
+		e.text = "\n" + e.text
+	}
+
+	p.addFlatEdit(e)
+
+	return nil
+}
+
+// removeFlat removes the line of the given value removed from a flat
+// document.
+func (p *Path) removeFlat(removed *yaml.Node) error {
+	l, ok := p.flat.lines[removed]
+	if !ok {
+		return tracer.Maskf(invalidFormatError, "%s documents cannot contain nested values", p.flat.format)
+	}
+
+	p.addFlatEdit(edit{start: l.start, end: l.end})
+
+	return nil
+}
+
+// replaceFlat writes the given value in place of the given value of a flat
+// document. Dotenv values keep their quotes if possible.
+func (p *Path) replaceFlat(n *yaml.Node, value *yaml.Node) error {
+	s, ok := p.flat.values[n]
+	if !ok || value.Kind != yaml.ScalarNode {
+		return tracer.Maskf(invalidFormatError, "%s documents cannot contain nested values", p.flat.format)
+	}
+
+	p.addFlatEdit(edit{start: s.start, end: s.end, text: p.flatValue(value, n.Style)})
+
+	return nil
+}
+
+// escapeProperty returns the given key or value of a Java properties file
+// with all characters escaped which would not be read as given otherwise.
+// Properties files are read as ISO 8859-1, so that all characters outside of
+// printable ASCII are written as unicode escape sequences.
+func escapeProperty(s string, key bool) string {
+	var b strings.Builder
+	for i, c := range s {
+		switch {
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\f':
+			b.WriteString(`\f`)
+		case c == ' ' && (key || i == 0):
+			b.WriteString(`\ `)
+		case strings.ContainsRune("=:#!", c) && (key || i == 0):
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case c > 0xffff:
+			h, l := utf16.EncodeRune(c)
+			b.WriteString(fmt.Sprintf(`\u%04X\u%04X`, h, l))
+		case c < 0x20 || c > 0x7e:
+			b.WriteString(fmt.Sprintf(`\u%04X`, c))
+		default:
+			b.WriteRune(c)
+		}
+	}
+
+	return b.String()
+}
+
+// nextLine returns the offset of the line following the given offset, or the
+// length of the given bytes if there is none.
+func nextLine(b []byte, o int) int {
+	i := bytes.IndexByte(b[o:], '\n')
+	if i == -1 {
+		return len(b)
+	}
+
+	return o + i + 1
+}
+
+// skip returns the offset of the first byte at or after the given offset
+// which is not one of the given characters.
+func skip(b []byte, o int, chars string) int {
+	for o < len(b) && strings.IndexByte(chars, b[o]) != -1 {
+		o++
+	}
+
+	return o
+}
+
+// unescapeDotenv resolves the escape sequences of a double quoted dotenv
+// value. Unknown escape sequences are kept as given.
+func unescapeDotenv(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '$', '\\':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String()
+}
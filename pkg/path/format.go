@@ -0,0 +1,218 @@
+package path
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// format describes the layout of a JSON document, so that values added to the
+// document are written looking the way the document was written.
+type format struct {
+	// base is the indentation of the closing bracket of the document, which
+	// all lines but the first one start with. Documents embedded in strings
+	// are sometimes indented this way.
+	base string
+	// colon is written between keys and values, e.g. ": ".
+	colon string
+	// comma is written between the members of collections in compact
+	// documents, e.g. ", ".
+	comma string
+	// identifiers causes keys which are identifiers to be written without
+	// quotes, like JSON5 documents allow.
+	identifiers bool
+	// indent is the string nested lines are indented with, e.g. a tab or two
+	// spaces. Documents without indent are written on a single line.
+	indent string
+}
+
+// newFormat detects the format of the given JSON document. Documents which do
+// not contain any members are indented using two spaces.
+func newFormat(b []byte) format {
+	t := bytes.TrimSpace(b)
+
+	f := format{
+		colon: ":",
+		comma: ",",
+	}
+
+	var colon bool
+	var comma bool
+	var line bool
+	var quoted bool
+	var escaped bool
+	for i, c := range t {
+		if quoted {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				quoted = false
+			}
+
+			continue
+		}
+
+		switch c {
+		case '"':
+			quoted = true
+		case ':':
+			if !colon && i+1 < len(t) && t[i+1] == ' ' {
+				f.colon = ": "
+			}
+			colon = true
+		case ',':
+			if !comma && i+1 < len(t) && t[i+1] == ' ' {
+				f.comma = ", "
+			}
+			comma = true
+		case '\n':
+			if !line {
+				r := t[i+1:]
+				f.indent = string(r[:len(r)-len(bytes.TrimLeft(r, " \t"))])
+			}
+			line = true
+		}
+	}
+
+	if line {
+		l := t[bytes.LastIndexByte(t, '\n')+1:]
+		f.base = string(l[:len(l)-len(bytes.TrimLeft(l, " \t"))])
+		f.indent = strings.TrimPrefix(f.indent, f.base)
+	}
+
+	if (!line && !colon && !comma) || (line && f.indent == "") {
+		f.colon = ": "
+		f.indent = "  "
+	}
+
+	return f
+}
+
+// delimiter returns what goes between two members of a collection, apart
+// from the line break of indented documents.
+func (f format) delimiter() string {
+	if f.indent == "" {
+		return f.comma
+	}
+
+	return ","
+}
+
+// key returns the given key as written in the given format.
+func (f format) key(k string) (string, error) {
+	if f.identifiers && k != "" && strings.IndexFunc(k, func(c rune) bool { return !isJSON5Identifier(c, false) }) == -1 && isJSON5Identifier([]rune(k)[0], true) {
+		return k, nil
+	}
+
+	return marshal(k)
+}
+
+// separator writes what goes between the brackets of a collection and its
+// members, or between two members, at the given depth.
+func (f format) separator(b *strings.Builder, depth int) {
+	if f.indent == "" {
+		return
+	}
+
+	b.WriteString("\n")
+	b.WriteString(f.base)
+	b.WriteString(strings.Repeat(f.indent, depth))
+}
+
+func (f format) write(b *strings.Builder, n *yaml.Node, depth int) error {
+	n = resolve(n)
+
+	switch n.Kind {
+	case yaml.DocumentNode:
+		return f.write(b, n.Content[0], depth)
+	case yaml.MappingNode:
+		l := pairs(n)
+		if len(l) == 0 {
+			b.WriteString("{}")
+			return nil
+		}
+
+		b.WriteString("{")
+		for i, c := range l {
+			if i != 0 {
+				b.WriteString(f.delimiter())
+			}
+			f.separator(b, depth+1)
+
+			k, err := f.key(c[0].Value)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+
+			b.WriteString(k)
+			b.WriteString(f.colon)
+
+			err = f.write(b, c[1], depth+1)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+		f.separator(b, depth)
+		b.WriteString("}")
+
+		return nil
+	case yaml.SequenceNode:
+		if len(n.Content) == 0 {
+			b.WriteString("[]")
+			return nil
+		}
+
+		b.WriteString("[")
+		for i, c := range n.Content {
+			if i != 0 {
+				b.WriteString(f.delimiter())
+			}
+			f.separator(b, depth+1)
+
+			err := f.write(b, c, depth+1)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+		f.separator(b, depth)
+		b.WriteString("]")
+
+		return nil
+	}
+
+	v, err := toInterface(n)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	s, err := marshal(v)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	b.WriteString(s)
+
+	return nil
+}
+
+// marshal returns the JSON representation of the given value without escaping
+// HTML characters, which json.Marshal would do.
+func marshal(v interface{}) (string, error) {
+	var b bytes.Buffer
+
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+
+	err := e.Encode(v)
+	if err != nil {
+		return "", tracer.Mask(err)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
@@ -0,0 +1,442 @@
+package path
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/xh3b4sd/tracer"
+	"github.com/zclconf/go-cty/cty"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// hclExpressionTag is the tag of HCL expressions which cannot be
+	// evaluated without context, like var.region or file("x"). Their values
+	// are their source text, which is written back as given.
+	hclExpressionTag = "!hcl/expression"
+)
+
+// hclDocument describes where the attributes and blocks of an HCL document
+// are written. Modifications are written using the native HCL writer, which
+// keeps the comments and formatting of all attributes and blocks which are
+// not modified. Attributes are written again as a whole when values within
+// them are modified.
+type hclDocument struct {
+	// blocks are the blocks the given bodies are read from.
+	blocks map[*yaml.Node]hclBlock
+	// bodies are the bodies of the given mappings, which are the root body
+	// and the bodies of blocks.
+	bodies map[*yaml.Node]*hclwrite.Body
+	// dirty are the attributes which have to be written again, in the order
+	// they were modified.
+	dirty []hclAttribute
+	file  *hclwrite.File
+	// indents are the columns the attributes of the given bodies are
+	// written at.
+	indents map[*yaml.Node]int
+	// owners are the attributes the given values are written in.
+	owners map[*yaml.Node]hclAttribute
+}
+
+// hclAttribute is an attribute of the body of the given mapping.
+type hclAttribute struct {
+	body *yaml.Node
+	name string
+}
+
+// hclBlock is a block within the given parent body.
+type hclBlock struct {
+	block  *hclwrite.Block
+	parent *hclwrite.Body
+}
+
+// parseHCL returns the given HCL document as YAML node tree, together with
+// the description of where its attributes and blocks are written. Blocks are
+// objects under their type and labels, e.g. resource.aws_instance.web.
+func parseHCL(b []byte) (*yaml.Node, *hclDocument, error) {
+	s, diags := hclsyntax.ParseConfig(b, "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, nil, tracer.Maskf(invalidFormatError, "hcl: %s", diags.Error())
+	}
+
+	f, diags := hclwrite.ParseConfig(b, "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, nil, tracer.Maskf(invalidFormatError, "hcl: %s", diags.Error())
+	}
+
+	h := &hclDocument{
+		blocks:  map[*yaml.Node]hclBlock{},
+		bodies:  map[*yaml.Node]*hclwrite.Body{},
+		file:    f,
+		indents: map[*yaml.Node]int{},
+		owners:  map[*yaml.Node]hclAttribute{},
+	}
+
+	n := &yaml.Node{
+		Kind:    yaml.DocumentNode,
+		Content: []*yaml.Node{h.body(b, s.Body.(*hclsyntax.Body), f.Body(), 0)},
+	}
+
+	return n, h, nil
+}
+
+// body returns the given body as mapping of its attributes and blocks, in the
+// order they are written in. Attributes added to empty bodies are written at
+// the given indentation.
+func (h *hclDocument) body(b []byte, s *hclsyntax.Body, w *hclwrite.Body, indent int) *yaml.Node {
+	m := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	h.bodies[m] = w
+	h.indents[m] = indent
+
+	var attributes []*hclsyntax.Attribute
+	for _, a := range s.Attributes {
+		attributes = append(attributes, a)
+	}
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].SrcRange.Start.Byte < attributes[j].SrcRange.Start.Byte })
+
+	blocks := w.Blocks()
+
+	var i, j int
+	for i < len(attributes) || j < len(s.Blocks) {
+		if j >= len(s.Blocks) || (i < len(attributes) && attributes[i].SrcRange.Start.Byte < s.Blocks[j].TypeRange.Start.Byte) {
+			a := attributes[i]
+			i++
+
+			if len(m.Content) == 0 {
+				h.indents[m] = a.SrcRange.Start.Column - 1
+			}
+
+			m.Content = append(m.Content, hclKeyNode(a.Name), h.expression(b, a.Expr, hclAttribute{body: m, name: a.Name}))
+			continue
+		}
+
+		sb := s.Blocks[j]
+		wb := blocks[j]
+		j++
+
+		if len(m.Content) == 0 {
+			h.indents[m] = sb.TypeRange.Start.Column - 1
+		}
+
+		v := h.body(b, sb.Body, wb.Body(), h.indents[m]+2)
+		h.blocks[v] = hclBlock{block: wb, parent: w}
+
+		// Blocks are nested under their type and labels. Blocks having the
+		// same type and labels are lists.
+		c := m
+		keys := append([]string{sb.Type}, sb.Labels...)
+		for _, k := range keys[:len(keys)-1] {
+			l := lookup(c, k)
+			if l == nil {
+				l = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				c.Content = append(c.Content, hclKeyNode(k), l)
+			}
+
+			c = l
+		}
+
+		k := keys[len(keys)-1]
+		switch l := lookup(c, k); {
+		case l == nil:
+			c.Content = append(c.Content, hclKeyNode(k), v)
+		case l.Kind == yaml.SequenceNode:
+			l.Content = append(l.Content, v)
+		default:
+			c := h.moved(l)
+			*l = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{c, v}}
+		}
+	}
+
+	return m
+}
+
+// expression returns the value of the given expression written in the given
+// attribute. Objects and tuples are read item by item, so that the items keep
+// their order. Expressions which cannot be evaluated without context are
+// strings of their source text.
+func (h *hclDocument) expression(b []byte, e hclsyntax.Expression, a hclAttribute) *yaml.Node {
+	var n *yaml.Node
+
+	switch e := e.(type) {
+	case *hclsyntax.ObjectConsExpr:
+		n = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: yaml.FlowStyle}
+		for _, i := range e.Items {
+			k := hcl.ExprAsKeyword(i.KeyExpr)
+			if k == "" {
+				v, diags := i.KeyExpr.Value(nil)
+				if diags.HasErrors() || !v.IsWhollyKnown() || v.IsNull() || v.Type() != cty.String {
+					k = string(i.KeyExpr.Range().SliceBytes(b))
+				} else {
+					k = v.AsString()
+				}
+			}
+
+			n.Content = append(n.Content, hclKeyNode(k), h.expression(b, i.ValueExpr, a))
+		}
+	case *hclsyntax.TupleConsExpr:
+		n = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+		for _, i := range e.Exprs {
+			n.Content = append(n.Content, h.expression(b, i, a))
+		}
+	default:
+		v, diags := e.Value(nil)
+		if diags.HasErrors() || !v.IsWhollyKnown() {
+			n = &yaml.Node{Kind: yaml.ScalarNode, Tag: hclExpressionTag, Value: string(e.Range().SliceBytes(b))}
+		} else {
+			n = hclValueNode(v)
+		}
+	}
+
+	h.own(n, a)
+
+	return n
+}
+
+// moved returns a copy of the given block body, which is moved into a list of
+// blocks.
+func (h *hclDocument) moved(n *yaml.Node) *yaml.Node {
+	c := &yaml.Node{}
+	*c = *n
+
+	h.blocks[c] = h.blocks[n]
+	h.bodies[c] = h.bodies[n]
+	h.indents[c] = h.indents[n]
+
+	for k, a := range h.owners {
+		if a.body == n {
+			h.owners[k] = hclAttribute{body: c, name: a.name}
+		}
+	}
+
+	delete(h.blocks, n)
+	delete(h.bodies, n)
+	delete(h.indents, n)
+
+	return c
+}
+
+// modified registers the given attribute as one which has to be written
+// again.
+func (h *hclDocument) modified(a hclAttribute) {
+	for _, d := range h.dirty {
+		if d == a {
+			return
+		}
+	}
+
+	h.dirty = append(h.dirty, a)
+}
+
+// own registers the given value and all values within it as written in the
+// given attribute.
+func (h *hclDocument) own(n *yaml.Node, a hclAttribute) {
+	h.owners[n] = a
+	for _, c := range n.Content {
+		h.own(c, a)
+	}
+}
+
+// addHCL marks the attribute the given list is written in as modified.
+func (p *Path) addHCL(n *yaml.Node, value *yaml.Node) error {
+	a, ok := p.hcl.owners[n]
+	if !ok {
+		return tracer.Maskf(invalidFormatError, "HCL blocks cannot be created")
+	}
+
+	p.hcl.own(value, a)
+	p.hcl.modified(a)
+
+	return nil
+}
+
+// insertHCL writes the given key and value added to the given mapping of an
+// HCL document. Keys added to bodies are attributes.
+func (p *Path) insertHCL(m *yaml.Node, key *yaml.Node, value *yaml.Node) error {
+	a, ok := p.hcl.owners[m]
+	if _, body := p.hcl.bodies[m]; body {
+		a, ok = hclAttribute{body: m, name: key.Value}, true
+	}
+	if !ok {
+		return tracer.Maskf(invalidFormatError, "HCL blocks cannot be created")
+	}
+
+	p.hcl.own(value, a)
+	p.hcl.modified(a)
+
+	return nil
+}
+
+// removeHCL removes the given value removed from the given collection of an
+// HCL document. Values which are not written in attributes are blocks, or the
+// types and labels of blocks, which have all their blocks removed. Attributes
+// are removed, or written again if the value was removed from within them.
+func (p *Path) removeHCL(n *yaml.Node, removed *yaml.Node) error {
+	a, ok := p.hcl.owners[removed]
+	if !ok {
+		var remove func(n *yaml.Node)
+		remove = func(n *yaml.Node) {
+			if b, ok := p.hcl.blocks[n]; ok {
+				b.parent.RemoveBlock(b.block)
+				return
+			}
+
+			for _, c := range n.Content {
+				remove(c)
+			}
+		}
+
+		remove(removed)
+
+		return nil
+	}
+
+	p.hcl.modified(a)
+
+	return nil
+}
+
+// replaceHCL marks the attribute the given value is written in as modified.
+func (p *Path) replaceHCL(n *yaml.Node, value *yaml.Node) error {
+	a, ok := p.hcl.owners[n]
+	if !ok {
+		return tracer.Maskf(invalidFormatError, "HCL blocks cannot be modified as a whole")
+	}
+
+	p.hcl.own(value, a)
+	p.hcl.modified(a)
+
+	return nil
+}
+
+// writeHCL returns the bytes of the HCL document, having all modified
+// attributes written again. Only the modified attributes are formatted, so
+// that all other attributes keep their alignment.
+func (p *Path) writeHCL() []byte {
+	for _, a := range p.hcl.dirty {
+		b := p.hcl.bodies[a.body]
+
+		v := lookup(a.body, a.name)
+		if v == nil {
+			b.RemoveAttribute(a.name)
+			continue
+		}
+
+		i := p.hcl.indents[a.body]
+		e := hclwrite.Tokens{{Type: hclsyntax.TokenIdent, Bytes: hclExpression(v, i), SpacesBefore: 1}}
+
+		if b.GetAttribute(a.name) != nil {
+			b.SetAttributeRaw(a.name, e)
+			continue
+		}
+
+		// Added attributes are written without any spaces by the native HCL
+		// writer, which formats the whole document otherwise.
+		b.SetAttributeRaw(a.name, e)
+		t := b.GetAttribute(a.name).BuildTokens(nil)
+		t[0].SpacesBefore = i
+		t[1].SpacesBefore = 1
+	}
+
+	return p.hcl.file.BuildTokens(nil).Bytes()
+}
+
+// hclExpression returns the given value written as formatted HCL expression,
+// having all lines after the first indented by the given number of spaces.
+func hclExpression(n *yaml.Node, indent int) []byte {
+	f := hclwrite.NewEmptyFile()
+	f.Body().SetAttributeRaw("x", hclTokens(n))
+
+	b := bytes.TrimSuffix(bytes.TrimPrefix(f.Bytes(), []byte("x = ")), []byte("\n"))
+
+	return bytes.ReplaceAll(b, []byte("\n"), []byte("\n"+spaces(indent)))
+}
+
+func hclKeyNode(k string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}
+}
+
+// hclTokens returns the given value written as HCL expression.
+func hclTokens(n *yaml.Node) hclwrite.Tokens {
+	n = resolve(n)
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		var l []hclwrite.ObjectAttrTokens
+		for _, c := range pairs(n) {
+			k := hclwrite.TokensForValue(cty.StringVal(c[0].Value))
+			if hclsyntax.ValidIdentifier(c[0].Value) && !strings.Contains(c[0].Value, "-") {
+				k = hclwrite.TokensForIdentifier(c[0].Value)
+			}
+
+			l = append(l, hclwrite.ObjectAttrTokens{Name: k, Value: hclTokens(c[1])})
+		}
+
+		return hclwrite.TokensForObject(l)
+	case yaml.SequenceNode:
+		var l []hclwrite.Tokens
+		for _, c := range n.Content {
+			l = append(l, hclTokens(c))
+		}
+
+		return hclwrite.TokensForTuple(l)
+	}
+
+	switch n.Tag {
+	case hclExpressionTag:
+		return hclwrite.Tokens{{Type: hclsyntax.TokenIdent, Bytes: []byte(n.Value)}}
+	case "!!bool":
+		v, err := strconv.ParseBool(n.Value)
+		if err == nil {
+			return hclwrite.TokensForValue(cty.BoolVal(v))
+		}
+	case "!!float", "!!int":
+		return hclwrite.Tokens{{Type: hclsyntax.TokenNumberLit, Bytes: []byte(n.Value)}}
+	case "!!null":
+		return hclwrite.TokensForValue(cty.NullVal(cty.DynamicPseudoType))
+	}
+
+	return hclwrite.TokensForValue(cty.StringVal(n.Value))
+}
+
+// hclValueNode returns the given value of an evaluated HCL expression as YAML
+// node.
+func hclValueNode(v cty.Value) *yaml.Node {
+	t := v.Type()
+
+	switch {
+	case v.IsNull():
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case t == cty.String:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v.AsString()}
+	case t == cty.Bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v.True())}
+	case t == cty.Number:
+		f := v.AsBigFloat()
+		if f.IsInt() {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: f.Text('f', -1)}
+		}
+
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: f.Text('g', -1)}
+	case t.IsObjectType() || t.IsMapType():
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: yaml.FlowStyle}
+		for it := v.ElementIterator(); it.Next(); {
+			k, e := it.Element()
+			n.Content = append(n.Content, hclKeyNode(k.AsString()), hclValueNode(e))
+		}
+
+		return n
+	}
+
+	n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+	for it := v.ElementIterator(); it.Next(); {
+		_, e := it.Element()
+		n.Content = append(n.Content, hclValueNode(e))
+	}
+
+	return n
+}
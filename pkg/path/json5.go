@@ -0,0 +1,760 @@
+package path
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	json5NumberExpression = regexp.MustCompile(`^[+-]?(?:Infinity|NaN|0[xX][0-9a-fA-F]+|(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][+-]?[0-9]+)?)`)
+)
+
+// json5 describes where the values of a JSON5 or JSON document are written, so
+// that modifications can be spliced into the original bytes, keeping all
+// comments and the format of all values not modified.
+type json5 struct {
+	// commas are the offsets of the commas following the given values within
+	// their objects and arrays.
+	commas map[*yaml.Node]int
+	// format is the format new values are written in, as detected from the
+	// document without its comments.
+	format format
+	// spans are the byte ranges of the given values, including the quotes of
+	// strings and the brackets of objects and arrays.
+	spans map[*yaml.Node]span
+	// starts are the offsets the members of objects and arrays having the
+	// given values start at, which are the offsets of the keys of objects.
+	starts map[*yaml.Node]int
+}
+
+// json5Parser reads JSON5 documents as specified in JSON5 v1.0.0, which covers
+// JSON documents with comments and trailing commas as well.
+type json5Parser struct {
+	b []byte
+	o int
+
+	// identifiers is true if the first key of the document is written without
+	// quotes.
+	identifiers *bool
+	json5       *json5
+}
+
+// parseJSON5 returns the given JSON5 document as YAML node tree, together
+// with the description of where its values are written. Hexadecimal numbers,
+// Infinity and NaN are numbers like YAML defines them.
+func parseJSON5(b []byte) (*yaml.Node, *json5, error) {
+	r := &json5Parser{
+		b: b,
+
+		json5: &json5{
+			commas: map[*yaml.Node]int{},
+			spans:  map[*yaml.Node]span{},
+			starts: map[*yaml.Node]int{},
+		},
+	}
+
+	err := r.space()
+	if err != nil {
+		return nil, nil, tracer.Mask(err)
+	}
+
+	v, err := r.value()
+	if err != nil {
+		return nil, nil, tracer.Mask(err)
+	}
+
+	err = r.space()
+	if err != nil {
+		return nil, nil, tracer.Mask(err)
+	}
+
+	if r.o < len(r.b) {
+		return nil, nil, r.fail("expected the end of the document")
+	}
+
+	r.json5.format = newFormat(stripJSON5(b))
+	r.json5.format.identifiers = r.identifiers != nil && *r.identifiers
+
+	n := &yaml.Node{
+		Kind:    yaml.DocumentNode,
+		Content: []*yaml.Node{v},
+	}
+
+	return n, r.json5, nil
+}
+
+func (r *json5Parser) array() (*yaml.Node, error) {
+	n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+
+	err := r.members(']', func() error {
+		s := r.o
+
+		v, err := r.value()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		r.json5.starts[v] = s
+		n.Content = append(n.Content, v)
+
+		return nil
+	})
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return n, nil
+}
+
+func (r *json5Parser) fail(reason string) error {
+	l := bytes.Count(r.b[:r.o], []byte("\n")) + 1
+	return tracer.Maskf(invalidFormatError, "json5: %s at line %d", reason, l)
+}
+
+// identifier reads an unquoted key, which must be an ECMAScript identifier
+// name.
+func (r *json5Parser) identifier() (string, error) {
+	s := r.o
+	for r.o < len(r.b) {
+		c, w := utf8.DecodeRune(r.b[r.o:])
+		if !isJSON5Identifier(c, r.o == s) {
+			break
+		}
+
+		r.o += w
+	}
+
+	if r.o == s {
+		return "", r.fail("expected a key")
+	}
+
+	return string(r.b[s:r.o]), nil
+}
+
+// members reads the comma separated members of an object or array, calling
+// the given function for each of them, up to the given closing bracket. The
+// last member may be followed by a comma.
+func (r *json5Parser) members(end byte, member func() error) error {
+	r.o++
+
+	for {
+		err := r.space()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		if r.o < len(r.b) && r.b[r.o] == end {
+			r.o++
+			return nil
+		}
+
+		err = member()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		err = r.space()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		switch {
+		case r.o < len(r.b) && r.b[r.o] == ',':
+			r.o++
+		case r.o < len(r.b) && r.b[r.o] == end:
+			r.o++
+			return nil
+		default:
+			return r.fail("expected a comma or " + string(end))
+		}
+	}
+}
+
+func (r *json5Parser) number() (*yaml.Node, error) {
+	m := json5NumberExpression.Find(r.b[r.o:])
+	if m == nil {
+		return nil, r.fail("expected a value")
+	}
+
+	r.o += len(m)
+
+	s := strings.TrimPrefix(string(m), "+")
+	u := strings.TrimPrefix(s, "-")
+	sign := s[:len(s)-len(u)]
+
+	n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: s}
+	switch {
+	case u == "Infinity":
+		n.Tag, n.Value = "!!float", sign+".inf"
+	case u == "NaN":
+		n.Tag, n.Value = "!!float", ".nan"
+	case strings.HasPrefix(u, "0x") || strings.HasPrefix(u, "0X"):
+		i, err := strconv.ParseUint(u[2:], 16, 64)
+		if err != nil {
+			return nil, r.fail("expected a hexadecimal number")
+		}
+
+		n.Value = sign + strconv.FormatUint(i, 10)
+	case strings.ContainsAny(u, ".eE"):
+		if strings.HasPrefix(u, ".") {
+			u = "0" + u
+		}
+		if strings.HasSuffix(u, ".") {
+			u += "0"
+		}
+
+		n.Tag, n.Value = "!!float", sign+strings.Replace(u, ".e", ".0e", 1)
+	}
+
+	return n, nil
+}
+
+func (r *json5Parser) object() (*yaml.Node, error) {
+	n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: yaml.FlowStyle}
+
+	err := r.members('}', func() error {
+		s := r.o
+
+		var k string
+		var err error
+		if r.b[r.o] == '"' || r.b[r.o] == '\'' {
+			k, err = r.string()
+		} else {
+			k, err = r.identifier()
+		}
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		if r.identifiers == nil {
+			i := r.b[s] != '"' && r.b[s] != '\''
+			r.identifiers = &i
+		}
+
+		err = r.space()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		if r.o >= len(r.b) || r.b[r.o] != ':' {
+			return r.fail("expected a colon")
+		}
+		r.o++
+
+		err = r.space()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		v, err := r.value()
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		r.json5.starts[v] = s
+		n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Style: yaml.DoubleQuotedStyle, Value: k}, v)
+
+		return nil
+	})
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return n, nil
+}
+
+func (r *json5Parser) peek(s string) bool {
+	return bytes.HasPrefix(r.b[r.o:], []byte(s))
+}
+
+// space skips whitespace and comments.
+func (r *json5Parser) space() error {
+	for r.o < len(r.b) {
+		switch {
+		case strings.IndexByte(" \t\r\n\f\v", r.b[r.o]) != -1:
+			r.o++
+		case r.peek("\ufeff") || r.peek("\u00a0") || r.peek("\u2028") || r.peek("\u2029"):
+			_, w := utf8.DecodeRune(r.b[r.o:])
+			r.o += w
+		case r.peek("//"):
+			r.o = nextLine(r.b, r.o)
+		case r.peek("/*"):
+			i := bytes.Index(r.b[r.o+2:], []byte("*/"))
+			if i == -1 {
+				return r.fail("expected the end of the comment")
+			}
+
+			r.o += i + 4
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// string reads a single or double quoted string, resolving its escape
+// sequences.
+func (r *json5Parser) string() (string, error) {
+	q := r.b[r.o]
+	r.o++
+
+	var b strings.Builder
+	for {
+		if r.o >= len(r.b) || r.b[r.o] == '\n' || r.b[r.o] == '\r' {
+			return "", r.fail("expected the end of the string")
+		}
+
+		c := r.b[r.o]
+		switch {
+		case c == q:
+			r.o++
+			return b.String(), nil
+		case c != '\\':
+			b.WriteByte(c)
+			r.o++
+			continue
+		}
+
+		if r.o+1 >= len(r.b) {
+			return "", r.fail("expected an escape sequence")
+		}
+
+		e := r.b[r.o+1]
+		r.o += 2
+
+		switch e {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '0':
+			b.WriteByte(0)
+		case 'x', 'u':
+			w := 2
+			if e == 'u' {
+				w = 4
+			}
+			if r.o+w > len(r.b) {
+				return "", r.fail("expected a hexadecimal escape sequence")
+			}
+
+			i, err := strconv.ParseUint(string(r.b[r.o:r.o+w]), 16, 32)
+			if err != nil {
+				return "", r.fail("expected a hexadecimal escape sequence")
+			}
+			r.o += w
+
+			c := rune(i)
+			if utf16.IsSurrogate(c) && r.peek(`\u`) && r.o+6 <= len(r.b) {
+				j, err := strconv.ParseUint(string(r.b[r.o+2:r.o+6]), 16, 32)
+				if err == nil {
+					c = utf16.DecodeRune(c, rune(j))
+					r.o += 6
+				}
+			}
+
+			b.WriteRune(c)
+		case '\n':
+		case '\r':
+			if r.peek("\n") {
+				r.o++
+			}
+		default:
+			// Escaped line separators continue the string on the next line.
+			// All other escaped characters are the characters themselves.
+			r.o--
+			c, w := utf8.DecodeRune(r.b[r.o:])
+			r.o += w
+			if c != '\u2028' && c != '\u2029' {
+				b.WriteRune(c)
+			}
+		}
+	}
+}
+
+func (r *json5Parser) value() (*yaml.Node, error) {
+	if r.o >= len(r.b) {
+		return nil, r.fail("expected a value")
+	}
+
+	s := r.o
+
+	var n *yaml.Node
+	var err error
+	switch {
+	case r.b[r.o] == '{':
+		n, err = r.object()
+	case r.b[r.o] == '[':
+		n, err = r.array()
+	case r.b[r.o] == '"' || r.b[r.o] == '\'':
+		var v string
+		v, err = r.string()
+		n = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Style: yaml.DoubleQuotedStyle, Value: v}
+	case r.peek("true") || r.peek("false"):
+		n = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(r.peek("true"))}
+		r.o += len(n.Value)
+	case r.peek("null"):
+		n = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+		r.o += len(n.Value)
+	default:
+		n, err = r.number()
+	}
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	if n.Kind == yaml.ScalarNode && r.o < len(r.b) {
+		c, _ := utf8.DecodeRune(r.b[r.o:])
+		if isJSON5Identifier(c, false) {
+			return nil, r.fail("expected a value")
+		}
+	}
+
+	r.json5.spans[n] = span{start: s, end: r.o}
+
+	err = r.space()
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	if r.o < len(r.b) && r.b[r.o] == ',' {
+		r.json5.commas[n] = r.o
+	}
+
+	return n, nil
+}
+
+// addJSON5 writes the given value added to the given array of a JSON5
+// document at index i.
+func (p *Path) addJSON5(n *yaml.Node, i int, value *yaml.Node) error {
+	if i == len(n.Content)-1 {
+		return p.appendJSON5(n, n.Content[:i], func(indent string) (string, error) {
+			return p.renderJSON5(value, indent)
+		})
+	}
+
+	o := p.json5.starts[n.Content[i+1]]
+	indent := lineIndent(p.bytes, o)
+
+	v, err := p.renderJSON5(value, indent)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	t := v + p.json5.inline()
+	if ownLine(p.bytes, o) {
+		t = v + ",\n" + indent
+	}
+
+	p.edits = append(p.edits, edit{start: o, end: o, text: t})
+
+	return nil
+}
+
+// appendJSON5 writes the member rendered by the given function after the
+// given existing members of the given object or array. Members of objects and
+// arrays written across lines are written on their own line, at the
+// indentation of the last member. Trailing commas are kept.
+func (p *Path) appendJSON5(n *yaml.Node, members []*yaml.Node, render func(indent string) (string, error)) error {
+	b := p.bytes
+	s, ok := p.json5.spans[n]
+	if !ok {
+		return tracer.Maskf(invalidFormatError, "JSON5 values must be added to existing objects and arrays")
+	}
+
+	var values []*yaml.Node
+	for i, c := range members {
+		if n.Kind == yaml.SequenceNode || i%2 == 1 {
+			values = append(values, c)
+		}
+	}
+
+	if len(values) == 0 {
+		o := s.start + 1
+		e := edit{start: o, end: o}
+		if len(bytes.TrimSpace(b[o:s.end-1])) == 0 {
+			e.end = s.end - 1
+		}
+
+		indent := lineIndent(b, s.start)
+		if p.json5.format.indent != "" {
+			indent += p.json5.format.indent
+		}
+
+		v, err := render(indent)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		e.text = v
+		if p.json5.format.indent != "" {
+			e.text = "\n" + indent + v + "\n" + lineIndent(b, s.start)
+		}
+
+		p.edits = append(p.edits, e)
+
+		return nil
+	}
+
+	last := values[len(values)-1]
+	start := p.json5.starts[last]
+	end := p.json5.spans[last].end
+	comma, trailing := p.json5.commas[last]
+
+	indent := lineIndent(b, start)
+
+	v, err := render(indent)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	var e edit
+	switch {
+	case ownLine(b, start) && trailing:
+		e.start = restOfLine(b, comma+1)
+		e.text = "\n" + indent + v + ","
+	case ownLine(b, start):
+		e.start = end
+		e.text = ",\n" + indent + v
+		if o := restOfLine(b, end); o != end {
+			p.edits = append(p.edits, edit{start: end, end: end, text: ","})
+			e.start = o
+			e.text = "\n" + indent + v
+		}
+	case trailing:
+		e.start = comma + 1
+		e.text = strings.TrimPrefix(p.json5.inline(), ",") + v + ","
+	default:
+		e.start = end
+		e.text = p.json5.inline() + v
+	}
+	e.end = e.start
+
+	p.edits = append(p.edits, e)
+
+	return nil
+}
+
+// insertJSON5 writes the given key and value added to the given object of a
+// JSON5 document.
+func (p *Path) insertJSON5(m *yaml.Node, key *yaml.Node, value *yaml.Node) error {
+	return p.appendJSON5(m, m.Content[:len(m.Content)-2], func(indent string) (string, error) {
+		k, err := p.json5.format.key(key.Value)
+		if err != nil {
+			return "", tracer.Mask(err)
+		}
+
+		v, err := p.renderJSON5(value, indent)
+		if err != nil {
+			return "", tracer.Mask(err)
+		}
+
+		return k + p.json5.format.colon + v, nil
+	})
+}
+
+// removeJSON5 removes the given value removed from the given object or array
+// of a JSON5 document, together with its key and comma. Members written on
+// their own line are removed together with their line and the comment at the
+// end of it.
+func (p *Path) removeJSON5(n *yaml.Node, removed *yaml.Node) error {
+	b := p.bytes
+
+	start := p.json5.starts[removed]
+	end := p.json5.spans[removed].end
+	comma, trailing := p.json5.commas[removed]
+
+	var previous *yaml.Node
+	var next *yaml.Node
+	for i, c := range n.Content {
+		if n.Kind == yaml.MappingNode && i%2 == 0 {
+			continue
+		}
+
+		o := p.json5.starts[c]
+		if o < start && (previous == nil || o > p.json5.starts[previous]) {
+			previous = c
+		}
+		if o > start && (next == nil || o < p.json5.starts[next]) {
+			next = c
+		}
+	}
+
+	stop := end
+	if trailing {
+		stop = comma + 1
+	}
+
+	switch {
+	case previous == nil && next == nil:
+		s := p.json5.spans[n]
+		p.edits = append(p.edits, edit{start: s.start + 1, end: s.end - 1})
+	case ownLine(b, start) && restOfLine(b, stop) == lineEnd(b, stop):
+		e := edit{start: lineStart(b, start), end: lineEnd(b, stop)}
+		if e.end < len(b) {
+			e.end++
+		}
+
+		p.edits = append(p.edits, e)
+
+		if c, ok := p.json5.commas[previous]; ok && next == nil && !trailing {
+			p.edits = append(p.edits, edit{start: c, end: c + 1})
+		}
+	case next != nil:
+		p.edits = append(p.edits, edit{start: start, end: p.json5.starts[next]})
+	default:
+		p.edits = append(p.edits, edit{start: p.json5.spans[previous].end, end: end})
+	}
+
+	return nil
+}
+
+// renderJSON5 returns the given value written in the format of the JSON5
+// document, having all lines after the first indented by the given indent.
+func (p *Path) renderJSON5(n *yaml.Node, indent string) (string, error) {
+	f := p.json5.format
+	f.base = indent
+
+	var b strings.Builder
+	err := f.write(&b, n, 0)
+	if err != nil {
+		return "", tracer.Mask(err)
+	}
+
+	return b.String(), nil
+}
+
+// replaceJSON5 writes the given value replacing the given value of a JSON5
+// document.
+func (p *Path) replaceJSON5(n *yaml.Node, value *yaml.Node) error {
+	s, ok := p.json5.spans[n]
+	if !ok {
+		return tracer.Maskf(invalidFormatError, "JSON5 values must be replaced within the document")
+	}
+
+	v, err := p.renderJSON5(value, lineIndent(p.bytes, s.start))
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	p.edits = append(p.edits, edit{start: s.start, end: s.end, text: v})
+
+	return nil
+}
+
+// inline returns what goes between two members written on the same line.
+func (j *json5) inline() string {
+	if j.format.indent == "" {
+		return j.format.comma
+	}
+
+	return ", "
+}
+
+// isJSON5Identifier returns whether the given character may be part of an
+// unquoted key, being its first character if first is set.
+func isJSON5Identifier(c rune, first bool) bool {
+	switch {
+	case c == '_' || c == '$' || unicode.IsLetter(c):
+		return true
+	case first:
+		return false
+	}
+
+	return unicode.IsDigit(c) || unicode.In(c, unicode.Mn, unicode.Mc, unicode.Pc) || c == '\u200c' || c == '\u200d'
+}
+
+// lineEnd returns the offset of the line break ending the line of the given
+// offset, or the length of the given bytes.
+func lineEnd(b []byte, o int) int {
+	i := bytes.IndexByte(b[o:], '\n')
+	if i == -1 {
+		return len(b)
+	}
+
+	return o + i
+}
+
+// lineIndent returns the whitespace the line of the given offset starts with.
+func lineIndent(b []byte, o int) string {
+	s := lineStart(b, o)
+	return string(b[s:skip(b, s, " \t")])
+}
+
+// ownLine returns whether only whitespace is written in front of the given
+// offset on its line.
+func ownLine(b []byte, o int) bool {
+	return skip(b, lineStart(b, o), " \t") == o
+}
+
+// restOfLine returns the end of the line of the given offset, if only
+// whitespace and a line comment follow the given offset on its line. The
+// given offset is returned otherwise.
+func restOfLine(b []byte, o int) int {
+	i := skip(b, o, " \t\r")
+	if i == lineEnd(b, o) || bytes.HasPrefix(b[i:], []byte("//")) {
+		return lineEnd(b, o)
+	}
+
+	return o
+}
+
+// stripJSON5 returns the given JSON5 document without its comments and
+// without empty lines, so that its format can be detected.
+func stripJSON5(b []byte) []byte {
+	var c []byte
+
+	var q byte
+	for i := 0; i < len(b); i++ {
+		switch {
+		case q != 0 && b[i] == '\\' && i+1 < len(b):
+			c = append(c, b[i], b[i+1])
+			i++
+			continue
+		case q != 0 && b[i] == q:
+			q = 0
+		case q != 0:
+		case b[i] == '"' || b[i] == '\'':
+			q = b[i]
+		case bytes.HasPrefix(b[i:], []byte("//")):
+			i = lineEnd(b, i) - 1
+			continue
+		case bytes.HasPrefix(b[i:], []byte("/*")):
+			e := bytes.Index(b[i+2:], []byte("*/"))
+			if e == -1 {
+				return c
+			}
+
+			i += e + 3
+			continue
+		}
+
+		c = append(c, b[i])
+	}
+
+	var l [][]byte
+	for _, x := range bytes.Split(c, []byte("\n")) {
+		if len(bytes.TrimSpace(x)) != 0 {
+			l = append(l, x)
+		}
+	}
+
+	return append(bytes.Join(l, []byte("\n")), '\n')
+}
@@ -0,0 +1,768 @@
+package path
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+// query is a JSONPath query as specified in RFC 9535. Queries start either at
+// the root node, $, or at the current node of a filter, @.
+type query struct {
+	relative bool
+	segments []segment
+}
+
+// segment selects nodes from the children of its input nodes, or from all
+// descendants of its input nodes in case of descendant segments like ..name.
+type segment struct {
+	descendant bool
+	selectors  []selector
+}
+
+type selectorKind int
+
+const (
+	nameSelector selectorKind = iota
+	wildcardSelector
+	indexSelector
+	sliceSelector
+	filterSelector
+)
+
+type selector struct {
+	kind selectorKind
+
+	filter expression
+	index  int
+	name   string
+	start  *int
+	end    *int
+	step   int
+}
+
+// singular returns whether the query addresses at most one node, which is the
+// case if it only consists of child segments with a single name or index
+// selector.
+func (q *query) singular() bool {
+	for _, s := range q.segments {
+		if s.descendant || len(s.selectors) != 1 {
+			return false
+		}
+		if s.selectors[0].kind != nameSelector && s.selectors[0].kind != indexSelector {
+			return false
+		}
+	}
+
+	return true
+}
+
+// split returns the path segments of a singular query, where keys containing
+// the given separator are escaped.
+func (q *query) split(separator string) []string {
+	var l []string
+
+	for _, s := range q.segments {
+		switch s.selectors[0].kind {
+		case nameSelector:
+			l = append(l, strings.ReplaceAll(s.selectors[0].name, separator, escapedSeparatorPlaceholder))
+		case indexSelector:
+			l = append(l, fmt.Sprintf("[%d]", s.selectors[0].index))
+		}
+	}
+
+	return l
+}
+
+// normalized returns the normalized JSONPath of the given path segments, as
+// in $['spec']['containers'][0].
+func normalized(split []string, separator string) string {
+	var b strings.Builder
+
+	b.WriteString("$")
+	for _, s := range split {
+		if i, err := indexFromKey(s); err == nil {
+			b.WriteString(fmt.Sprintf("[%d]", i))
+			continue
+		}
+
+		b.WriteString("['")
+		for _, r := range strings.ReplaceAll(s, escapedSeparatorPlaceholder, separator) {
+			switch {
+			case r == '\'' || r == '\\':
+				b.WriteString(`\` + string(r))
+			case r == '\b':
+				b.WriteString(`\b`)
+			case r == '\f':
+				b.WriteString(`\f`)
+			case r == '\n':
+				b.WriteString(`\n`)
+			case r == '\r':
+				b.WriteString(`\r`)
+			case r == '\t':
+				b.WriteString(`\t`)
+			case r < ' ':
+				b.WriteString(fmt.Sprintf(`\u%04x`, r))
+			default:
+				b.WriteRune(r)
+			}
+		}
+		b.WriteString("']")
+	}
+
+	return b.String()
+}
+
+// parser reads JSONPath queries as specified in RFC 9535.
+type parser struct {
+	s string
+	i int
+}
+
+func parseQuery(s string) (*query, error) {
+	r := &parser{s: s}
+
+	if !r.consume("$") {
+		return nil, r.fail("query must start with '$'")
+	}
+
+	q, err := r.query(false)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	if r.i != len(r.s) {
+		return nil, r.fail("unexpected character")
+	}
+
+	return q, nil
+}
+
+// comparable parses a literal, a singular query or a function expression.
+func (r *parser) comparable() (operand, error) {
+	switch {
+	case r.peek('@') || r.peek('$'):
+		rel := r.s[r.i] == '@'
+		r.i++
+
+		q, err := r.query(rel)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		return q, nil
+	case r.peek('\'') || r.peek('"'):
+		s, err := r.string()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		return literal{v: s}, nil
+	case r.consume("true"):
+		return literal{v: true}, nil
+	case r.consume("false"):
+		return literal{v: false}, nil
+	case r.consume("null"):
+		return literal{v: nil}, nil
+	case r.peek('-') || r.digit():
+		n, err := r.number()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		return literal{v: n}, nil
+	case r.lower():
+		f, err := r.function()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		return f, nil
+	}
+
+	return nil, r.fail("expected comparable")
+}
+
+func (r *parser) comparison() string {
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if r.consume(op) {
+			return op
+		}
+	}
+
+	return ""
+}
+
+func (r *parser) consume(s string) bool {
+	if strings.HasPrefix(r.s[r.i:], s) {
+		r.i += len(s)
+		return true
+	}
+
+	return false
+}
+
+func (r *parser) digit() bool {
+	return r.i < len(r.s) && r.s[r.i] >= '0' && r.s[r.i] <= '9'
+}
+
+func (r *parser) fail(reason string) error {
+	return tracer.Maskf(invalidQueryError, "%s at position %d of '%s'", reason, r.i, r.s)
+}
+
+// function parses a function expression like length(@.name).
+func (r *parser) function() (*function, error) {
+	j := r.i
+	for r.i < len(r.s) && (r.lower() || r.digit() || r.s[r.i] == '_') {
+		r.i++
+	}
+
+	f := &function{
+		name: r.s[j:r.i],
+	}
+
+	if !r.consume("(") {
+		return nil, r.fail("expected '('")
+	}
+
+	r.space()
+	for !r.peek(')') {
+		if len(f.args) != 0 {
+			if !r.consume(",") {
+				return nil, r.fail("expected ','")
+			}
+			r.space()
+		}
+
+		a, err := r.comparable()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		f.args = append(f.args, a)
+		r.space()
+	}
+	r.i++
+
+	err := r.validate(f)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return f, nil
+}
+
+// integer parses an integer within the range of I-JSON.
+func (r *parser) integer() (int, error) {
+	j := r.i
+	if r.peek('-') {
+		r.i++
+	}
+	if !r.digit() {
+		return 0, r.fail("expected integer")
+	}
+	if r.s[r.i] == '0' && r.i > j {
+		return 0, r.fail("invalid integer")
+	}
+	if r.s[r.i] == '0' {
+		r.i++
+		return 0, nil
+	}
+	for r.digit() {
+		r.i++
+	}
+
+	i, err := strconv.ParseInt(r.s[j:r.i], 10, 64)
+	if err != nil || i > 1<<53-1 || i < -(1<<53-1) {
+		return 0, r.fail("integer out of range")
+	}
+
+	return int(i), nil
+}
+
+func (r *parser) logical() (expression, error) {
+	var or orExpression
+	for {
+		var and andExpression
+		for {
+			r.space()
+
+			b, err := r.basic()
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+			and = append(and, b)
+
+			r.space()
+			if !r.consume("&&") {
+				break
+			}
+		}
+
+		or = append(or, and)
+
+		if !r.consume("||") {
+			break
+		}
+	}
+
+	return or, nil
+}
+
+// basic parses a parenthesized expression, a comparison or a test
+// expression, each optionally negated.
+func (r *parser) basic() (expression, error) {
+	if r.consume("!") {
+		r.space()
+
+		b, err := r.negatable()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		return notExpression{expression: b}, nil
+	}
+
+	if r.peek('(') {
+		b, err := r.negatable()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		return b, nil
+	}
+
+	left, err := r.comparable()
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	j := r.i
+	r.space()
+
+	op := r.comparison()
+	if op == "" {
+		r.i = j
+		return r.test(left)
+	}
+
+	r.space()
+
+	right, err := r.comparable()
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	for _, o := range []operand{left, right} {
+		if q, ok := o.(*query); ok && !q.singular() {
+			return nil, r.fail("comparison requires singular queries")
+		}
+		if f, ok := o.(*function); ok && f.result() != valueType {
+			return nil, r.fail(fmt.Sprintf("function %s() cannot be compared", f.name))
+		}
+	}
+
+	c := comparison{
+		left:  left,
+		op:    op,
+		right: right,
+	}
+
+	return c, nil
+}
+
+// negatable parses the expressions which may follow the logical not operator.
+func (r *parser) negatable() (expression, error) {
+	if r.consume("(") {
+		l, err := r.logical()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		r.space()
+		if !r.consume(")") {
+			return nil, r.fail("expected ')'")
+		}
+
+		return l, nil
+	}
+
+	o, err := r.comparable()
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return r.test(o)
+}
+
+func (r *parser) lower() bool {
+	return r.i < len(r.s) && r.s[r.i] >= 'a' && r.s[r.i] <= 'z'
+}
+
+// name parses a member name shorthand as in .name.
+func (r *parser) name() (string, error) {
+	j := r.i
+	for r.i < len(r.s) {
+		c, w := utf8.DecodeRuneInString(r.s[r.i:])
+		first := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= 0x80 && c != utf8.RuneError)
+		if !first && !(r.i > j && c >= '0' && c <= '9') {
+			break
+		}
+		r.i += w
+	}
+
+	if r.i == j {
+		return "", r.fail("expected member name")
+	}
+
+	return r.s[j:r.i], nil
+}
+
+func (r *parser) number() (float64, error) {
+	j := r.i
+	if r.peek('-') {
+		r.i++
+	}
+	if !r.digit() {
+		return 0, r.fail("expected number")
+	}
+	if r.s[r.i] == '0' {
+		r.i++
+		if r.digit() {
+			return 0, r.fail("invalid number")
+		}
+	}
+	for r.digit() {
+		r.i++
+	}
+	if r.consume(".") {
+		if !r.digit() {
+			return 0, r.fail("invalid number")
+		}
+		for r.digit() {
+			r.i++
+		}
+	}
+	if r.peek('e') || r.peek('E') {
+		r.i++
+		if r.peek('+') || r.peek('-') {
+			r.i++
+		}
+		if !r.digit() {
+			return 0, r.fail("invalid number")
+		}
+		for r.digit() {
+			r.i++
+		}
+	}
+
+	f, err := strconv.ParseFloat(r.s[j:r.i], 64)
+	if err != nil || math.IsInf(f, 0) {
+		return 0, r.fail("invalid number")
+	}
+
+	return f, nil
+}
+
+func (r *parser) peek(c byte) bool {
+	return r.i < len(r.s) && r.s[r.i] == c
+}
+
+func (r *parser) query(relative bool) (*query, error) {
+	q := &query{
+		relative: relative,
+	}
+
+	for {
+		j := r.i
+		r.space()
+
+		switch {
+		case r.consume(".."):
+			s := segment{descendant: true}
+			switch {
+			case r.peek('['):
+				l, err := r.selectors()
+				if err != nil {
+					return nil, tracer.Mask(err)
+				}
+				s.selectors = l
+			case r.consume("*"):
+				s.selectors = []selector{{kind: wildcardSelector}}
+			default:
+				n, err := r.name()
+				if err != nil {
+					return nil, tracer.Mask(err)
+				}
+				s.selectors = []selector{{kind: nameSelector, name: n}}
+			}
+			q.segments = append(q.segments, s)
+		case r.consume("."):
+			s := segment{}
+			if r.consume("*") {
+				s.selectors = []selector{{kind: wildcardSelector}}
+			} else {
+				n, err := r.name()
+				if err != nil {
+					return nil, tracer.Mask(err)
+				}
+				s.selectors = []selector{{kind: nameSelector, name: n}}
+			}
+			q.segments = append(q.segments, s)
+		case r.peek('['):
+			l, err := r.selectors()
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+			q.segments = append(q.segments, segment{selectors: l})
+		default:
+			r.i = j
+			return q, nil
+		}
+	}
+}
+
+// selector parses a single selector within brackets.
+func (r *parser) selector() (selector, error) {
+	switch {
+	case r.peek('\'') || r.peek('"'):
+		s, err := r.string()
+		if err != nil {
+			return selector{}, tracer.Mask(err)
+		}
+
+		return selector{kind: nameSelector, name: s}, nil
+	case r.consume("*"):
+		return selector{kind: wildcardSelector}, nil
+	case r.consume("?"):
+		r.space()
+
+		l, err := r.logical()
+		if err != nil {
+			return selector{}, tracer.Mask(err)
+		}
+
+		return selector{kind: filterSelector, filter: l}, nil
+	}
+
+	s := selector{
+		kind: sliceSelector,
+		step: 1,
+	}
+
+	if !r.peek(':') {
+		i, err := r.integer()
+		if err != nil {
+			return selector{}, tracer.Mask(err)
+		}
+
+		j := r.i
+		r.space()
+		if !r.peek(':') {
+			r.i = j
+			return selector{kind: indexSelector, index: i}, nil
+		}
+
+		s.start = &i
+	}
+
+	r.i++
+	r.space()
+
+	if r.peek('-') || r.digit() {
+		i, err := r.integer()
+		if err != nil {
+			return selector{}, tracer.Mask(err)
+		}
+		s.end = &i
+		r.space()
+	}
+
+	if r.consume(":") {
+		r.space()
+		if r.peek('-') || r.digit() {
+			i, err := r.integer()
+			if err != nil {
+				return selector{}, tracer.Mask(err)
+			}
+			s.step = i
+		}
+	}
+
+	return s, nil
+}
+
+// selectors parses a bracketed selection like ['a', 0, 1:3].
+func (r *parser) selectors() ([]selector, error) {
+	var l []selector
+
+	r.i++
+	for {
+		r.space()
+
+		s, err := r.selector()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+		l = append(l, s)
+
+		r.space()
+		if r.consume("]") {
+			return l, nil
+		}
+		if !r.consume(",") {
+			return nil, r.fail("expected ',' or ']'")
+		}
+	}
+}
+
+func (r *parser) space() {
+	for r.i < len(r.s) && (r.s[r.i] == ' ' || r.s[r.i] == '\t' || r.s[r.i] == '\n' || r.s[r.i] == '\r') {
+		r.i++
+	}
+}
+
+// string parses a single or double quoted string literal.
+func (r *parser) string() (string, error) {
+	q := r.s[r.i]
+	r.i++
+
+	var b strings.Builder
+	for r.i < len(r.s) {
+		c := r.s[r.i]
+
+		switch {
+		case c == q:
+			r.i++
+			return b.String(), nil
+		case c < ' ':
+			return "", r.fail("invalid character in string")
+		case c != '\\':
+			b.WriteByte(c)
+			r.i++
+			continue
+		}
+
+		r.i++
+		if r.i >= len(r.s) {
+			break
+		}
+
+		e := r.s[r.i]
+		r.i++
+
+		switch e {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '/', '\\':
+			b.WriteByte(e)
+		case 'u':
+			u, err := r.unicode()
+			if err != nil {
+				return "", tracer.Mask(err)
+			}
+			if utf16.IsSurrogate(u) {
+				if !r.consume(`\u`) {
+					return "", r.fail("invalid surrogate pair")
+				}
+				l, err := r.unicode()
+				if err != nil {
+					return "", tracer.Mask(err)
+				}
+				u = utf16.DecodeRune(u, l)
+				if u == utf8.RuneError {
+					return "", r.fail("invalid surrogate pair")
+				}
+			}
+			b.WriteRune(u)
+		default:
+			if e != q {
+				return "", r.fail("invalid escape sequence")
+			}
+			b.WriteByte(e)
+		}
+	}
+
+	return "", r.fail("unterminated string")
+}
+
+// test turns the given operand into a test expression, checking for existing
+// nodes in case of queries.
+func (r *parser) test(o operand) (expression, error) {
+	switch o := o.(type) {
+	case *query:
+		return existence{query: o}, nil
+	case *function:
+		if o.result() == valueType {
+			return nil, r.fail(fmt.Sprintf("result of function %s() must be compared", o.name))
+		}
+		return o, nil
+	}
+
+	return nil, r.fail("literals must be compared")
+}
+
+// validate checks the arguments of the given function expression against the
+// parameters of the function extensions defined in RFC 9535.
+func (r *parser) validate(f *function) error {
+	var params []functionType
+	switch f.name {
+	case "length":
+		params = []functionType{valueType}
+	case "count", "value":
+		params = []functionType{nodesType}
+	case "match", "search":
+		params = []functionType{valueType, valueType}
+	default:
+		return r.fail(fmt.Sprintf("unknown function %s()", f.name))
+	}
+
+	if len(f.args) != len(params) {
+		return r.fail(fmt.Sprintf("function %s() expects %d arguments", f.name, len(params)))
+	}
+
+	for i, a := range f.args {
+		var ok bool
+		switch a := a.(type) {
+		case literal:
+			ok = params[i] == valueType
+		case *query:
+			ok = params[i] == nodesType || a.singular()
+		case *function:
+			ok = params[i] == valueType && a.result() == valueType
+		}
+
+		if !ok {
+			return r.fail(fmt.Sprintf("invalid argument %d of function %s()", i+1, f.name))
+		}
+	}
+
+	return nil
+}
+
+func (r *parser) unicode() (rune, error) {
+	if r.i+4 > len(r.s) {
+		return 0, r.fail("invalid unicode escape")
+	}
+
+	u, err := strconv.ParseUint(r.s[r.i:r.i+4], 16, 32)
+	if err != nil {
+		return 0, r.fail("invalid unicode escape")
+	}
+	r.i += 4
+
+	return rune(u), nil
+}
@@ -0,0 +1,269 @@
+package path
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// wildcard matches any key of an object or any index of a list.
+	wildcard = "*"
+	// recursiveWildcard matches any amount of nested keys and indices,
+	// including none at all.
+	recursiveWildcard = "**"
+)
+
+// Match is a value found under a path expression, together with the concrete
+// path the value was found under.
+type Match struct {
+	Path  string
+	Value interface{}
+}
+
+// IsPattern returns whether the given path expression may match any amount of
+// values, which is the case for paths containing wildcards and for JSONPath
+// queries which are not singular. The values of other paths are found under
+// the given path itself.
+func (p *Path) IsPattern(path string) (bool, error) {
+	_, ok, err := p.single(path)
+	if err != nil {
+		return false, tracer.Mask(err)
+	}
+
+	return !ok, nil
+}
+
+// match is a node found under a path expression, together with the concrete
+// path segments the node was found under.
+type match struct {
+	node  *yaml.Node
+	split []string
+}
+
+// GetAll returns all values found under the given path expression. Path
+// expressions may contain the wildcard segment "*", matching any key or index
+// on a single level, and the recursive wildcard segment "**", matching any
+// amount of levels. Strings containing embedded documents are only entered by
+// segments following the string, not by the recursive wildcard. The concrete
+// paths of JSONPath queries are returned as normalized paths, e.g.
+// $['spec']['replicas'].
+func (p *Path) GetAll(path string) ([]Match, error) {
+	matches, err := p.matches(path)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	var l []Match
+	for _, m := range matches {
+		v, err := toInterface(m.node)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		c := p.join(m.split)
+		if p.queryLanguage == QueryLanguageJSONPath {
+			c = normalized(m.split, p.separator)
+		}
+
+		l = append(l, Match{Path: p.unmask(c), Value: p.unmaskValue(v)})
+	}
+
+	return l, nil
+}
+
+// children returns the key value pairs of the given mapping, or the index item
+// pairs of the given sequence. The keys and indices are returned as concrete
+// path segments.
+func (p *Path) children(n *yaml.Node) ([]string, []*yaml.Node) {
+	var keys []string
+	var values []*yaml.Node
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		for _, c := range pairs(n) {
+			keys = append(keys, p.escapeSeparator(c[0].Value))
+			values = append(values, c[1])
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			keys = append(keys, fmt.Sprintf("[%d]", i))
+			values = append(values, c)
+		}
+	}
+
+	return keys, values
+}
+
+// join returns the path string of the given concrete path segments.
+func (p *Path) join(split []string) string {
+	var l []string
+	for _, s := range split {
+		l = append(l, strings.ReplaceAll(p.unescapeKey(s), p.separator, `\`+p.separator))
+	}
+
+	return strings.Join(l, p.separator)
+}
+
+func (p *Path) matchFromNode(split []string, n *yaml.Node, prefix []string) []match {
+	n = resolve(n)
+
+	if len(split) == 0 {
+		return []match{{node: n, split: prefix}}
+	}
+
+	if isMarker(split[0]) {
+		return p.matchMarked(split, n, prefix)
+	}
+
+	var l []match
+
+	switch split[0] {
+	case recursiveWildcard:
+		l = append(l, p.matchFromNode(split[1:], n, prefix)...)
+
+		keys, values := p.children(n)
+		for i := range keys {
+			l = append(l, p.matchFromNode(split, values[i], with(prefix, keys[i]))...)
+		}
+
+		return unique(l)
+	case wildcard:
+		keys, values := p.children(n)
+		for i := range keys {
+			l = append(l, p.matchFromNode(split[1:], values[i], with(prefix, keys[i]))...)
+		}
+	default:
+		key := p.unescapeKey(split[0])
+		n = p.listed(n, key)
+
+		switch n.Kind {
+		case yaml.MappingNode:
+			v := lookup(n, key)
+			if v != nil {
+				l = append(l, p.matchFromNode(split[1:], v, with(prefix, split[0]))...)
+			}
+
+			return l
+		case yaml.SequenceNode:
+			if pr, ok := predicateFromKey(key); ok {
+				for _, i := range pr.indices(n) {
+					l = append(l, p.matchFromNode(split[1:], n.Content[i], with(prefix, fmt.Sprintf("[%d]", i)))...)
+				}
+
+				return l
+			}
+
+			index, err := indexFromKey(key)
+			if err == nil && index < 0 {
+				index += len(n.Content)
+			}
+			if err == nil && index >= 0 && index < len(n.Content) {
+				l = append(l, p.matchFromNode(split[1:], n.Content[index], with(prefix, fmt.Sprintf("[%d]", index)))...)
+			}
+
+			return l
+		}
+	}
+
+	if n.Kind == yaml.ScalarNode {
+		e, err := p.embedded(n)
+		if err == nil {
+			l = append(l, e.matchFromNode(split, e.root(), prefix)...)
+		}
+	}
+
+	return l
+}
+
+// matches returns all nodes found under the given path expression.
+func (p *Path) matches(path string) ([]match, error) {
+	var l []match
+	if p.queryLanguage == QueryLanguageJSONPath {
+		q, err := parseQuery(path)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		l = p.evaluate(q)
+	} else {
+		l = p.matchFromNode(p.split(path), p.root(), nil)
+	}
+
+	if len(l) == 0 {
+		return nil, tracer.Maskf(notFoundError, "key '%s'", path)
+	}
+
+	return l, nil
+}
+
+// modifiable returns all nodes found under the given path expression, ordered
+// in a way that modifying them one after another does not invalidate the
+// paths of the nodes modified later. Nested nodes come before their parents
+// and list items come in reverse order.
+func (p *Path) modifiable(path string) ([]match, error) {
+	l, err := p.matches(path)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	l = unique(l)
+
+	sort.SliceStable(l, func(i, j int) bool {
+		a := l[i].split
+		b := l[j].split
+
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] == b[k] {
+				continue
+			}
+
+			x, errA := indexFromKey(a[k])
+			y, errB := indexFromKey(b[k])
+			if errA == nil && errB == nil {
+				return x > y
+			}
+
+			return a[k] > b[k]
+		}
+
+		return len(a) > len(b)
+	})
+
+	return l, nil
+}
+
+// isPattern returns whether the given path segments contain wildcards.
+func isPattern(split []string) bool {
+	for _, s := range split {
+		if s == wildcard || s == recursiveWildcard {
+			return true
+		}
+	}
+
+	return false
+}
+
+func unique(l []match) []match {
+	var u []match
+
+	seen := map[string]bool{}
+	for _, m := range l {
+		k := strings.Join(m.split, "\n")
+		if seen[k] {
+			continue
+		}
+
+		seen[k] = true
+		u = append(u, m)
+	}
+
+	return u
+}
+
+func with(prefix []string, s string) []string {
+	return append(append([]string{}, prefix...), s)
+}
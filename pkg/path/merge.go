@@ -0,0 +1,254 @@
+package path
+
+import (
+	"fmt"
+
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// ListStrategyAppend appends the items of lists in the fragment to the
+	// existing lists.
+	ListStrategyAppend = "append"
+	// ListStrategyMerge merges items of lists in the fragment into the items
+	// of the existing lists which have the same value under the merge key,
+	// like strategic merge patches do. Items without counterpart are
+	// appended.
+	ListStrategyMerge = "merge"
+	// ListStrategyReplace replaces existing lists with the lists in the
+	// fragment, as specified in RFC 7386.
+	ListStrategyReplace = "replace"
+)
+
+type MergeConfig struct {
+	// Fragment is the YAML or JSON document merged into the data structure.
+	Fragment []byte
+	// ListStrategy defines how lists are merged. Defaults to
+	// ListStrategyReplace.
+	ListStrategy string
+	// MergeKey is the key identifying list items when using
+	// ListStrategyMerge. Defaults to name.
+	MergeKey string
+}
+
+// change is a single modification necessary to merge a fragment.
+type change struct {
+	delete bool
+	split  []string
+	value  *yaml.Node
+}
+
+// Merge deep merges the configured fragment into the value found under the
+// given path, following the semantics of JSON Merge Patches as specified in
+// RFC 7386. Objects are merged recursively, null values delete the keys they
+// are defined for and all other values replace the existing ones. Lists are
+// merged according to the configured list strategy. The empty path refers to
+// the whole data structure.
+func (p *Path) Merge(path string, config MergeConfig) error {
+	if config.ListStrategy == "" {
+		config.ListStrategy = ListStrategyReplace
+	}
+	if config.ListStrategy != ListStrategyAppend && config.ListStrategy != ListStrategyMerge && config.ListStrategy != ListStrategyReplace {
+		return tracer.Maskf(invalidConfigError, "%T.ListStrategy must be one of %s, %s or %s", config, ListStrategyAppend, ListStrategyMerge, ListStrategyReplace)
+	}
+	if config.MergeKey == "" {
+		config.MergeKey = "name"
+	}
+
+	var fragment *yaml.Node
+	{
+		n, err := unmarshal(config.Fragment)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+		if len(n.Content) == 0 {
+			return tracer.Maskf(invalidFormatError, "fragment must not be empty")
+		}
+
+		fragment = n.Content[0]
+		if isJSON(config.Fragment) {
+			strip(fragment)
+		}
+	}
+
+	var targets []match
+	if path == "" {
+		targets = []match{{node: p.root()}}
+	} else {
+		split, ok, err := p.single(path)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		if ok {
+			var n *yaml.Node
+			if l := p.matchFromNode(split, p.root(), nil); len(l) != 0 {
+				n = l[0].node
+			}
+
+			targets = []match{{node: n, split: split}}
+		} else {
+			targets, err = p.modifiable(path)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+	}
+
+	// Values are set before any value is deleted, so that objects are not
+	// emptied on their way, which would cause the values added to them to be
+	// written in flow style, like {}.
+	for _, t := range targets {
+		l := p.merge(t.split, t.node, fragment, config)
+
+		for _, c := range l {
+			if c.delete {
+				continue
+			}
+
+			err := p.set(c.split, clone(c.value))
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+
+		for _, c := range l {
+			if !c.delete {
+				continue
+			}
+
+			err := p.delete(c.split)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// merge returns the changes necessary to merge the given fragment into the
+// given target node found under the given path segments. The target node is
+// nil if it does not exist yet.
+func (p *Path) merge(split []string, target *yaml.Node, fragment *yaml.Node, config MergeConfig) []change {
+	if target != nil {
+		target = resolve(target)
+	}
+	fragment = resolve(fragment)
+
+	// Fragments are merged into documents embedded in strings, instead of
+	// replacing the strings.
+	if target != nil && target.Kind == yaml.ScalarNode && fragment.Kind != yaml.ScalarNode {
+		e, err := p.embedded(target)
+		if err == nil {
+			target = e.root()
+		}
+	}
+
+	switch {
+	case isNull(fragment):
+		if target == nil {
+			return nil
+		}
+
+		return []change{{delete: true, split: split}}
+	case fragment.Kind == yaml.MappingNode && target != nil && target.Kind == yaml.MappingNode:
+		var l []change
+		for _, c := range pairs(fragment) {
+			k := with(split, p.escapeSeparator(c[0].Value))
+			t := lookup(target, c[0].Value)
+
+			switch {
+			case isNull(c[1]) && index(target, c[0].Value) == -1:
+				continue
+			case t == nil:
+				l = append(l, change{split: k, value: prune(c[1])})
+			default:
+				l = append(l, p.merge(k, t, c[1], config)...)
+			}
+		}
+
+		return l
+	case fragment.Kind == yaml.SequenceNode && target != nil && target.Kind == yaml.SequenceNode && config.ListStrategy != ListStrategyReplace:
+		var l []change
+		for _, c := range fragment.Content {
+			if config.ListStrategy == ListStrategyMerge {
+				i := mergeIndex(target, c, config.MergeKey)
+				if i != -1 {
+					l = append(l, p.merge(with(split, fmt.Sprintf("[%d]", i)), target.Content[i], c, config)...)
+					continue
+				}
+			}
+
+			l = append(l, change{split: with(split, "[+]"), value: prune(c)})
+		}
+
+		return l
+	case fragment.Kind == yaml.ScalarNode && target != nil && target.Kind == yaml.ScalarNode:
+		if fragment.Tag == target.Tag && fragment.Value == target.Value {
+			return nil
+		}
+	}
+
+	return []change{{split: split, value: prune(fragment)}}
+}
+
+func isNull(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.Tag == "!!null"
+}
+
+// mergeIndex returns the index of the item of the given target sequence which
+// has the same value under the given key as the given item, or -1 if there is
+// no such item.
+func mergeIndex(target *yaml.Node, item *yaml.Node, key string) int {
+	item = resolve(item)
+	if item.Kind != yaml.MappingNode {
+		return -1
+	}
+
+	v := lookup(item, key)
+	if v == nil || resolve(v).Kind != yaml.ScalarNode {
+		return -1
+	}
+
+	pr := predicate{
+		key:   key,
+		value: resolve(v).Value,
+	}
+
+	l := pr.indices(target)
+	if len(l) == 0 {
+		return -1
+	}
+
+	return l[0]
+}
+
+// prune returns a copy of the given node without any keys holding null
+// values, since null values delete keys when merging, as specified in RFC
+// 7386.
+func prune(n *yaml.Node) *yaml.Node {
+	n = clone(n)
+
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind == yaml.MappingNode {
+			var l []*yaml.Node
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if !isNull(n.Content[i+1]) {
+					l = append(l, n.Content[i], n.Content[i+1])
+				}
+			}
+			n.Content = l
+		}
+
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+
+	walk(n)
+
+	return n
+}
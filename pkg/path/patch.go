@@ -0,0 +1,308 @@
+package path
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// operation is a single operation of a JSON Patch as specified in RFC 6902.
+type operation struct {
+	From  string           `json:"from"`
+	Op    string           `json:"op"`
+	Path  *string          `json:"path"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// ApplyPatch applies the given JSON Patch as specified in RFC 6902. The
+// operations add, remove, replace, move, copy and test are addressed using
+// JSON Pointers as specified in RFC 6901, which may point into documents
+// embedded in strings. The patch is applied atomically. If any operation
+// fails, the data structure is left as it was before.
+func (p *Path) ApplyPatch(patch []byte) error {
+	var operations []operation
+	err := json.Unmarshal(patch, &operations)
+	if err != nil {
+		return tracer.Maskf(invalidPatchError, "%s", err.Error())
+	}
+
+	b := p.bytes
+
+	for _, o := range operations {
+		err := p.operate(o)
+		if err != nil {
+			{
+				err := p.parse(b)
+				if err != nil {
+					return tracer.Mask(err)
+				}
+			}
+
+			return tracer.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Path) operate(o operation) error {
+	if o.Path == nil {
+		return tracer.Maskf(invalidPatchError, "%s operation must define path", o.Op)
+	}
+
+	switch o.Op {
+	case "add":
+		v, err := patchValue(o)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		split, _, err := p.pointer(*o.Path, true)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		err = p.set(split, v)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	case "remove":
+		split, _, err := p.pointer(*o.Path, false)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+		if len(split) == 0 {
+			return tracer.Maskf(invalidPatchError, "root cannot be removed")
+		}
+
+		err = p.delete(split)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	case "replace":
+		v, err := patchValue(o)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		split, _, err := p.pointer(*o.Path, false)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		err = p.set(split, v)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	case "move":
+		if o.From == *o.Path {
+			return nil
+		}
+		if strings.HasPrefix(*o.Path, o.From+"/") {
+			return tracer.Maskf(invalidPatchError, "'%s' cannot be moved into one of its children", o.From)
+		}
+
+		v, err := p.pointed(o.From)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		{
+			split, _, err := p.pointer(o.From, false)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+
+			err = p.delete(split)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+
+		split, _, err := p.pointer(*o.Path, true)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		err = p.set(split, v)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	case "copy":
+		v, err := p.pointed(o.From)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		split, _, err := p.pointer(*o.Path, true)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		err = p.set(split, v)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	case "test":
+		v, err := patchValue(o)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		n, err := p.pointed(*o.Path)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		a, _ := decode(n)
+		b, _ := decode(v)
+		if !reflect.DeepEqual(a, b) {
+			return tracer.Maskf(testFailedError, "value of '%s' differs", *o.Path)
+		}
+	default:
+		return tracer.Maskf(invalidPatchError, "unknown operation '%s'", o.Op)
+	}
+
+	return nil
+}
+
+// pointed returns a copy of the node the given JSON Pointer points to.
+func (p *Path) pointed(ptr string) (*yaml.Node, error) {
+	_, n, err := p.pointer(ptr, false)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return clone(n), nil
+}
+
+// pointer returns the path segments addressed by the given JSON Pointer. All
+// but the last token must point to existing nodes. The last token must point
+// to an existing node as well, unless add is true. In that case the last
+// token may describe a new key of an object, or the index at which a new item
+// is inserted into a list, where "-" appends the item to the list. The node
+// pointed to is returned as well, if it exists.
+func (p *Path) pointer(ptr string, add bool) ([]string, *yaml.Node, error) {
+	if ptr == "" {
+		return nil, p.root(), nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, nil, tracer.Maskf(invalidPatchError, "pointer '%s' must start with '/'", ptr)
+	}
+
+	tokens := strings.Split(ptr[1:], "/")
+
+	var split []string
+
+	e := p
+	n := p.root()
+	for i, t := range tokens {
+		t = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+		last := i == len(tokens)-1
+
+		if n == nil {
+			return nil, nil, tracer.Maskf(notFoundError, "pointer '%s'", ptr)
+		}
+
+		n = resolve(n)
+		if n.Kind == yaml.ScalarNode {
+			x, err := e.embedded(n)
+			if err != nil {
+				return nil, nil, tracer.Maskf(notFoundError, "pointer '%s'", ptr)
+			}
+
+			e = x
+			n = x.root()
+		}
+
+		switch n.Kind {
+		case yaml.MappingNode:
+			split = append(split, e.escapeSeparator(t))
+
+			n = lookup(n, t)
+			if n == nil && !(add && last) {
+				return nil, nil, tracer.Maskf(notFoundError, "pointer '%s'", ptr)
+			}
+		case yaml.SequenceNode:
+			if add && last && t == "-" {
+				split = append(split, "[+]")
+				n = nil
+				break
+			}
+
+			index, err := strconv.Atoi(t)
+			if err != nil || t != strconv.Itoa(index) || index < 0 {
+				return nil, nil, tracer.Maskf(notFoundError, "pointer '%s'", ptr)
+			}
+
+			if add && last {
+				if index > len(n.Content) {
+					return nil, nil, tracer.Maskf(notFoundError, "pointer '%s'", ptr)
+				}
+
+				split = append(split, fmt.Sprintf("[+%d]", index))
+				n = nil
+				break
+			}
+
+			if index >= len(n.Content) {
+				return nil, nil, tracer.Maskf(notFoundError, "pointer '%s'", ptr)
+			}
+
+			split = append(split, fmt.Sprintf("[%d]", index))
+			n = n.Content[index]
+		}
+	}
+
+	return split, n, nil
+}
+
+// clone returns a deep copy of the given node, with aliases replaced by the
+// nodes they refer to, so that the copy can be written anywhere.
+func clone(n *yaml.Node) *yaml.Node {
+	n = resolve(n)
+
+	c := &yaml.Node{
+		Kind:  n.Kind,
+		Style: n.Style,
+		Tag:   n.Tag,
+		Value: n.Value,
+	}
+
+	for _, x := range n.Content {
+		c.Content = append(c.Content, clone(x))
+	}
+
+	return c
+}
+
+// patchValue returns the value of the given operation as node. The styles of
+// the JSON value are dropped, so that e.g. strings are only quoted if
+// necessary when written into YAML documents.
+func patchValue(o operation) (*yaml.Node, error) {
+	if o.Value == nil {
+		return nil, tracer.Maskf(invalidPatchError, "%s operation must define value", o.Op)
+	}
+
+	n, err := unmarshal(*o.Value)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	strip(n.Content[0])
+
+	return n.Content[0], nil
+}
+
+// strip removes the styles of the given node and all of its children.
+func strip(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		strip(c)
+	}
+}
@@ -1,87 +1,221 @@
 package path
 
 import (
-	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"reflect"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 
-	yamltojson "github.com/ghodss/yaml"
-	"github.com/spf13/cast"
 	"github.com/xh3b4sd/tracer"
-	yaml "gopkg.in/yaml.v2"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/xh3b4sd/dsm/pkg/sops"
+	"github.com/xh3b4sd/dsm/pkg/template"
 )
 
 const (
 	escapedSeparatorPlaceholder = "%%PLACEHOLDER%%"
 )
 
+const (
+	// FormatDotenv describes dotenv files, which are flat lists of key value
+	// pairs like KEY=value. All values are strings.
+	FormatDotenv = "dotenv"
+	// FormatHCL describes HCL documents, like Terraform variable files, which
+	// keep their comments after being modified. Blocks are objects under their
+	// type and labels, e.g. resource.aws_instance.web. Expressions referring
+	// to variables or functions are strings of their source text.
+	FormatHCL = "hcl"
+	// FormatJSON describes JSON documents, which have all modifications
+	// spliced into their original bytes, keeping the format of all values not
+	// modified.
+	FormatJSON = "json"
+	// FormatJSON5 describes JSON5 documents, and JSON documents with comments
+	// like tsconfig.json, which have all modifications spliced into their
+	// original bytes, keeping their comments. Comments, trailing commas,
+	// unquoted keys and single quoted strings are read as JSON5 allows.
+	FormatJSON5 = "json5"
+	// FormatProperties describes Java properties files, which are flat lists
+	// of key value pairs like spring.datasource.url=jdbc:h2:mem:db. All values
+	// are strings. Keys containing the separator have to be escaped, e.g.
+	// spring\.datasource\.url.
+	FormatProperties = "properties"
+	// FormatTOML describes TOML documents, which keep their comments and
+	// tables after being modified, as far as possible.
+	FormatTOML = "toml"
+	// FormatXML describes XML documents, like Maven POMs, which have all
+	// modifications spliced into their original bytes. Elements containing
+	// text only are strings. All other elements are objects, having their
+	// attributes as keys like @version and their child elements as keys like
+	// version. Child elements occurring more than once are lists.
+	FormatXML = "xml"
+	// FormatYAML describes YAML documents, which have all modifications
+	// spliced into their original bytes. JSON documents are YAML documents as
+	// well.
+	FormatYAML = "yaml"
+)
+
+// Formats are all the formats documents can be read and written in.
+var Formats = []string{
+	FormatDotenv,
+	FormatHCL,
+	FormatJSON,
+	FormatJSON5,
+	FormatProperties,
+	FormatTOML,
+	FormatXML,
+	FormatYAML,
+}
+
+const (
+	// QueryLanguageDotted is the default query language, describing paths
+	// like spec.containers.[0].image, where the separator is configurable.
+	QueryLanguageDotted = "dotted"
+	// QueryLanguageJSONPath describes paths as JSONPath queries as specified
+	// in RFC 9535, e.g. $.spec.containers[?@.name=='app'].image.
+	QueryLanguageJSONPath = "jsonpath"
+)
+
 var (
+	bracketExpression     = regexp.MustCompile(`^(.*?)((?:\[[^\[\]]*\])+)$`)
+	indexExpression       = regexp.MustCompile(`^\[-?[0-9]+\]$`)
+	numberExpression      = regexp.MustCompile(`^-?(?:0|[1-9][0-9]*)(?:\.[0-9]+)?(?:[eE][+-]?[0-9]+)?$`)
 	placeholderExpression = regexp.MustCompile(escapedSeparatorPlaceholder)
+	positionExpression    = regexp.MustCompile(`^\[\+([0-9]+)\]$`)
+	segmentExpression     = regexp.MustCompile(`\[[^\[\]]*\]`)
 )
 
 type Config struct {
-	Bytes     []byte
-	Separator string
+	// Base64 causes strings containing base64 encoded YAML or JSON documents
+	// to be decoded, so that paths can address the values of e.g. the data
+	// of Kubernetes Secrets. Modified documents are encoded again.
+	Base64 bool
+	Bytes  []byte
+	// Format is the format of Bytes, one of Formats. JSON, TOML, XML and YAML
+	// are detected from the content of Bytes if empty. Flat formats like dotenv,
+	// HCL and JSON5 have to be given, since their content is valid YAML or TOML
+	// as well, or looks like JSON.
+	Format        string
+	QueryLanguage string
+	Separator     string
+	// Sops causes YAML and JSON documents encrypted with SOPS to be decrypted
+	// using the keys it provides, so that paths address their plaintext
+	// values. Modified documents have their changed values encrypted again
+	// and their MAC recomputed. The MAC of files holding more than one
+	// encrypted document covers all of them, which is not supported.
+	Sops *sops.Sops
+	// SplitAliases causes Set to write copies of the anchored nodes aliases
+	// and merge keys refer to, instead of modifying the anchored nodes
+	// themselves, which would change every place using them.
+	SplitAliases bool
+	// Template causes Go template actions like {{ .Values.image }} to be
+	// treated as opaque tokens, so that the static parts of e.g. Helm chart
+	// templates can be searched and updated. All actions are written back
+	// unchanged.
+	Template bool
 }
 
 type Path struct {
+	base64                     bool
+	bytes                      []byte
+	edits                      []edit
+	encoded                    bool
+	flat                       *flat
+	flow                       map[*yaml.Node]bool
+	hcl                        *hclDocument
 	isJSON                     bool
-	jsonBytes                  []byte
-	jsonStructure              interface{}
+	json5                      *json5
+	language                   string
+	node                       *yaml.Node
+	queryLanguage              string
+	renderer                   *renderer
+	source                     *source
 	escapedSeparatorExpression *regexp.Regexp
 	separatorExpression        *regexp.Regexp
 
-	separator string
+	separator    string
+	sops         *sopsDocument
+	splitAliases bool
+	template     *template.Actions
+	toml         *toml
+	xml          *xmlDocument
+}
+
+// edit describes the replacement of the source bytes between start and end
+// with the given text.
+type edit struct {
+	start int
+	end   int
+	text  string
 }
 
 func New(config Config) (*Path, error) {
 	if config.Bytes == nil {
 		return nil, tracer.Maskf(invalidConfigError, "%T.Bytes must not be empty", config)
 	}
-	if config.Separator == "" {
-		config.Separator = "."
-	}
-
-	var err error
-
-	var isJSON bool
-	var jsonBytes []byte
-	var jsonStructure interface{}
 	{
-		jsonBytes, isJSON, err = toJSON(config.Bytes)
-		if err != nil {
-			return nil, tracer.Mask(err)
+		ok := config.Format == ""
+		for _, f := range Formats {
+			if config.Format == f {
+				ok = true
+			}
 		}
 
-		err := json.Unmarshal(jsonBytes, &jsonStructure)
-		if err != nil {
-			return nil, tracer.Mask(err)
+		if !ok {
+			return nil, tracer.Maskf(invalidConfigError, "%T.Format must be one of %s", config, strings.Join(Formats, ", "))
 		}
 	}
+	if config.QueryLanguage == "" {
+		config.QueryLanguage = QueryLanguageDotted
+	}
+	if config.QueryLanguage != QueryLanguageDotted && config.QueryLanguage != QueryLanguageJSONPath {
+		return nil, tracer.Maskf(invalidConfigError, "%T.QueryLanguage must be one of %s or %s", config, QueryLanguageDotted, QueryLanguageJSONPath)
+	}
+	if config.Separator == "" {
+		config.Separator = "."
+	}
 
 	p := &Path{
-		isJSON:                     isJSON,
-		jsonBytes:                  jsonBytes,
-		jsonStructure:              jsonStructure,
+		base64:                     config.Base64,
 		escapedSeparatorExpression: regexp.MustCompile(fmt.Sprintf(`\\%s`, config.Separator)),
+		language:                   config.Format,
+		queryLanguage:              config.QueryLanguage,
 		separatorExpression:        regexp.MustCompile(fmt.Sprintf(`\%s`, config.Separator)),
 
-		separator: config.Separator,
+		separator:    config.Separator,
+		splitAliases: config.SplitAliases,
+	}
+
+	b := config.Bytes
+	if config.Template {
+		var a template.Actions
+		b, a = template.Mask(b)
+		p.template = &a
+	}
+
+	err := p.parse(b)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	if config.Sops != nil {
+		err := p.decryptSops(config.Sops)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
 	}
 
 	return p, nil
 }
 
-// All returns all paths found in the configured JSON structure.
+// All returns all paths found in the configured data structure.
 func (p *Path) All() ([]string, error) {
-	paths, err := p.allFromInterface(p.jsonStructure)
-	if err != nil {
-		return nil, tracer.Mask(err)
+	paths := p.allFromNode(p.root())
+	for i := range paths {
+		paths[i] = p.unmask(paths[i])
 	}
 
 	sort.Strings(paths)
@@ -89,43 +223,91 @@ func (p *Path) All() ([]string, error) {
 	return paths, nil
 }
 
-// Get returns the value found under the given path, if any.
+// Delete removes the value found under the given path, including its key in
+// case the value is part of an object.
+func (p *Path) Delete(path string) error {
+	split, ok, err := p.single(path)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	if ok && !isPredicate(split) {
+		err := p.delete(split)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	}
+
+	matches, err := p.modifiable(path)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	// Recursive wildcards match the document itself, which cannot be
+	// deleted. Nothing is deleted in this case, instead of deleting all the
+	// values matched before.
+	for _, m := range matches {
+		if len(m.split) == 0 {
+			return tracer.Maskf(invalidQueryError, "key '%s' must not match the document root", path)
+		}
+	}
+
+	for _, m := range matches {
+		err := p.delete(m.split)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// Get returns the value found under the given path, if any. In case the path
+// is an expression matching multiple values, the first value is returned. Use
+// GetAll in order to get all values together with their concrete paths.
 func (p *Path) Get(path string) (interface{}, error) {
-	value, err := p.getFromInterface(p.escapeKey(path), p.jsonStructure)
+	matches, err := p.GetAll(path)
 	if err != nil {
 		return nil, tracer.Mask(err)
 	}
 
-	return value, nil
+	return matches[0].Value, nil
 }
 
+// OutputBytes returns the current state of the configured data structure.
+// YAML documents are written exactly as they were given, except for the
+// parts changed using Set. Documents decrypted using Config.Sops are encrypted
+// again.
 func (p *Path) OutputBytes() ([]byte, error) {
-	b := p.jsonBytes
-	if !p.isJSON {
+	b := p.bytes
+	if p.sops != nil {
 		var err error
-		b, err = yamltojson.JSONToYAML(b)
+		b, err = p.encryptSops()
 		if err != nil {
 			return nil, tracer.Mask(err)
 		}
 	}
 
-	return b, nil
+	return []byte(p.unmask(string(b))), nil
 }
 
-// Set changes the value of the given path.
+// Set changes the value of the given path. Missing keys are created. In case
+// the path is an expression, the values of all existing matches are changed.
 func (p *Path) Set(path string, value interface{}) error {
-	var err error
-
-	p.jsonStructure, err = p.setFromInterface(p.escapeKey(path), value, p.jsonStructure)
+	var n yaml.Node
+	err := n.Encode(value)
 	if err != nil {
 		return tracer.Mask(err)
 	}
 
-	b, err := json.MarshalIndent(p.jsonStructure, "", "  ")
+	err = p.setWith(path, func(existing *yaml.Node) (*yaml.Node, error) {
+		return &n, nil
+	})
 	if err != nil {
 		return tracer.Mask(err)
 	}
-	p.jsonBytes = b
 
 	return nil
 }
@@ -157,394 +339,1360 @@ func (p *Path) Validate(paths []string) error {
 	return nil
 }
 
-func (p *Path) allFromInterface(value interface{}) ([]string, error) {
-	// process map
-	{
-		stringMap, err := cast.ToStringMapE(value)
-		if err != nil {
-			// fall through
-		} else {
-			var paths []string
-
-			for k, v := range stringMap {
-				var l []string
-				if reflect.TypeOf(v).String() != "string" {
-					l, err = p.allFromInterface(v)
-					if err != nil {
-						return nil, tracer.Mask(err)
-					}
-				}
+func (p *Path) allFromNode(n *yaml.Node) []string {
+	var paths []string
 
-				k := p.separatorExpression.ReplaceAllString(k, fmt.Sprintf(`\%s`, p.separator))
+	n = resolve(n)
 
-				if l != nil { // nolint:gosimple
-					for _, v := range l {
-						paths = append(paths, fmt.Sprintf("%s%s%s", k, p.separator, v))
-					}
-				} else {
-					paths = append(paths, k)
-				}
+	switch n.Kind {
+	case yaml.MappingNode:
+		for _, c := range pairs(n) {
+			k := p.separatorExpression.ReplaceAllString(c[0].Value, fmt.Sprintf(`\%s`, p.separator))
+
+			l := p.allFromNode(c[1])
+			if l == nil {
+				paths = append(paths, k)
+			}
+			for _, v := range l {
+				paths = append(paths, fmt.Sprintf("%s%s%s", k, p.separator, v))
 			}
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			k := fmt.Sprintf("[%d]", i)
 
-			return paths, nil
+			l := p.allFromNode(c)
+			if l == nil {
+				paths = append(paths, k)
+			}
+			for _, v := range l {
+				paths = append(paths, fmt.Sprintf("%s%s%s", k, p.separator, v))
+			}
+		}
+	case yaml.ScalarNode:
+		// Documents decoded from base64 are listed, since their values cannot
+		// be seen otherwise.
+		if p.base64 {
+			e, err := p.embeddedAs(n, true)
+			if err == nil {
+				paths = e.allFromNode(e.root())
+			}
 		}
 	}
 
-	// process slice
-	{
-		slice, err := cast.ToSliceE(value)
-		if err != nil {
-			// fall through
-		} else {
-			var paths []string
-
-			for i, v := range slice {
-				l, err := p.allFromInterface(v)
-				if err != nil {
-					return nil, tracer.Mask(err)
-				}
+	return paths
+}
 
-				for _, v := range l {
-					paths = append(paths, fmt.Sprintf("[%d]%s%s", i, p.separator, v))
-				}
+// add inserts the given value into the given sequence, such that the value
+// ends up at index i.
+func (p *Path) add(n *yaml.Node, i int, value *yaml.Node) error {
+	if p.isYAML() {
+		s := p.source
+
+		var e edit
+		switch {
+		case n.Style&yaml.FlowStyle != 0 && i < len(n.Content):
+			e.start = s.start(n.Content[i])
+			e.text = p.renderer.flow(value) + ", "
+		case n.Style&yaml.FlowStyle != 0:
+			e.start = s.end(n) - 1
+			e.text = p.renderer.flow(value)
+			if len(n.Content) != 0 {
+				e.start = s.end(n.Content[len(n.Content)-1])
+				e.text = ", " + e.text
 			}
-
-			return paths, nil
+		case i < len(n.Content):
+			e.start = s.indicator(s.start(n.Content[i]))
+			c := s.column(e.start)
+			e.text = "-" + p.renderer.item(value, c) + "\n" + spaces(c)
+		default:
+			c := s.column(s.indicator(s.start(n.Content[0])))
+			e.start = s.lineEnd(s.end(n.Content[len(n.Content)-1]))
+			e.text = "\n" + spaces(c) + "-" + p.renderer.item(value, c)
 		}
+		e.end = e.start
+
+		p.edits = append(p.edits, e)
 	}
 
-	// process string
-	{
-		str, err := cast.ToStringE(value)
+	n.Content = append(n.Content[:i:i], append([]*yaml.Node{value}, n.Content[i:]...)...)
+
+	if p.toml != nil {
+		err := p.addTOML(n, i, value)
 		if err != nil {
-			// fall through
-		} else if str == "" {
-			// fall through
-		} else {
-			jsonBytes, _, err := toJSON([]byte(str))
-			if err != nil {
-				// fall through
-			} else {
-				var jsonStructure interface{}
-				err := json.Unmarshal(jsonBytes, &jsonStructure)
-				if err != nil {
-					return nil, tracer.Mask(err)
-				}
+			return tracer.Mask(err)
+		}
+	}
 
-				l, err := p.allFromInterface(jsonStructure)
-				if err != nil {
-					return nil, tracer.Mask(err)
-				}
+	if p.xml != nil {
+		err := p.addXML(n, i, value)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
 
-				return l, nil
-			}
+	if p.hcl != nil {
+		err := p.addHCL(n, value)
+		if err != nil {
+			return tracer.Mask(err)
 		}
 	}
 
-	return nil, nil
-}
+	if p.json5 != nil {
+		err := p.addJSON5(n, i, value)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
 
-func (p *Path) escapeKey(key string) string {
-	return p.escapedSeparatorExpression.ReplaceAllString(key, escapedSeparatorPlaceholder)
+	return nil
 }
 
-func (p *Path) getFromInterface(path string, jsonStructure interface{}) (interface{}, error) {
-	split := strings.Split(path, p.separator)
-	key := p.unescapeKey(split[0])
-
-	// process map
-	{
-		stringMap, err := cast.ToStringMapE(jsonStructure)
+// apply writes the edits collected during a modification into the configured
+// data structure. YAML and JSON documents have the edits spliced into their
+// original bytes. TOML documents have the edits spliced into their original
+// bytes, unless they have to be encoded again as a whole. HCL documents have
+// their modified attributes written by the native HCL writer.
+func (p *Path) apply() error {
+	var b []byte
+	if p.hcl != nil {
+		b = p.writeHCL()
+	} else if p.toml != nil && p.toml.dirty {
+		var err error
+		b, err = encodeTOML(p.node)
 		if err != nil {
-			// fall through
-		} else {
-			value, ok := stringMap[key]
-			if ok {
-				if len(split) == 1 {
-					return value, nil
-				} else {
-					recPath := strings.Join(split[1:], p.separator)
-
-					v, err := p.getFromInterface(recPath, value)
-					if err != nil {
-						return nil, tracer.Mask(err)
-					}
-
-					return v, nil
-				}
-			} else {
-				return nil, tracer.Maskf(notFoundError, "key '%s'", path)
-			}
+			return tracer.Mask(err)
+		}
+	} else {
+		b = p.bytes
+
+		sort.SliceStable(p.edits, func(i, j int) bool { return p.edits[i].start > p.edits[j].start })
+		for _, e := range p.edits {
+			var c []byte
+			c = append(c, b[:e.start]...)
+			c = append(c, e.text...)
+			c = append(c, b[e.end:]...)
+			b = c
 		}
 	}
 
-	// process slice
-	{
-		slice, err := cast.ToSliceE(jsonStructure)
-		if err != nil {
-			// fall through
-		} else {
-			index, err := indexFromKey(key)
-			if err != nil {
-				return nil, tracer.Mask(err)
-			}
+	err := p.parse(b)
+	if err != nil {
+		return tracer.Mask(err)
+	}
 
-			if index >= len(slice) {
-				return nil, tracer.Maskf(notFoundError, "key '%s'", key)
-			}
-			recPath := strings.Join(split[1:], p.separator)
+	return nil
+}
 
-			v, err := p.getFromInterface(recPath, slice[index])
-			if err != nil {
-				return nil, tracer.Mask(err)
-			}
+func (p *Path) delete(split []string) error {
+	p.edits = nil
 
-			return v, nil
-		}
+	err := p.deleteFromNode(split, p.root())
+	if err != nil {
+		return tracer.Mask(err)
 	}
 
-	// process string
-	{
-		str, err := cast.ToStringE(jsonStructure)
-		if err != nil {
-			// fall through
-		} else {
-			jsonBytes, _, err := toJSON([]byte(str))
-			if err != nil {
-				// fall through
-			} else {
-				var jsonStructure interface{}
-				err := json.Unmarshal(jsonBytes, &jsonStructure)
-				if err != nil {
-					return nil, tracer.Mask(err)
-				}
+	err = p.apply()
+	if err != nil {
+		return tracer.Mask(err)
+	}
 
-				v, err := p.getFromInterface(path, jsonStructure)
-				if err != nil {
-					return nil, tracer.Mask(err)
-				}
+	return nil
+}
 
-				return v, nil
-			}
+func (p *Path) deleteFromNode(split []string, n *yaml.Node) error {
+	if len(split) == 0 {
+		return tracer.Maskf(invalidQueryError, "the document root cannot be deleted")
+	}
+
+	if n.Kind == yaml.AliasNode {
+		err := p.deleteFromNode(split, n.Alias)
+		if err != nil {
+			return tracer.Mask(err)
 		}
+
+		return nil
 	}
 
-	return nil, nil
-}
+	if isMarker(split[0]) {
+		err := p.deleteMarked(split, n)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	}
 
-func (p *Path) setFromInterface(path string, value interface{}, jsonStructure interface{}) (interface{}, error) {
-	split := strings.Split(path, p.separator)
 	key := p.unescapeKey(split[0])
+	n = p.listed(n, key)
 
-	// Create new element when the existing jsonStructure doesn't exist.
-	if jsonStructure == nil {
-		m := make(map[string]interface{})
+	switch n.Kind {
+	case yaml.MappingNode:
+		i := index(n, key)
+		if i == -1 {
+			return tracer.Maskf(notFoundError, "key '%s'", strings.Join(split, p.separator))
+		}
 
-		// Just recurse when there are more components left in path with
-		// missing elements.
-		if len(split) > 1 {
-			var err error
-			recPath := strings.Join(split[1:], p.separator)
-			value, err = p.setFromInterface(recPath, value, nil)
+		if len(split) == 1 {
+			err := p.remove(n, i)
 			if err != nil {
-				return nil, tracer.Mask(err)
+				return tracer.Mask(err)
 			}
-		}
 
-		m[key] = value
-
-		return m, nil
-	}
+			return nil
+		}
 
-	// process map
-	{
-		_, ok := jsonStructure.(string)
-		if ok {
-			// Fall through in case our received JSON structure is actually a string.
-			// cast.ToStringMapE was working as expected until
-			// https://github.com/spf13/cast/pull/59, so we have to make sure we do
-			// not call cast.ToStringMapE only if we do not have an actual string,
-			// because cast.ToStringMapE would now accept the string instead of
-			// returning an error like it did before.
-		} else {
-			stringMap, err := cast.ToStringMapE(jsonStructure)
-			if err != nil {
-				// fall through
-			} else {
-				if len(split) == 1 {
-					stringMap[path] = value
-					return stringMap, nil
-				} else {
-					recPath := strings.Join(split[1:], p.separator)
-
-					modified, err := p.setFromInterface(recPath, value, stringMap[key])
-					if err != nil {
-						return nil, tracer.Mask(err)
-					}
-					stringMap[key] = modified
-
-					return stringMap, nil
-				}
-			}
+		err := p.deleteFromNode(split[1:], n.Content[i+1])
+		if err != nil {
+			return tracer.Mask(err)
 		}
-	}
 
-	// process slice
-	{
-		slice, err := cast.ToSliceE(jsonStructure)
+		return nil
+	case yaml.SequenceNode:
+		index, err := indexFromKey(key)
 		if err != nil {
-			// fall through
-		} else {
-			index, err := indexFromKey(key)
-			if err != nil {
-				return nil, tracer.Mask(err)
-			}
+			return tracer.Mask(err)
+		}
+		if index < 0 {
+			index += len(n.Content)
+		}
 
-			if index >= len(slice) {
-				return nil, tracer.Maskf(notFoundError, "key '%s'", key)
-			}
-			recPath := strings.Join(split[1:], p.separator)
+		if index < 0 || index >= len(n.Content) {
+			return tracer.Maskf(notFoundError, "key '%s'", key)
+		}
 
-			modified, err := p.setFromInterface(recPath, value, slice[index])
+		if len(split) == 1 {
+			err := p.remove(n, index)
 			if err != nil {
-				return nil, tracer.Mask(err)
+				return tracer.Mask(err)
 			}
-			slice[index] = modified
 
-			return slice, nil
+			return nil
 		}
-	}
 
-	// process string
-	{
-		str, err := cast.ToStringE(jsonStructure)
+		err = p.deleteFromNode(split[1:], n.Content[index])
 		if err != nil {
-			// fall through
-		} else {
-			jsonBytes, isJSON, err := toJSON([]byte(str))
-			if err != nil {
-				// fall through
-			} else {
-				var jsonStructure interface{}
-				err := json.Unmarshal(jsonBytes, &jsonStructure)
-				if err != nil {
-					return nil, tracer.Mask(err)
-				}
+			return tracer.Mask(err)
+		}
 
-				modified, err := p.setFromInterface(path, value, jsonStructure)
-				if err != nil {
-					return nil, tracer.Mask(err)
-				}
+		return nil
+	}
 
-				var b []byte
-				if !isJSON {
-					b, err = yamltojson.Marshal(modified)
-					if err != nil {
-						return nil, tracer.Mask(err)
-					}
-				} else {
-					b, err = json.MarshalIndent(modified, "", "  ")
-					if err != nil {
-						return nil, tracer.Mask(err)
-					}
-				}
+	// Modify the document embedded in the existing string value.
+	{
+		modify := func(e *Path) error {
+			return e.delete(split)
+		}
 
-				return string(b), nil
-			}
+		err := p.setEmbedded(n, key, modify)
+		if err != nil {
+			return tracer.Mask(err)
 		}
 	}
 
-	return nil, nil
+	return nil
 }
 
-func (p *Path) unescapeKey(key string) string {
-	return placeholderExpression.ReplaceAllString(key, p.separator)
+// embedded returns a Path for the document embedded in the given string
+// node, if any. Strings containing base64 encoded documents are decoded, if
+// configured.
+func (p *Path) embedded(n *yaml.Node) (*Path, error) {
+	e, err := p.embeddedAs(n, false)
+	if err != nil && p.base64 {
+		e, err = p.embeddedAs(n, true)
+	}
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return e, nil
 }
 
-func containsString(list []string, item string) bool {
-	for _, l := range list {
-		if l == item {
-			return true
-		}
+// embeddedAs returns a Path for the document embedded in the given string
+// node, decoding the string as base64 first if encoded is true.
+func (p *Path) embeddedAs(n *yaml.Node, encoded bool) (*Path, error) {
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!str" {
+		return nil, tracer.Mask(invalidFormatError)
 	}
 
-	return false
-}
+	b := []byte(n.Value)
+	if encoded {
+		var err error
+		b, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(n.Value), ""))
+		if err != nil {
+			return nil, tracer.Maskf(invalidFormatError, "%s", err.Error())
+		}
+	}
 
-func indexFromKey(key string) (int, error) {
-	re := regexp.MustCompile(`\[[0-9]+\]`)
-	ok := re.MatchString(key)
-	if !ok {
-		return 0, tracer.Maskf(invalidFormatError, key)
+	c := Config{
+		Base64:       p.base64,
+		Bytes:        b,
+		Format:       FormatYAML,
+		Separator:    p.separator,
+		SplitAliases: p.splitAliases,
 	}
 
-	p := key[1 : len(key)-1]
-	i, err := strconv.Atoi(p)
+	e, err := New(c)
 	if err != nil {
-		return 0, tracer.Mask(err)
+		return nil, tracer.Mask(err)
 	}
 
-	return i, nil
-}
+	e.encoded = encoded
 
-func isJSON(b []byte) bool {
-	var l []interface{}
-	isList := json.Unmarshal(b, &l) == nil
+	return e, nil
+}
 
-	var m map[string]interface{}
-	isObject := json.Unmarshal(b, &m) == nil
+// embeddedBytes returns the bytes of a Path describing an embedded document,
+// to be written back into the string node it was found in. The document keeps
+// its formatting, since YAML and JSON documents have all edits spliced into
+// their original bytes. Documents decoded from base64 are encoded again.
+func (p *Path) embeddedBytes() ([]byte, error) {
+	if p.encoded {
+		return []byte(base64.StdEncoding.EncodeToString(p.bytes)), nil
+	}
 
-	return isObject || isList
+	return p.bytes, nil
 }
 
-func isYAMLList(b []byte) bool {
-	var l []interface{}
-	return yaml.Unmarshal(b, &l) == nil && bytes.HasPrefix(b, []byte("-"))
+func (p *Path) escapeKey(key string) string {
+	return p.escapedSeparatorExpression.ReplaceAllString(key, escapedSeparatorPlaceholder)
 }
 
-func isYAMLObject(b []byte) bool {
-	var m map[interface{}]interface{}
-	return yaml.Unmarshal(b, &m) == nil && !bytes.HasPrefix(b, []byte("-"))
+// escapeSeparator turns the given literal key into a path segment by replacing
+// the separators it contains with the escaped separator placeholder.
+func (p *Path) escapeSeparator(key string) string {
+	return strings.ReplaceAll(key, p.separator, escapedSeparatorPlaceholder)
 }
 
-func toJSON(b []byte) ([]byte, bool, error) {
-	if isJSON(b) {
-		return b, true, nil
+// insert adds the given key and value to the given mapping.
+func (p *Path) insert(m *yaml.Node, key string, value *yaml.Node) error {
+	var k yaml.Node
+	err := k.Encode(key)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	if p.isYAML() {
+		var e edit
+		if m.Style&yaml.FlowStyle != 0 {
+			e.start = p.source.end(m) - 1
+			e.text = p.renderer.scalar(&k, true) + ": " + p.renderer.flow(value)
+			if len(m.Content) != 0 {
+				e.start = p.source.end(m.Content[len(m.Content)-1])
+				e.text = ", " + e.text
+			}
+		} else {
+			c := m.Content[0].Column - 1
+			e.start, err = p.actionsEnd(m)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+			e.text = "\n" + spaces(c) + p.renderer.scalar(&k, false) + ":" + p.renderer.value(value, c)
+		}
+		e.end = e.start
+
+		p.edits = append(p.edits, e)
 	}
 
-	isYAMLList := isYAMLList(b)
-	isYAMLObject := isYAMLObject(b)
+	m.Content = append(m.Content, &k, value)
 
-	var jsonBytes []byte
-	if isYAMLList && !isYAMLObject {
-		var jsonList []interface{}
-		err := yamltojson.Unmarshal(b, &jsonList)
+	if p.toml != nil {
+		err := p.insertTOML(m, &k, value)
 		if err != nil {
-			return nil, false, tracer.Mask(err)
+			return tracer.Mask(err)
 		}
-
-		jsonBytes, err = json.Marshal(jsonList)
+	}
+	if p.flat != nil {
+		err := p.insertFlat(m, &k, value)
 		if err != nil {
-			return nil, false, tracer.Mask(err)
+			return tracer.Mask(err)
 		}
-
-		return jsonBytes, false, nil
 	}
 
-	if !isYAMLList && isYAMLObject {
-		var jsonMap map[string]interface{}
-		err := yamltojson.Unmarshal(b, &jsonMap)
+	if p.xml != nil {
+		err := p.insertXML(m, &k, value)
 		if err != nil {
-			return nil, false, tracer.Mask(err)
+			return tracer.Mask(err)
+		}
+	}
+
+	if p.hcl != nil {
+		err := p.insertHCL(m, &k, value)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	if p.json5 != nil {
+		err := p.insertJSON5(m, &k, value)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// isYAML returns whether the document is written as YAML, which has all
+// modifications spliced into its original bytes by the generic edits.
+func (p *Path) isYAML() bool {
+	return !p.isJSON && p.toml == nil && p.flat == nil && p.xml == nil && p.hcl == nil && p.json5 == nil
+}
+
+// parse reads the given bytes in the configured format. Documents are read as
+// XML if no format is configured and they start with a tag. Documents are read
+// as TOML if no format is configured and they are valid TOML but not JSON,
+// since the YAML parser accepts the table headers of TOML documents.
+func (p *Path) parse(b []byte) error {
+	if p.language == "" && isXML(b) {
+		p.language = FormatXML
+	}
+
+	var n *yaml.Node
+	var t *toml
+	var err error
+	if p.language == "" && !isJSON(b) {
+		n, t, err = parseTOML(b)
+		if err == nil {
+			p.language = FormatTOML
+		}
+	}
+
+	var f *flat
+	var h *hclDocument
+	var j *json5
+	var x *xmlDocument
+	switch {
+	case p.language == FormatDotenv || p.language == FormatProperties:
+		n, f, err = parseFlat(b, p.language)
+	case p.language == FormatHCL:
+		n, h, err = parseHCL(b)
+	case p.language == FormatJSON5:
+		n, j, err = parseJSON5(b)
+	case p.language == FormatXML:
+		n, x, err = parseXML(b)
+	case p.language == FormatTOML && t == nil:
+		n, t, err = parseTOML(b)
+	case p.language != FormatTOML && isJSON(b):
+		n, j, err = parseJSON5(b)
+	case p.language != FormatTOML:
+		n, err = toNode(b)
+	}
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	if p.language == FormatJSON && !isJSON(b) {
+		return tracer.Maskf(invalidFormatError, "document must be JSON")
+	}
+
+	p.bytes = b
+	p.edits = nil
+	p.flat = f
+	p.flow = map[*yaml.Node]bool{}
+	p.hcl = h
+	p.isJSON = j != nil && p.language != FormatJSON5
+	p.json5 = j
+	p.node = n
+	p.toml = t
+	p.xml = x
+	p.renderer = newRenderer(n)
+	p.source = newSource(b)
+
+	var walk func(n *yaml.Node, flow bool)
+	walk = func(n *yaml.Node, flow bool) {
+		p.flow[n] = flow
+		for _, c := range n.Content {
+			walk(c, flow || n.Style&yaml.FlowStyle != 0)
+		}
+	}
+
+	walk(n, false)
+
+	return nil
+}
+
+// remove removes the key value pair at index i of the given mapping, or the
+// item at index i of the given sequence.
+func (p *Path) remove(n *yaml.Node, i int) error {
+	w := 1
+	if n.Kind == yaml.MappingNode {
+		w = 2
+	}
+
+	r := n.Content[i+w-1]
+
+	if p.isYAML() {
+		if len(n.Content) == w {
+			empty := &yaml.Node{
+				Kind:  n.Kind,
+				Style: yaml.FlowStyle,
+				Tag:   n.Tag,
+			}
+
+			p.edits = append(p.edits, p.replacement(n, empty))
+		} else {
+			p.edits = append(p.edits, p.removal(n, i, w))
+		}
+	}
+
+	n.Content = append(n.Content[:i:i], n.Content[i+w:]...)
+
+	if p.toml != nil {
+		err := p.removeTOML(n, r)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+	if p.flat != nil {
+		err := p.removeFlat(r)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	if p.xml != nil {
+		err := p.removeXML(n, r)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	if p.hcl != nil {
+		err := p.removeHCL(n, r)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	if p.json5 != nil {
+		err := p.removeJSON5(n, r)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// removal returns the edit necessary to remove the w nodes starting at index
+// i of the content of the given collection within the source bytes. The
+// collection must keep at least one element.
+func (p *Path) removal(n *yaml.Node, i int, w int) edit {
+	s := p.source
+
+	begin := func(i int) int {
+		o := s.start(n.Content[i])
+		if n.Kind == yaml.SequenceNode && n.Style&yaml.FlowStyle == 0 {
+			o = s.indicator(o)
+		}
+
+		return o
+	}
+
+	e := edit{
+		start: begin(i),
+		end:   s.end(n.Content[i+w-1]),
+	}
+
+	switch {
+	case n.Style&yaml.FlowStyle != 0 && i+w < len(n.Content):
+		e.end = begin(i + w)
+	case n.Style&yaml.FlowStyle != 0:
+		e.start = s.end(n.Content[i-1])
+	case s.ownLine(e.start):
+		e.start = s.lineStart(e.start)
+		e.end = s.lineEnd(e.end)
+		if e.end < len(s.bytes) {
+			e.end++
+		} else if e.start > 0 {
+			e.start--
+		}
+	default:
+		e.end = begin(i + w)
+	}
+
+	return e
+}
+
+// replace changes the given node to the given value.
+func (p *Path) replace(n *yaml.Node, value *yaml.Node) error {
+	if p.flat != nil {
+		err := p.replaceFlat(n, value)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	} else if p.toml != nil {
+		err := p.replaceTOML(n, value)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	} else if p.xml != nil {
+		err := p.replaceXML(n, value)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	} else if p.hcl != nil {
+		err := p.replaceHCL(n, value)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	} else if p.json5 != nil {
+		err := p.replaceJSON5(n, value)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	} else {
+		p.edits = append(p.edits, p.replacement(n, value))
+	}
+
+	*n = *value
+
+	return nil
+}
+
+// replacement returns the edit necessary to replace the given node with the
+// given value within the source bytes.
+func (p *Path) replacement(n *yaml.Node, value *yaml.Node) edit {
+	s := p.source
+
+	e := edit{
+		start: s.content(n),
+		end:   s.end(n),
+	}
+
+	// Comments following a block collection on its last line belong to the
+	// collection.
+	if isBlock(n) {
+		e.end = s.lineEnd(e.end)
+	}
+
+	if n.Kind == yaml.ScalarNode && value.Kind == yaml.ScalarNode && p.flow[n] {
+		e.text = p.renderer.replace(n, value, 0, true)
+		return e
+	}
+
+	if p.flow[n] {
+		e.text = p.renderer.flow(value)
+		return e
+	}
+
+	if n.Kind == yaml.ScalarNode && value.Kind == yaml.ScalarNode {
+		indent := s.indent(e.start) + p.renderer.step
+		if n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			if i := s.blockIndent(e.start); i != -1 {
+				indent = i
+			}
+		}
+
+		e.text = p.renderer.replace(n, value, indent, false)
+		if e.start == e.end && s.previousByte(e.start) == ':' {
+			e.text = " " + e.text
+		}
+
+		return e
+	}
+
+	if t, ok := p.renderer.inline(value); ok {
+		if s.ownLine(e.start) && s.previous(e.start) != 0 {
+			e.start = s.previous(e.start)
+			e.text = " " + t
+		} else {
+			e.text = t
+		}
+
+		return e
+	}
+
+	if s.ownLine(e.start) || s.previousByte(e.start) == '-' {
+		e.text = p.renderer.block(value, s.column(e.start))
+		return e
+	}
+
+	for e.start > 0 && (s.bytes[e.start-1] == ' ' || s.bytes[e.start-1] == '\t') {
+		e.start--
+	}
+
+	indent := s.indent(e.start) + p.renderer.step
+	if isLiteral(value) {
+		e.text = " " + p.renderer.literal(value.Value, indent, false)
+		return e
+	}
+	if value.Kind == yaml.SequenceNode && p.renderer.indentless {
+		indent -= p.renderer.step
+	}
+
+	e.text = "\n" + spaces(indent) + p.renderer.block(value, indent)
+
+	return e
+}
+
+func (p *Path) root() *yaml.Node {
+	return p.node.Content[0]
+}
+
+func (p *Path) set(split []string, value *yaml.Node) error {
+	if p.splitAliases {
+		err := p.detach(split)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	p.edits = nil
+
+	err := p.setFromNode(split, value, p.root())
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	err = p.apply()
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+func (p *Path) setFromNode(split []string, value *yaml.Node, n *yaml.Node) error {
+	// Aliases are replaced themselves when splitting them off, and their
+	// anchored nodes are modified otherwise.
+	if len(split) == 0 {
+		if !p.splitAliases {
+			n = resolve(n)
+		}
+
+		err := p.replace(n, value)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	}
+
+	if n.Kind == yaml.AliasNode {
+		err := p.setFromNode(split, value, n.Alias)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	}
+
+	if isMarker(split[0]) {
+		err := p.setMarked(split, value, n)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	}
+
+	key := p.unescapeKey(split[0])
+	n = p.listed(n, key)
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		i := index(n, key)
+		if o := origin(n, key); o != nil {
+			err := p.setFromNode(split, value, o)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+
+			return nil
+		}
+		if i == -1 {
+			c, err := create(split[1:], value, p.unescapeKey)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+
+			err = p.insert(n, key, c)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+
+			return nil
+		}
+
+		err := p.setFromNode(split[1:], value, n.Content[i+1])
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	case yaml.SequenceNode:
+		if pr, ok := predicateFromKey(key); ok {
+			l := pr.indices(n)
+			if len(l) == 0 {
+				c, err := create(split[1:], value, p.unescapeKey)
+				if err != nil {
+					return tracer.Mask(err)
+				}
+
+				c, err = pr.item(c)
+				if err != nil {
+					return tracer.Mask(err)
+				}
+
+				err = p.add(n, len(n.Content), c)
+				if err != nil {
+					return tracer.Mask(err)
+				}
+
+				return nil
+			}
+
+			// Items replaced as a whole keep the predicate's key, so that they
+			// are still selected by the predicate.
+			v := value
+			if len(split) == 1 {
+				var err error
+				v, err = pr.item(value)
+				if err != nil {
+					return tracer.Mask(err)
+				}
+			}
+
+			for _, i := range l {
+				err := p.setFromNode(split[1:], v, n.Content[i])
+				if err != nil {
+					return tracer.Mask(err)
+				}
+			}
+
+			return nil
+		}
+
+		if i, ok := positionFromKey(key, len(n.Content)); ok {
+			if i > len(n.Content) {
+				return tracer.Maskf(notFoundError, "key '%s'", key)
+			}
+
+			c, err := create(split[1:], value, p.unescapeKey)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+
+			err = p.add(n, i, c)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+
+			return nil
+		}
+
+		index, err := indexFromKey(key)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+		if index < 0 {
+			index += len(n.Content)
+		}
+
+		if index < 0 || index >= len(n.Content) {
+			return tracer.Maskf(notFoundError, "key '%s'", key)
+		}
+
+		err = p.setFromNode(split[1:], value, n.Content[index])
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	}
+
+	// Create new elements when the existing value is empty.
+	if n.Tag == "!!null" || (n.Tag == "!!str" && n.Value == "") {
+		c, err := create(split, value, p.unescapeKey)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		err = p.replace(n, c)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	}
+
+	// Modify the document embedded in the existing string value.
+	{
+		modify := func(e *Path) error {
+			return e.set(split, value)
+		}
+
+		err := p.setEmbedded(n, key, modify)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// setEmbedded applies the given modification to the document embedded in the
+// given string node and writes the modified document back into the string
+// node.
+func (p *Path) setEmbedded(n *yaml.Node, key string, modify func(e *Path) error) error {
+	e, err := p.embedded(n)
+	if err != nil {
+		return tracer.Maskf(notFoundError, "key '%s'", key)
+	}
+
+	err = p.writeEmbedded(n, e, modify)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+// writeEmbedded applies the given modification to the given Path describing
+// the document embedded in the given string node and writes the modified
+// document back into the string node.
+func (p *Path) writeEmbedded(n *yaml.Node, e *Path, modify func(e *Path) error) error {
+	err := modify(e)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	b, err := e.embeddedBytes()
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	var s yaml.Node
+	err = s.Encode(string(b))
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	err = p.replace(n, &s)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+// setWith sets the values produced by the given function under the given
+// path. The function receives the existing value of every location modified,
+// which is nil if the location does not exist yet.
+func (p *Path) setWith(path string, value func(existing *yaml.Node) (*yaml.Node, error)) error {
+	split, ok, err := p.single(path)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	if ok {
+		var existing *yaml.Node
+		if l := p.matchFromNode(split, p.root(), nil); len(l) != 0 {
+			existing = l[0].node
+		}
+
+		n, err := value(existing)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		err = p.set(split, n)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	}
+
+	matches, err := p.modifiable(path)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	// Recursive wildcards match the document itself, which cannot be
+	// replaced. Nothing is set in this case, instead of setting all the
+	// values matched before.
+	for _, m := range matches {
+		if len(m.split) == 0 {
+			return tracer.Maskf(invalidQueryError, "key '%s' must not match the document root", path)
+		}
+	}
+
+	for _, m := range matches {
+		n, err := value(m.node)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		err = p.set(m.split, n)
+		if err != nil {
+			return tracer.Mask(err)
 		}
+	}
+
+	return nil
+}
 
-		jsonBytes, err = json.Marshal(jsonMap)
+// single returns the path segments of the given path in case the path
+// addresses a single location, which does not have to exist yet. Paths
+// containing wildcards, and JSONPath queries which are not singular, address
+// any amount of existing locations instead.
+func (p *Path) single(path string) ([]string, bool, error) {
+	if p.queryLanguage == QueryLanguageJSONPath {
+		q, err := parseQuery(path)
 		if err != nil {
 			return nil, false, tracer.Mask(err)
 		}
 
-		return jsonBytes, false, nil
+		if !q.singular() {
+			return nil, false, nil
+		}
+
+		return q.split(p.separator), true, nil
+	}
+
+	split := p.split(path)
+
+	return split, !isPattern(split), nil
+}
+
+// split returns the segments of the given path. Separators within brackets do
+// not split the path, so that predicates like [name=a.b] stay intact. Brackets
+// attached to a key, like containers[0], are segments of their own.
+func (p *Path) split(path string) []string {
+	path = p.escapeKey(path)
+
+	var l []string
+	var depth int
+	var last int
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '[':
+			depth++
+		case path[i] == ']' && depth > 0:
+			depth--
+		case depth == 0 && strings.HasPrefix(path[i:], p.separator):
+			l = append(l, brackets(path[last:i])...)
+			last = i + len(p.separator)
+			i = last - 1
+		}
+	}
+
+	l = append(l, brackets(path[last:])...)
+
+	return l
+}
+
+func (p *Path) unescapeKey(key string) string {
+	return placeholderExpression.ReplaceAllString(key, p.separator)
+}
+
+func containsString(list []string, item string) bool {
+	for _, l := range list {
+		if l == item {
+			return true
+		}
+	}
+
+	return false
+}
+
+// brackets splits brackets attached to the given path segment into segments
+// of their own, e.g. containers[0] becomes containers and [0].
+func brackets(s string) []string {
+	m := bracketExpression.FindStringSubmatch(s)
+	if m == nil {
+		return []string{s}
+	}
+
+	var l []string
+	if m[1] != "" {
+		l = append(l, m[1])
+	}
+
+	return append(l, segmentExpression.FindAllString(m[2], -1)...)
+}
+
+// create returns the structure described by the given path, holding the given
+// value at its end. Index segments create lists holding a single item, which
+// is why only the indices of the first and the last item are valid here.
+func create(split []string, value *yaml.Node, unescape func(string) string) (*yaml.Node, error) {
+	if len(split) == 0 {
+		return value, nil
+	}
+
+	key := unescape(split[0])
+
+	rest, err := create(split[1:], value, unescape)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	if pr, ok := predicateFromKey(key); ok {
+		item, err := pr.item(rest)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		n := &yaml.Node{
+			Kind:    yaml.SequenceNode,
+			Tag:     "!!seq",
+			Content: []*yaml.Node{item},
+		}
+
+		return n, nil
+	}
+
+	if i, ok := positionFromKey(key, 0); ok {
+		if i != 0 {
+			return nil, tracer.Maskf(notFoundError, "key '%s'", key)
+		}
+
+		n := &yaml.Node{
+			Kind:    yaml.SequenceNode,
+			Tag:     "!!seq",
+			Content: []*yaml.Node{rest},
+		}
+
+		return n, nil
+	}
+
+	if i, err := indexFromKey(key); err == nil {
+		if i != 0 && i != -1 {
+			return nil, tracer.Maskf(notFoundError, "key '%s'", key)
+		}
+
+		n := &yaml.Node{
+			Kind:    yaml.SequenceNode,
+			Tag:     "!!seq",
+			Content: []*yaml.Node{rest},
+		}
+
+		return n, nil
+	}
+
+	n := &yaml.Node{
+		Kind: yaml.MappingNode,
+		Tag:  "!!map",
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+			rest,
+		},
+	}
+
+	return n, nil
+}
+
+// index returns the index of the given key within the content of the given
+// mapping, or -1 if the key cannot be found.
+func index(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Tag != "!!merge" && m.Content[i].Value == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// indexFromKey returns the list index described by the given key, e.g. 2 for
+// [2]. Negative indices like [-1] count from the end of the list.
+func indexFromKey(key string) (int, error) {
+	ok := indexExpression.MatchString(key)
+	if !ok {
+		return 0, tracer.Maskf(invalidFormatError, key)
+	}
+
+	p := key[1 : len(key)-1]
+	i, err := strconv.Atoi(p)
+	if err != nil {
+		return 0, tracer.Mask(err)
+	}
+
+	return i, nil
+}
+
+func isJSON(b []byte) bool {
+	var l []interface{}
+	isList := json.Unmarshal(b, &l) == nil
+
+	var m map[string]interface{}
+	isObject := json.Unmarshal(b, &m) == nil
+
+	return isObject || isList
+}
+
+// lookup returns the value of the given key within the given mapping, taking
+// merge keys into account.
+func lookup(m *yaml.Node, key string) *yaml.Node {
+	for _, c := range pairs(m) {
+		if c[0].Value == key {
+			return c[1]
+		}
+	}
+
+	return nil
+}
+
+// positionFromKey returns the position at which a new item is added to a list
+// of the given length. The keys [+] and [append] add the item to the end of
+// the list. The key [+N] inserts the item at index N.
+func positionFromKey(key string, l int) (int, bool) {
+	if key == "[+]" || key == "[append]" {
+		return l, true
+	}
+
+	m := positionExpression.FindStringSubmatch(key)
+	if m == nil {
+		return 0, false
+	}
+
+	i, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+
+	return i, true
+}
+
+// pairs returns the key value pairs of the given mapping. Keys merged from
+// other mappings using merge keys are included, unless they are overwritten.
+func pairs(m *yaml.Node) [][2]*yaml.Node {
+	var l [][2]*yaml.Node
+	var merged []*yaml.Node
+
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		k := m.Content[i]
+		v := m.Content[i+1]
+
+		if k.Tag == "!!merge" {
+			v = resolve(v)
+			if v.Kind == yaml.SequenceNode {
+				merged = append(merged, v.Content...)
+			} else {
+				merged = append(merged, v)
+			}
+			continue
+		}
+
+		l = append(l, [2]*yaml.Node{k, v})
+	}
+
+	for _, v := range merged {
+		v = resolve(v)
+		if v.Kind != yaml.MappingNode {
+			continue
+		}
+
+		for _, c := range pairs(v) {
+			if index(m, c[0].Value) == -1 {
+				l = append(l, c)
+			}
+		}
+	}
+
+	return l
+}
+
+// resolve returns the node the given alias refers to.
+func resolve(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+
+	return n
+}
+
+// toInterface returns the value of the given node. Numbers are returned as
+// json.Number holding the original text of the scalar, so that neither large
+// integers nor the precision of floats get lost when encoding them again.
+func toInterface(n *yaml.Node) (interface{}, error) {
+	n = resolve(n)
+
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+
+		return toInterface(n.Content[0])
+	case yaml.MappingNode:
+		m := map[string]interface{}{}
+		for _, c := range pairs(n) {
+			v, err := toInterface(c[1])
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+
+			m[c[0].Value] = v
+		}
+
+		return m, nil
+	case yaml.SequenceNode:
+		l := []interface{}{}
+		for _, c := range n.Content {
+			v, err := toInterface(c)
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+
+			l = append(l, v)
+		}
+
+		return l, nil
+	}
+
+	if (n.Tag == "!!int" || n.Tag == "!!float") && numberExpression.MatchString(n.Value) {
+		return json.Number(n.Value), nil
+	}
+
+	var v interface{}
+	err := n.Decode(&v)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return v, nil
+}
+
+// toNode parses the given bytes, which must describe a single YAML or JSON
+// document containing either an object or a list.
+func toNode(b []byte) (*yaml.Node, error) {
+	n, err := unmarshal(b)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	if n.Kind != yaml.DocumentNode || len(n.Content) == 0 {
+		return nil, tracer.Mask(invalidFormatError)
+	}
+	if n.Content[0].Kind != yaml.MappingNode && n.Content[0].Kind != yaml.SequenceNode {
+		return nil, tracer.Mask(invalidFormatError)
+	}
+
+	return n, nil
+}
+
+// unmarshal parses the given YAML or JSON value as document node. JSON is read
+// as JSON, since the YAML parser rejects some of its escape sequences, like
+// the escaped slash \/.
+func unmarshal(b []byte) (*yaml.Node, error) {
+	if json.Valid(b) {
+		n, _, err := parseJSON5(b)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		return n, nil
+	}
+
+	var n yaml.Node
+	err := yaml.Unmarshal(b, &n)
+	if err != nil {
+		return nil, tracer.Maskf(invalidFormatError, "%s", err.Error())
 	}
 
-	return nil, false, tracer.Mask(invalidFormatError)
+	return &n, nil
 }
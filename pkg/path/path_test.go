@@ -1,14 +1,25 @@
 package path
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/xh3b4sd/dsm/pkg/sops"
 )
 
 func Test_Service_All(t *testing.T) {
 	testCases := []struct {
 		InputBytes []byte
+		Base64     bool
+		Format     string
 		Expected   []string
 	}{
 		// Test case 1, ensure a single unnested path can be found.
@@ -214,6 +225,39 @@ func Test_Service_All(t *testing.T) {
 				"k1.k2.k3",
 			},
 		},
+
+		// Test case 14, ensure the paths of base64 encoded documents are
+		// returned if configured.
+		{
+			InputBytes: []byte(`data:
+  config.json: eyJlbmRwb2ludCI6ICJodHRwOi8vYXBwIiwgInBvcnQiOiA4MH0=
+  password: c2VjcmV0
+`),
+			Base64: true,
+			Expected: []string{
+				"data.config\\.json.endpoint",
+				"data.config\\.json.port",
+				"data.password",
+			},
+		},
+
+		// Test case 15, ensure the paths of HCL attributes and blocks are found
+		// under the types and labels of the blocks.
+		{
+			InputBytes: []byte(`region = "eu-central-1"
+
+module "vpc" {
+  source = "./vpc"
+  tags   = { team = "platform" }
+}
+`),
+			Format: FormatHCL,
+			Expected: []string{
+				"module.vpc.source",
+				"module.vpc.tags.team",
+				"region",
+			},
+		},
 	}
 
 	for i, tc := range testCases {
@@ -222,7 +266,9 @@ func Test_Service_All(t *testing.T) {
 		var p *Path
 		{
 			c := Config{
-				Bytes: tc.InputBytes,
+				Base64: tc.Base64,
+				Bytes:  tc.InputBytes,
+				Format: tc.Format,
 			}
 
 			p, err = New(c)
@@ -241,256 +287,431 @@ func Test_Service_All(t *testing.T) {
 	}
 }
 
-func Test_Service_Get(t *testing.T) {
+func Test_Service_ApplyPatch(t *testing.T) {
 	testCases := []struct {
 		InputBytes []byte
-		Path       string
-		Expected   interface{}
+		Patch      []byte
+		Expected   []byte
 	}{
-		// Test case 1, ensure the value of an unnested path can be returned.
+		// Test case 1, ensure values can be replaced and list items can be
+		// appended.
 		{
-			InputBytes: []byte(`{
-  "k1": "v1"
-}`),
-			Path:     "k1",
-			Expected: "v1",
+			InputBytes: []byte(`spec:
+  replicas: 2 # replicas
+  containers:
+  - name: app
+`),
+			Patch: []byte(`[
+  {"op": "replace", "path": "/spec/replicas", "value": 3},
+  {"op": "add", "path": "/spec/containers/-", "value": {"name": "sidecar"}}
+]`),
+			Expected: []byte(`spec:
+  replicas: 3 # replicas
+  containers:
+  - name: app
+  - name: sidecar
+`),
 		},
 
-		// Test case 2, ensure the value of a nested path can be returned.
+		// Test case 2, ensure list items can be inserted and keys containing
+		// escaped characters can be removed.
+		{
+			InputBytes: []byte(`containers:
+- name: app
+labels:
+  app.kubernetes.io/name: n1
+  team: t1
+`),
+			Patch: []byte(`[
+  {"op": "add", "path": "/containers/0", "value": {"name": "init"}},
+  {"op": "remove", "path": "/labels/app.kubernetes.io~1name"}
+]`),
+			Expected: []byte(`containers:
+- name: init
+- name: app
+labels:
+  team: t1
+`),
+		},
+
+		// Test case 3, ensure values can be copied and moved after a successful
+		// test.
 		{
 			InputBytes: []byte(`{
-  "k1": {
-    "k2": {
-      "k3": "v3"
-    }
+  "k1": "v1",
+  "k2": {
+    "k3": "v3"
+  }
+}`),
+			Patch: []byte(`[
+  {"op": "test", "path": "/k1", "value": "v1"},
+  {"op": "copy", "from": "/k2", "path": "/k4"},
+  {"op": "move", "from": "/k1", "path": "/k2/k1"}
+]`),
+			Expected: []byte(`{
+  "k2": {
+    "k3": "v3",
+    "k1": "v1"
+  },
+  "k4": {
+    "k3": "v3"
   }
 }`),
-			Path:     "k1.k2.k3",
-			Expected: "v3",
 		},
+	}
 
-		// Test case 3, ensure the value of a list path can be returned.
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
 		{
-			InputBytes: []byte(`{
-  "k1": [
-    {
-      "k2": "v2"
-    }
-  ]
-}`),
-			Path:     "k1.[0].k2",
-			Expected: "v2",
+			c := Config{
+				Bytes: tc.InputBytes,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		err = p.ApplyPatch(tc.Patch)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		output, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(tc.Expected, output) {
+			t.Fatal("test", i+1, "expected", string(tc.Expected), "got", string(output))
+		}
+	}
+}
+
+func Test_Service_ApplyPatch_Error(t *testing.T) {
+	testCases := []struct {
+		Patch        []byte
+		ErrorMatcher func(error) bool
+	}{
+		// Test case 1, ensure a failing test aborts the whole patch.
+		{
+			Patch: []byte(`[
+  {"op": "replace", "path": "/k1", "value": "modified"},
+  {"op": "test", "path": "/k2", "value": "v1"}
+]`),
+			ErrorMatcher: IsTestFailed,
 		},
 
-		// Test case 4, ensure the value of single paths with unnested inline JSON
-		// objects can be returned.
+		// Test case 2, ensure values cannot be added to missing parents.
 		{
-			InputBytes: []byte(`{
-  "k1": ` + strconv.Quote(`{
-    "k2": "v2"
-  }`) + `
-}`),
-			Path:     "k1.k2",
-			Expected: "v2",
+			Patch: []byte(`[
+  {"op": "remove", "path": "/k1"},
+  {"op": "add", "path": "/k3/k4", "value": "v4"}
+]`),
+			ErrorMatcher: IsNotFound,
 		},
 
-		// Test case 5, ensure the value of single paths with nested inline JSON
-		// objects can be returned.
+		// Test case 3, ensure unknown operations are rejected.
 		{
-			InputBytes: []byte(`{
-  "k1": ` + strconv.Quote(`{
-    "k2": {
-      "k3": "v3"
-    }
-  }`) + `
-}`),
-			Path:     "k1.k2.k3",
-			Expected: "v3",
+			Patch: []byte(`[
+  {"op": "replace", "path": "/k1", "value": "modified"},
+  {"op": "merge", "path": "/k1", "value": "v1"}
+]`),
+			ErrorMatcher: IsInvalidPatch,
 		},
+	}
 
-		// Test case 6, ensure the value of single paths with unnested inline JSON
-		// lists can be returned.
+	for i, tc := range testCases {
+		var err error
+
+		input := []byte(`k1: v1 # comment
+k2: v2
+`)
+
+		var p *Path
+		{
+			c := Config{
+				Bytes: input,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		err = p.ApplyPatch(tc.Patch)
+		if !tc.ErrorMatcher(err) {
+			t.Fatal("test", i+1, "expected", true, "got", false)
+		}
+
+		output, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(input, output) {
+			t.Fatal("test", i+1, "expected", string(input), "got", string(output))
+		}
+	}
+}
+
+func Test_Service_Delete(t *testing.T) {
+	testCases := []struct {
+		InputBytes []byte
+		Path       string
+		Format     string
+		Expected   []byte
+	}{
+		// Test case 1, ensure an unnested key can be deleted.
 		{
 			InputBytes: []byte(`{
-  "k1": ` + strconv.Quote(`[
-    {
-      "k2": "v2"
-    }
-  ]`) + `
+  "k1": "v1",
+  "k2": "v2"
+}`),
+			Path: "k1",
+			Expected: []byte(`{
+  "k2": "v2"
 }`),
-			Path:     "k1.[0].k2",
-			Expected: "v2",
 		},
 
-		// Test case 7, ensure the value of multiple paths with unnested inline JSON
-		// objects can be returned.
+		// Test case 2, ensure a list item can be deleted.
 		{
 			InputBytes: []byte(`{
-  "k1": ` + strconv.Quote(`{
-    "k2": "v2",
-    "k3": "v3"
-  }`) + `
+  "k1": [
+    "v1",
+    "v2"
+  ]
+}`),
+			Path: "k1.[0]",
+			Expected: []byte(`{
+  "k1": [
+    "v2"
+  ]
 }`),
-			Path:     "k1.k2",
-			Expected: "v2",
 		},
 
-		// Test case 8, ensure the value of multiple paths with nested inline JSON
-		// objects can be returned.
+		// Test case 3, ensure a nested key can be deleted from YAML without
+		// changing anything else.
 		{
-			InputBytes: []byte(`{
-  "k1": ` + strconv.Quote(`{
-    "k2": {
-      "k3": "v3"
-    },
-    "k4": {
-      "k5": "v5"
-    }
-  }`) + `
-}`),
-			Path:     "k1.k2.k3",
-			Expected: "v3",
+			InputBytes: []byte(`# comment
+k1: v1
+k2:
+  k3: v3 # comment
+  k4:
+    k5: v5
+
+k6: v6
+`),
+			Path: "k2.k4",
+			Expected: []byte(`# comment
+k1: v1
+k2:
+  k3: v3 # comment
+
+k6: v6
+`),
 		},
 
-		// Test case 9, ensure the value of multiple paths with unnested inline JSON
-		// lists can be returned.
+		// Test case 4, ensure the last key of an object results in an empty
+		// object.
 		{
-			InputBytes: []byte(`{
-  "k1": ` + strconv.Quote(`[
-    {
-      "k2": "v2"
-    },
-    {
-      "k3": "v3"
-    }
-  ]`) + `
-}`),
-			Path:     "k1.[0].k2",
-			Expected: "v2",
+			InputBytes: []byte(`k1:
+  k2: v2
+k3: v3
+`),
+			Path: "k1.k2",
+			Expected: []byte(`k1: {}
+k3: v3
+`),
 		},
 
-		// Test case 10, ensure the value of single paths with unnested inline YAML
-		// objects can be returned.
+		// Test case 5, ensure list items can be deleted from YAML.
 		{
-			InputBytes: []byte(`{
-  "k1": "k2: v2"
-}`),
-			Path:     "k1.k2",
-			Expected: "v2",
+			InputBytes: []byte(`k1:
+- name: n1
+  value: v1
+- name: n2
+  value: v2
+`),
+			Path: "k1.[0]",
+			Expected: []byte(`k1:
+- name: n2
+  value: v2
+`),
 		},
 
-		// Test case 11, ensure the value of single paths with nested inline YAML
-		// objects can be returned.
+		// Test case 6, ensure the first key of an object within a list can be
+		// deleted from YAML.
 		{
-			InputBytes: []byte(`{
-  "k1": ` + strconv.Quote(`k2:
-  k3: v3`) + `
-}`),
-			Path:     "k1.k2.k3",
-			Expected: "v3",
+			InputBytes: []byte(`k1:
+- name: n1
+  value: v1
+`),
+			Path: "k1.[0].name",
+			Expected: []byte(`k1:
+- value: v1
+`),
 		},
 
-		// Test case 12, ensure the value of single paths with unnested inline YAML
-		// lists can be returned.
+		// Test case 7, ensure keys and list items can be deleted from flow
+		// collections.
 		{
-			InputBytes: []byte(`{
-  "k1": "- k2: v2"
-}`),
-			Path:     "k1.[0].k2",
-			Expected: "v2",
+			InputBytes: []byte(`k1: {k2: v2, k3: [v3, v4]}
+`),
+			Path: "k1.k3.[1]",
+			Expected: []byte(`k1: {k2: v2, k3: [v3]}
+`),
 		},
 
-		// Test case 13, ensure the value of multiple paths with unnested inline
-		// YAML objects can be returned.
+		// Test case 8, ensure keys can be deleted from inline YAML objects.
 		{
 			InputBytes: []byte(`{
   "k1": ` + strconv.Quote(`k2: v2
 k3: v3`) + `
 }`),
-			Path:     "k1.k2",
-			Expected: "v2",
+			Path: "k1.k3",
+			Expected: []byte(`{
+  "k1": ` + strconv.Quote(`k2: v2`) + `
+}`),
 		},
 
-		// Test case 14, ensure the value of multiple paths with nested inline YAML
-		// objects can be returned.
+		// Test case 9, ensure all keys matched by a wildcard can be deleted.
 		{
-			InputBytes: []byte(`{
-  "k1": ` + strconv.Quote(`k2:
-  k3: v3
-k4:
-  k5: v5`) + `
-}`),
-			Path:     "k1.k2.k3",
-			Expected: "v3",
+			InputBytes: []byte(`k1:
+- name: n1
+  debug: true
+- name: n2
+- name: n3
+  debug: false
+`),
+			Path: "k1.*.debug",
+			Expected: []byte(`k1:
+- name: n1
+- name: n2
+- name: n3
+`),
 		},
 
-		// Test case 15, ensure the value of multiple paths with unnested inline
-		// YAML lists can be returned.
+		// Test case 10, ensure list items selected by a predicate can be
+		// deleted.
 		{
-			InputBytes: []byte(`{
-  "k1": ` + strconv.Quote(`- k2: v2
-- k3: v3`) + `
-}`),
-			Path:     "k1.[0].k2",
-			Expected: "v2",
+			InputBytes: []byte(`env:
+- name: LOG_LEVEL
+  value: info
+- name: PORT
+  value: "8080"
+`),
+			Path: "env[name=LOG_LEVEL]",
+			Expected: []byte(`env:
+- name: PORT
+  value: "8080"
+`),
 		},
 
-		// Test case 16, ensure the value of paths with separators inside keys can
-		// be returned.
+		// Test case 11, ensure the last list item can be deleted using a
+		// negative index.
 		{
-			InputBytes: []byte(`{
-  "k1": {
-    "k2.k3": {
-      "k4": "v4"
-    }
-  }
-}`),
-			Path:     `k1.k2\.k3.k4`,
-			Expected: "v4",
+			InputBytes: []byte(`k1: [v1, v2, v3]
+`),
+			Path: "k1.[-1]",
+			Expected: []byte(`k1: [v1, v2]
+`),
 		},
 
-		// Test case 17, ensure the value of a single unnested path can be returned,
-		// even though its value is empty.
+		// Test case 12, ensure keys can be deleted from TOML documents without
+		// changing anything else.
 		{
-			InputBytes: []byte(`{
-  "k1": ""
-}`),
-			Path:     "k1",
-			Expected: "",
+			InputBytes: []byte(`# comment
+[package]
+name = "dsm" # comment
+edition = 2021
+
+[dependencies]
+serde = "1.0"
+`),
+			Path: "package.edition",
+			Expected: []byte(`# comment
+[package]
+name = "dsm" # comment
+
+[dependencies]
+serde = "1.0"
+`),
 		},
 
-		// Test case 18, ensure the value of a single nested path can be returned,
-		// even though its value is empty.
+		// Test case 13, ensure keys can be deleted from dotenv files without
+		// changing anything else.
 		{
-			InputBytes: []byte(`{
-  "k1": {
-    "k2": {
-      "k3": ""
-    }
-  }
-}`),
-			Path:     "k1.k2.k3",
-			Expected: "",
+			InputBytes: []byte(`# comment
+K1=v1
+K2=v2 # comment
+`),
+			Path:   "K1",
+			Format: FormatDotenv,
+			Expected: []byte(`# comment
+K2=v2 # comment
+`),
 		},
 
-		// Test case 19, ensure the value of multiple paths(array index > 9) with unnested inline
-		// YAML lists can be returned.
+		// Test case 14, ensure XML elements can be deleted together with their
+		// line.
 		{
-			InputBytes: []byte(`{
-  "k": ` + strconv.Quote(`- k0: v0
-- k1: v1
-- k2: v2
-- k3: v3
-- k4: v4
-- k5: v5
-- k6: v6
-- k7: v7
-- k8: v8
-- k9: v9
-- k10: v10`) + `
-}`),
-			Path:     "k.[10].k10",
-			Expected: "v10",
+			InputBytes: []byte(`<modules>
+  <module>core</module>
+  <module>web</module> <!-- comment -->
+</modules>
+`),
+			Path: "modules.module.[0]",
+			Expected: []byte(`<modules>
+  <module>web</module> <!-- comment -->
+</modules>
+`),
+		},
+
+		// Test case 15, ensure HCL blocks can be deleted by their labels.
+		{
+			InputBytes: []byte(`# comment
+
+module "vpc" {
+  source = "./vpc"
+}
+module "db" {
+  source = "./db"
+}
+`),
+			Path:   "module.vpc",
+			Format: FormatHCL,
+			Expected: []byte(`# comment
+
+module "db" {
+  source = "./db"
+}
+`),
+		},
+
+		// Test case 16, ensure members of JSON documents with comments are
+		// deleted together with their line, keeping the comments of all other
+		// lines.
+		{
+			InputBytes: []byte(`// comment
+{
+  "compilerOptions": {
+    "target": "ES2020", // comment
+    "strict": true, // comment
+  },
+}
+`),
+			Path:   "compilerOptions.strict",
+			Format: FormatJSON5,
+			Expected: []byte(`// comment
+{
+  "compilerOptions": {
+    "target": "ES2020", // comment
+  },
+}
+`),
 		},
 	}
 
@@ -500,7 +721,8 @@ k4:
 		var p *Path
 		{
 			c := Config{
-				Bytes: tc.InputBytes,
+				Bytes:  tc.InputBytes,
+				Format: tc.Format,
 			}
 
 			p, err = New(c)
@@ -509,17 +731,22 @@ k4:
 			}
 		}
 
-		output, err := p.Get(tc.Path)
+		err = p.Delete(tc.Path)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		output, err := p.OutputBytes()
 		if err != nil {
 			t.Fatal("test", i+1, "expected", nil, "got", err)
 		}
 		if !reflect.DeepEqual(tc.Expected, output) {
-			t.Fatal("test", i+1, "expected", tc.Expected, "got", output)
+			t.Fatal("test", i+1, "expected", string(tc.Expected), "got", string(output))
 		}
 	}
 }
 
-func Test_Service_Get_Error(t *testing.T) {
+func Test_Service_Delete_Error(t *testing.T) {
 	testCases := []struct {
 		InputBytes   []byte
 		Path         string
@@ -535,36 +762,59 @@ func Test_Service_Get_Error(t *testing.T) {
     }
   ]
 }`),
-			Path:         "k1.[1].k2",
+			Path:         "k1.[1]",
 			ErrorMatcher: IsNotFound,
 		},
 
-		// Test 2, when there is k1 at the beginning of the path key k3 cannot be
+		// Test 2, when there is k2 at the end of the path key k3 cannot be
 		// found.
 		{
-			InputBytes: []byte(`{
-  "k1": [
-    {
-      "k2": "v2"
-    }
-  ]
-}`),
-			Path:         "k3.[0].k2",
+			InputBytes: []byte(`k1:
+  k2: v2
+`),
+			Path:         "k1.k3",
 			ErrorMatcher: IsNotFound,
 		},
 
-		// Test 3, when there is k2 at the end of the path key k3 cannot be
-		// found.
+		// Test 3, the recursive wildcard matches the root of block style
+		// documents, which cannot be deleted.
+		{
+			InputBytes: []byte(`k1:
+  k2: v2
+`),
+			Path:         "**",
+			ErrorMatcher: IsInvalidQuery,
+		},
+
+		// Test 4, the recursive wildcard matches the root of flow style
+		// documents, which cannot be deleted.
+		{
+			InputBytes: []byte(`{k1: [v1, v2]}
+`),
+			Path:         "**",
+			ErrorMatcher: IsInvalidQuery,
+		},
+
+		// Test 5, the recursive wildcard matches the root of lists, which
+		// cannot be deleted.
+		{
+			InputBytes: []byte(`- k1: v1
+- k2: v2
+`),
+			Path:         "**",
+			ErrorMatcher: IsInvalidQuery,
+		},
+
+		// Test 6, the recursive wildcard matches the root of JSON documents,
+		// which cannot be deleted.
 		{
 			InputBytes: []byte(`{
-  "k1": [
-    {
-      "k2": "v2"
-    }
-  ]
+  "k1": {
+    "k2": "v2"
+  }
 }`),
-			Path:         "k1.[0].k3",
-			ErrorMatcher: IsNotFound,
+			Path:         "**",
+			ErrorMatcher: IsInvalidQuery,
 		},
 	}
 
@@ -583,250 +833,125 @@ func Test_Service_Get_Error(t *testing.T) {
 			}
 		}
 
-		_, err = p.Get(tc.Path)
+		b, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		err = p.Delete(tc.Path)
 		if !tc.ErrorMatcher(err) {
 			t.Fatal("test", i+1, "expected", true, "got", false)
 		}
+
+		o, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if string(o) != string(b) {
+			t.Fatal("test", i+1, "expected", string(b), "got", string(o))
+		}
 	}
 }
 
-func Test_Service_Set(t *testing.T) {
+func Test_Service_Get(t *testing.T) {
 	testCases := []struct {
 		InputBytes []byte
 		Path       string
-		Value      string
-		Expected   []byte
+		Base64     bool
+		Format     string
+		Template   bool
+		Expected   interface{}
 	}{
-		// Test 1,
+		// Test case 1, ensure the value of an unnested path can be returned.
 		{
 			InputBytes: []byte(`{
   "k1": "v1"
 }`),
-			Path:  "k1",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": "modified"
-}`),
+			Path:     "k1",
+			Expected: "v1",
 		},
 
-		// Test 2,
+		// Test case 2, ensure the value of a nested path can be returned.
 		{
 			InputBytes: []byte(`{
-  "k1": "v1",
-  "k2": "v2"
-}`),
-			Path:  "k1",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": "modified",
-  "k2": "v2"
+  "k1": {
+    "k2": {
+      "k3": "v3"
+    }
+  }
 }`),
+			Path:     "k1.k2.k3",
+			Expected: "v3",
 		},
 
-		// Test 3,
+		// Test case 3, ensure the value of a list path can be returned.
 		{
 			InputBytes: []byte(`{
-  "k1": {
-    "k2": "v2"
-  }
-}`),
-			Path:  "k1.k2",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": {
-    "k2": "modified"
-  }
+  "k1": [
+    {
+      "k2": "v2"
+    }
+  ]
 }`),
+			Path:     "k1.[0].k2",
+			Expected: "v2",
 		},
 
-		// Test 4,
+		// Test case 4, ensure the value of single paths with unnested inline JSON
+		// objects can be returned.
 		{
 			InputBytes: []byte(`{
-  "k1": {
+  "k1": ` + strconv.Quote(`{
     "k2": "v2"
-  },
-  "k3": "v3"
-}`),
-			Path:  "k1.k2",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": {
-    "k2": "modified"
-  },
-  "k3": "v3"
+  }`) + `
 }`),
+			Path:     "k1.k2",
+			Expected: "v2",
 		},
 
-		// Test 5,
+		// Test case 5, ensure the value of single paths with nested inline JSON
+		// objects can be returned.
 		{
 			InputBytes: []byte(`{
-  "k1": {
+  "k1": ` + strconv.Quote(`{
     "k2": {
       "k3": "v3"
     }
-  }
-}`),
-			Path:  "k1.k2.k3",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": {
-    "k2": {
-      "k3": "modified"
-    }
-  }
+  }`) + `
 }`),
+			Path:     "k1.k2.k3",
+			Expected: "v3",
 		},
 
-		// Test 6,
-		{
-			InputBytes: []byte(`[
-  {
-    "k1": "v1"
-  }
-]`),
-			Path:  "[0].k1",
-			Value: "modified",
-			Expected: []byte(`[
-  {
-    "k1": "modified"
-  }
-]`),
-		},
-
-		// Test 7,
+		// Test case 6, ensure the value of single paths with unnested inline JSON
+		// lists can be returned.
 		{
 			InputBytes: []byte(`{
-  "k1": [
+  "k1": ` + strconv.Quote(`[
     {
       "k2": "v2"
     }
-  ]
-}`),
-			Path:  "k1.[0].k2",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": [
-    {
-      "k2": "modified"
-    }
-  ]
+  ]`) + `
 }`),
+			Path:     "k1.[0].k2",
+			Expected: "v2",
 		},
 
-		// Test 8,
+		// Test case 7, ensure the value of multiple paths with unnested inline JSON
+		// objects can be returned.
 		{
 			InputBytes: []byte(`{
-  "k1": [
-    [
-      {
-        "k2": "v2"
-      }
-    ],
-    [
-      {
-        "k3": "v3"
-      }
-    ]
-  ]
-}`),
-			Path:  "k1.[1].[0].k3",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": [
-    [
-      {
-        "k2": "v2"
-      }
-    ],
-    [
-      {
-        "k3": "modified"
-      }
-    ]
-  ]
-}`),
-		},
-
-		// Test case 9, ensure the value of single paths with unnested inline JSON
-		// objects can be modified.
-		{
-			InputBytes: []byte(`{
-  "k1": ` + strconv.Quote(`{
-    "k2": "v2"
-  }`) + `
-}`),
-			Path:  "k1.k2",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": ` + strconv.Quote(`{
-  "k2": "modified"
-}`) + `
-}`),
-		},
-
-		// Test case 10, ensure the value of single paths with nested inline JSON
-		// objects can be modified.
-		{
-			InputBytes: []byte(`{
-  "k1": ` + strconv.Quote(`{
-    "k2": {
-      "k3": "v3"
-    }
-  }`) + `
-}`),
-			Path:  "k1.k2.k3",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": ` + strconv.Quote(`{
-  "k2": {
-    "k3": "modified"
-  }
-}`) + `
-}`),
-		},
-
-		// Test case 11, ensure the value of single paths with unnested inline JSON
-		// lists can be modified.
-		{
-			InputBytes: []byte(`{
-  "k1": ` + strconv.Quote(`[
-    {
-      "k2": "v2"
-    }
-  ]`) + `
-}`),
-			Path:  "k1.[0].k2",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": ` + strconv.Quote(`[
-  {
-    "k2": "modified"
-  }
-]`) + `
-}`),
-		},
-
-		// Test case 12, ensure the value of multiple paths with unnested inline
-		// JSON objects can be modified.
-		{
-			InputBytes: []byte(`{
-  "k1": ` + strconv.Quote(`{
-    "k2": "v2",
-    "k3": "v3"
-  }`) + `
-}`),
-			Path:  "k1.k2",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": ` + strconv.Quote(`{
-  "k2": "modified",
-  "k3": "v3"
-}`) + `
+  "k1": ` + strconv.Quote(`{
+    "k2": "v2",
+    "k3": "v3"
+  }`) + `
 }`),
+			Path:     "k1.k2",
+			Expected: "v2",
 		},
 
-		// Test case 13, ensure the value of multiple paths with nested inline JSON
-		// objects can be modified.
+		// Test case 8, ensure the value of multiple paths with nested inline JSON
+		// objects can be returned.
 		{
 			InputBytes: []byte(`{
   "k1": ` + strconv.Quote(`{
@@ -838,22 +963,12 @@ func Test_Service_Set(t *testing.T) {
     }
   }`) + `
 }`),
-			Path:  "k1.k2.k3",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": ` + strconv.Quote(`{
-  "k2": {
-    "k3": "modified"
-  },
-  "k4": {
-    "k5": "v5"
-  }
-}`) + `
-}`),
+			Path:     "k1.k2.k3",
+			Expected: "v3",
 		},
 
-		// Test case 14, ensure the value of multiple paths with unnested inline
-		// JSON lists can be modified.
+		// Test case 9, ensure the value of multiple paths with unnested inline JSON
+		// lists can be returned.
 		{
 			InputBytes: []byte(`{
   "k1": ` + strconv.Quote(`[
@@ -865,82 +980,54 @@ func Test_Service_Set(t *testing.T) {
     }
   ]`) + `
 }`),
-			Path:  "k1.[0].k2",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": ` + strconv.Quote(`[
-  {
-    "k2": "modified"
-  },
-  {
-    "k3": "v3"
-  }
-]`) + `
-}`),
+			Path:     "k1.[0].k2",
+			Expected: "v2",
 		},
 
-		// Test case 15, ensure the value of single paths with unnested inline YAML
-		// objects can be modified.
+		// Test case 10, ensure the value of single paths with unnested inline YAML
+		// objects can be returned.
 		{
 			InputBytes: []byte(`{
   "k1": "k2: v2"
 }`),
-			Path:  "k1.k2",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": ` + strconv.Quote(`k2: modified
-`) + `
-}`),
+			Path:     "k1.k2",
+			Expected: "v2",
 		},
 
-		// Test case 16, ensure the value of single paths with nested inline YAML
-		// objects can be modified.
+		// Test case 11, ensure the value of single paths with nested inline YAML
+		// objects can be returned.
 		{
 			InputBytes: []byte(`{
   "k1": ` + strconv.Quote(`k2:
   k3: v3`) + `
 }`),
-			Path:  "k1.k2.k3",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": ` + strconv.Quote(`k2:
-  k3: modified
-`) + `
-}`),
+			Path:     "k1.k2.k3",
+			Expected: "v3",
 		},
 
-		// Test case 17, ensure the value of single paths with unnested inline YAML
-		// lists can be modified.
+		// Test case 12, ensure the value of single paths with unnested inline YAML
+		// lists can be returned.
 		{
 			InputBytes: []byte(`{
   "k1": "- k2: v2"
 }`),
-			Path:  "k1.[0].k2",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": ` + strconv.Quote(`- k2: modified
-`) + `
-}`),
+			Path:     "k1.[0].k2",
+			Expected: "v2",
 		},
 
-		// Test case 18, ensure the value of multiple paths with unnested inline
-		// YAML objects can be modified.
+		// Test case 13, ensure the value of multiple paths with unnested inline
+		// YAML objects can be returned.
 		{
 			InputBytes: []byte(`{
   "k1": ` + strconv.Quote(`k2: v2
 k3: v3`) + `
 }`),
-			Path:  "k1.k2",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": ` + strconv.Quote(`k2: modified
-k3: v3
-`) + `
-}`),
+			Path:     "k1.k2",
+			Expected: "v2",
 		},
 
-		// Test case 19, ensure the value of multiple paths with nested inline YAML
-		// objects can be modified.
+		// Test case 14, ensure the value of multiple paths with nested inline YAML
+		// objects can be returned.
 		{
 			InputBytes: []byte(`{
   "k1": ` + strconv.Quote(`k2:
@@ -948,135 +1035,3583 @@ k3: v3
 k4:
   k5: v5`) + `
 }`),
-			Path:  "k1.k2.k3",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": ` + strconv.Quote(`k2:
-  k3: modified
-k4:
-  k5: v5
-`) + `
-}`),
+			Path:     "k1.k2.k3",
+			Expected: "v3",
 		},
 
-		// Test case 20, ensure the value of multiple paths with unnested inline
-		// YAML lists can be modified.
+		// Test case 15, ensure the value of multiple paths with unnested inline
+		// YAML lists can be returned.
 		{
 			InputBytes: []byte(`{
   "k1": ` + strconv.Quote(`- k2: v2
 - k3: v3`) + `
 }`),
-			Path:  "k1.[0].k2",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": ` + strconv.Quote(`- k2: modified
+			Path:     "k1.[0].k2",
+			Expected: "v2",
+		},
+
+		// Test case 16, ensure the value of paths with separators inside keys can
+		// be returned.
+		{
+			InputBytes: []byte(`{
+  "k1": {
+    "k2.k3": {
+      "k4": "v4"
+    }
+  }
+}`),
+			Path:     `k1.k2\.k3.k4`,
+			Expected: "v4",
+		},
+
+		// Test case 17, ensure the value of a single unnested path can be returned,
+		// even though its value is empty.
+		{
+			InputBytes: []byte(`{
+  "k1": ""
+}`),
+			Path:     "k1",
+			Expected: "",
+		},
+
+		// Test case 18, ensure the value of a single nested path can be returned,
+		// even though its value is empty.
+		{
+			InputBytes: []byte(`{
+  "k1": {
+    "k2": {
+      "k3": ""
+    }
+  }
+}`),
+			Path:     "k1.k2.k3",
+			Expected: "",
+		},
+
+		// Test case 19, ensure the value of multiple paths(array index > 9) with unnested inline
+		// YAML lists can be returned.
+		{
+			InputBytes: []byte(`{
+  "k": ` + strconv.Quote(`- k0: v0
+- k1: v1
+- k2: v2
 - k3: v3
-`) + `
+- k4: v4
+- k5: v5
+- k6: v6
+- k7: v7
+- k8: v8
+- k9: v9
+- k10: v10`) + `
 }`),
+			Path:     "k.[10].k10",
+			Expected: "v10",
+		},
+
+		// Test case 20, ensure large integers are returned without losing
+		// precision.
+		{
+			InputBytes: []byte(`{
+  "k1": 123456789012345678
+}`),
+			Path:     "k1",
+			Expected: json.Number("123456789012345678"),
+		},
+
+		// Test case 21, ensure floats are returned as written.
+		{
+			InputBytes: []byte(`k1: 1.10
+`),
+			Path:     "k1",
+			Expected: json.Number("1.10"),
+		},
+
+		// Test case 22, ensure integers exceeding 64 bits are returned as
+		// written from embedded documents.
+		{
+			InputBytes: []byte(`k1: '{"k2": 123456789012345678901234}'
+`),
+			Path:     "k1.k2",
+			Expected: json.Number("123456789012345678901234"),
+		},
+
+		// Test case 23, ensure base64 encoded strings can be decoded using the
+		// base64 marker.
+		{
+			InputBytes: []byte(`data:
+  password: c2VjcmV0
+`),
+			Path:     "data.password[base64]",
+			Expected: "secret",
+		},
+
+		// Test case 24, ensure base64 encoded documents are entered if
+		// configured.
+		{
+			InputBytes: []byte(`data:
+  config.json: eyJlbmRwb2ludCI6ICJodHRwOi8vYXBwIiwgInBvcnQiOiA4MH0=
+`),
+			Path:     "data.config\\.json.endpoint",
+			Base64:   true,
+			Expected: "http://app",
+		},
+
+		// Test case 25, ensure template actions are returned as written if
+		// configured.
+		{
+			InputBytes: []byte(`metadata:
+  name: {{ include "fullname" . }}
+  labels:
+    {{- include "labels" . | nindent 4 }}
+spec:
+  image: "{{ .Values.image }}:{{ .Values.tag }}"
+`),
+			Path:     "spec.image",
+			Template: true,
+			Expected: "{{ .Values.image }}:{{ .Values.tag }}",
+		},
+
+		// Test case 26, ensure values of TOML documents can be returned.
+		{
+			InputBytes: []byte(`[package]
+name = "dsm" # comment
+
+[dependencies]
+serde = { version = "1.0" }
+`),
+			Path:     "dependencies.serde.version",
+			Expected: "1.0",
+		},
+
+		// Test case 27, ensure keys of Java properties files containing the
+		// separator can be addressed using the escaped separator.
+		{
+			InputBytes: []byte(`# comment
+spring.datasource.url = jdbc:h2:mem:db
+server.port: 8080
+`),
+			Path:     `spring\.datasource\.url`,
+			Format:   FormatProperties,
+			Expected: "jdbc:h2:mem:db",
+		},
+
+		// Test case 28, ensure quoted values of dotenv files can be returned.
+		{
+			InputBytes: []byte(`# comment
+export DB_HOST=localhost # comment
+DB_PASS="se\"cret"
+`),
+			Path:     "DB_PASS",
+			Format:   FormatDotenv,
+			Expected: "se\"cret",
+		},
+
+		// Test case 29, ensure attributes of XML elements can be returned.
+		{
+			InputBytes: []byte(`<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Include="Newtonsoft.Json" Version="12.0.1" />
+  </ItemGroup>
+</Project>
+`),
+			Path:     "Project.ItemGroup.PackageReference[@Include=Newtonsoft.Json].@Version",
+			Expected: "12.0.1",
+		},
+
+		// Test case 30, ensure expressions of HCL documents which cannot be
+		// evaluated are returned as written.
+		{
+			InputBytes: []byte(`# comment
+resource "aws_instance" "web" {
+  ami   = data.aws_ami.ubuntu.id
+  count = 3
+}
+`),
+			Path:     "resource.aws_instance.web.ami",
+			Format:   FormatHCL,
+			Expected: "data.aws_ami.ubuntu.id",
+		},
+
+		// Test case 31, ensure values of JSON5 documents having comments,
+		// unquoted keys and trailing commas can be returned.
+		{
+			InputBytes: []byte(`{
+  // comment
+  packageRules: [
+    {matchPackageNames: ['foo'], enabled: false,},
+  ],
+  timeout: 0x10, /* comment */
+}
+`),
+			Path:     "packageRules.[0].matchPackageNames.[0]",
+			Format:   FormatJSON5,
+			Expected: "foo",
+		},
+
+		// Test case 32, ensure values of JSON documents having escaped slashes
+		// can be returned.
+		{
+			InputBytes: []byte(`{
+  "homepage": "https:\/\/example.com\/",
+  "name": "caf\u00e9"
+}
+`),
+			Path:     "homepage",
+			Expected: "https://example.com/",
+		},
+
+		// Test case 33, ensure values of JSON documents having unicode escape
+		// sequences can be returned.
+		{
+			InputBytes: []byte(`{
+  "homepage": "https:\/\/example.com\/",
+  "name": "caf\u00e9 \ud83d\ude00"
+}
+`),
+			Path:     "name",
+			Format:   FormatJSON,
+			Expected: "café 😀",
+		},
+
+		// Test case 34, ensure escaped dollar signs of double quoted dotenv
+		// values are read as dollar signs.
+		{
+			InputBytes: []byte(`PRICE="it's \$5"
+`),
+			Path:     "PRICE",
+			Format:   FormatDotenv,
+			Expected: "it's $5",
+		},
+
+		// Test case 35, ensure unicode escape sequences of Java properties
+		// files are read, including surrogate pairs.
+		{
+			InputBytes: []byte(`greeting=caf\u00E9 \uD83D\uDE00
+`),
+			Path:     "greeting",
+			Format:   FormatProperties,
+			Expected: "café 😀",
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Base64:   tc.Base64,
+				Bytes:    tc.InputBytes,
+				Format:   tc.Format,
+				Template: tc.Template,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		output, err := p.Get(tc.Path)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(tc.Expected, output) {
+			t.Fatal("test", i+1, "expected", tc.Expected, "got", output)
+		}
+	}
+}
+
+func Test_Service_Get_Error(t *testing.T) {
+	testCases := []struct {
+		InputBytes   []byte
+		Path         string
+		ErrorMatcher func(error) bool
+	}{
+		// Test 1, when there is only 1 element in the list index [1] cannot be
+		// found.
+		{
+			InputBytes: []byte(`{
+  "k1": [
+    {
+      "k2": "v2"
+    }
+  ]
+}`),
+			Path:         "k1.[1].k2",
+			ErrorMatcher: IsNotFound,
+		},
+
+		// Test 2, when there is k1 at the beginning of the path key k3 cannot be
+		// found.
+		{
+			InputBytes: []byte(`{
+  "k1": [
+    {
+      "k2": "v2"
+    }
+  ]
+}`),
+			Path:         "k3.[0].k2",
+			ErrorMatcher: IsNotFound,
+		},
+
+		// Test 3, when there is k2 at the end of the path key k3 cannot be
+		// found.
+		{
+			InputBytes: []byte(`{
+  "k1": [
+    {
+      "k2": "v2"
+    }
+  ]
+}`),
+			Path:         "k1.[0].k3",
+			ErrorMatcher: IsNotFound,
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes: tc.InputBytes,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		_, err = p.Get(tc.Path)
+		if !tc.ErrorMatcher(err) {
+			t.Fatal("test", i+1, "expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Service_GetAll(t *testing.T) {
+	testCases := []struct {
+		InputBytes []byte
+		Path       string
+		Expected   []Match
+	}{
+		// Test case 1, ensure a path without wildcards returns a single match.
+		{
+			InputBytes: []byte(`k1:
+  k2: v2
+`),
+			Path: "k1.k2",
+			Expected: []Match{
+				{Path: "k1.k2", Value: "v2"},
+			},
+		},
+
+		// Test case 2, ensure the wildcard matches all keys of an object.
+		{
+			InputBytes: []byte(`k1:
+  k2:
+    tag: v2
+  k3:
+    tag: v3
+  k4:
+    name: v4
+`),
+			Path: "k1.*.tag",
+			Expected: []Match{
+				{Path: "k1.k2.tag", Value: "v2"},
+				{Path: "k1.k3.tag", Value: "v3"},
+			},
+		},
+
+		// Test case 3, ensure the wildcard matches all items of a list.
+		{
+			InputBytes: []byte(`{
+  "k1": [
+    {
+      "image": "i1"
+    },
+    {
+      "image": "i2"
+    }
+  ]
+}`),
+			Path: "k1.*.image",
+			Expected: []Match{
+				{Path: "k1.[0].image", Value: "i1"},
+				{Path: "k1.[1].image", Value: "i2"},
+			},
+		},
+
+		// Test case 4, ensure the recursive wildcard matches keys on any level,
+		// including the top level.
+		{
+			InputBytes: []byte(`tag: v1
+k1:
+  image:
+    tag: v2
+  k2:
+  - image:
+      tag: v3
+`),
+			Path: "**.tag",
+			Expected: []Match{
+				{Path: "tag", Value: "v1"},
+				{Path: "k1.image.tag", Value: "v2"},
+				{Path: "k1.k2.[0].image.tag", Value: "v3"},
+			},
+		},
+
+		// Test case 5, ensure escaped separators are kept in the concrete paths
+		// of matches.
+		{
+			InputBytes: []byte(`k1:
+  k2.k3: v3
+`),
+			Path: "k1.*",
+			Expected: []Match{
+				{Path: `k1.k2\.k3`, Value: "v3"},
+			},
+		},
+
+		// Test case 6, ensure list items can be selected by the value of one of
+		// their fields, no matter their position.
+		{
+			InputBytes: []byte(`containers:
+- name: sidecar
+  image: s1
+- name: app
+  env:
+  - name: LOG_LEVEL
+    value: info
+`),
+			Path: "containers[name=app].env[name=LOG_LEVEL].value",
+			Expected: []Match{
+				{Path: "containers.[1].env.[0].value", Value: "info"},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes: tc.InputBytes,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		matches, err := p.GetAll(tc.Path)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(tc.Expected, matches) {
+			t.Fatal("test", i+1, "expected", tc.Expected, "got", matches)
+		}
+	}
+}
+
+func Test_Service_GetAll_Error(t *testing.T) {
+	testCases := []struct {
+		InputBytes   []byte
+		Path         string
+		ErrorMatcher func(error) bool
+	}{
+		// Test case 1, ensure a wildcard without any match results in an error.
+		{
+			InputBytes: []byte(`k1:
+  k2: v2
+`),
+			Path:         "k1.*.tag",
+			ErrorMatcher: IsNotFound,
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes: tc.InputBytes,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		_, err = p.GetAll(tc.Path)
+		if !tc.ErrorMatcher(err) {
+			t.Fatal("test", i+1, "expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Service_GetAll_JSONPath(t *testing.T) {
+	testCases := []struct {
+		InputBytes []byte
+		Path       string
+		Expected   []Match
+	}{
+		// Test case 1, ensure filters select list items by the value of one of
+		// their fields.
+		{
+			InputBytes: []byte(`spec:
+  containers:
+  - name: sidecar
+    image: s1
+  - name: app
+    image: a1
+`),
+			Path: "$.spec.containers[?(@.name=='app')].image",
+			Expected: []Match{
+				{Path: "$['spec']['containers'][1]['image']", Value: "a1"},
+			},
+		},
+
+		// Test case 2, ensure recursive descent selects keys on any level.
+		{
+			InputBytes: []byte(`{
+  "image": "i1",
+  "k1": [
+    {
+      "image": "i2"
+    }
+  ]
+}`),
+			Path: "$..image",
+			Expected: []Match{
+				{Path: "$['image']", Value: "i1"},
+				{Path: "$['k1'][0]['image']", Value: "i2"},
+			},
+		},
+
+		// Test case 3, ensure slices and unions select list items in the
+		// given order.
+		{
+			InputBytes: []byte(`k1: [v1, v2, v3, v4]
+`),
+			Path: "$.k1[::-2,0]",
+			Expected: []Match{
+				{Path: "$['k1'][3]", Value: "v4"},
+				{Path: "$['k1'][1]", Value: "v2"},
+				{Path: "$['k1'][0]", Value: "v1"},
+			},
+		},
+
+		// Test case 4, ensure function extensions and comparisons of numbers
+		// can be used in filters.
+		{
+			InputBytes: []byte(`ports:
+- name: http
+  port: 80
+- name: https
+  port: 443
+`),
+			Path: "$.ports[?@.port > 100 && length(@.name) == 5].name",
+			Expected: []Match{
+				{Path: "$['ports'][1]['name']", Value: "https"},
+			},
+		},
+
+		// Test case 5, ensure keys containing dots can be selected using
+		// bracket notation.
+		{
+			InputBytes: []byte(`labels:
+  app.kubernetes.io/name: n1
+`),
+			Path: "$.labels['app.kubernetes.io/name']",
+			Expected: []Match{
+				{Path: "$['labels']['app.kubernetes.io/name']", Value: "n1"},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes:         tc.InputBytes,
+				QueryLanguage: QueryLanguageJSONPath,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		matches, err := p.GetAll(tc.Path)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(tc.Expected, matches) {
+			t.Fatal("test", i+1, "expected", tc.Expected, "got", matches)
+		}
+	}
+}
+
+func Test_Service_GetAll_JSONPath_Error(t *testing.T) {
+	testCases := []struct {
+		Path         string
+		ErrorMatcher func(error) bool
+	}{
+		// Test case 1, ensure queries must start with the root identifier.
+		{
+			Path:         "k1.k2",
+			ErrorMatcher: IsInvalidQuery,
+		},
+
+		// Test case 2, ensure comparisons require singular queries.
+		{
+			Path:         "$.k1[?@.* == 'v2']",
+			ErrorMatcher: IsInvalidQuery,
+		},
+
+		// Test case 3, ensure unknown functions are rejected.
+		{
+			Path:         "$.k1[?foo(@.k2)]",
+			ErrorMatcher: IsInvalidQuery,
+		},
+
+		// Test case 4, ensure queries without any match result in an error.
+		{
+			Path:         "$.k1[?@.k2 == 'v3']",
+			ErrorMatcher: IsNotFound,
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes: []byte(`k1:
+- k2: v2
+`),
+				QueryLanguage: QueryLanguageJSONPath,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		_, err = p.GetAll(tc.Path)
+		if !tc.ErrorMatcher(err) {
+			t.Fatal("test", i+1, "expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Service_IsPattern(t *testing.T) {
+	testCases := []struct {
+		Path          string
+		QueryLanguage string
+		Expected      bool
+	}{
+		// Test case 1, ensure keys addressing a single value are no patterns.
+		{
+			Path:     "spec.containers.[0].image",
+			Expected: false,
+		},
+
+		// Test case 2, ensure keys having predicates, negative indices and
+		// markers are no patterns.
+		{
+			Path:     "spec.containers[name=app].args.[-1][base64]",
+			Expected: false,
+		},
+
+		// Test case 3, ensure keys having wildcards are patterns.
+		{
+			Path:     "spec.containers.*.image",
+			Expected: true,
+		},
+
+		// Test case 4, ensure keys having recursive wildcards are patterns.
+		{
+			Path:     "**.image",
+			Expected: true,
+		},
+
+		// Test case 5, ensure singular JSONPath queries are no patterns.
+		{
+			Path:          "$.spec.containers[0].image",
+			QueryLanguage: QueryLanguageJSONPath,
+			Expected:      false,
+		},
+
+		// Test case 6, ensure JSONPath queries which are not singular are
+		// patterns.
+		{
+			Path:          "$..image",
+			QueryLanguage: QueryLanguageJSONPath,
+			Expected:      true,
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes: []byte(`spec:
+  containers:
+  - name: app
+    image: a1
+`),
+				QueryLanguage: tc.QueryLanguage,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		ok, err := p.IsPattern(tc.Path)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if ok != tc.Expected {
+			t.Fatal("test", i+1, "expected", tc.Expected, "got", ok)
+		}
+	}
+}
+
+func Test_Service_Merge(t *testing.T) {
+	testCases := []struct {
+		InputBytes []byte
+		Path       string
+		Config     MergeConfig
+		Expected   []byte
+	}{
+		// Test case 1, ensure mappings are merged recursively and null values
+		// delete keys.
+		{
+			InputBytes: []byte(`spec:
+  replicas: 2 # replicas
+  resources:
+    limits:
+      cpu: 100m
+      memory: 128Mi
+    requests:
+      cpu: 50m
+`),
+			Path: "spec.resources",
+			Config: MergeConfig{
+				Fragment: []byte(`limits:
+  memory: 256Mi
+requests: null
+`),
+			},
+			Expected: []byte(`spec:
+  replicas: 2 # replicas
+  resources:
+    limits:
+      cpu: 100m
+      memory: 256Mi
+`),
+		},
+
+		// Test case 2, ensure list items are merged by their merge key and items
+		// without counterpart are appended.
+		{
+			InputBytes: []byte(`containers:
+- name: app
+  image: app:v1
+- name: sidecar
+  image: sidecar:v1
+`),
+			Path: "containers",
+			Config: MergeConfig{
+				Fragment: []byte(`- name: sidecar
+  image: sidecar:v2
+- name: proxy
+  image: proxy:v1
+`),
+				ListStrategy: ListStrategyMerge,
+			},
+			Expected: []byte(`containers:
+- name: app
+  image: app:v1
+- name: sidecar
+  image: sidecar:v2
+- name: proxy
+  image: proxy:v1
+`),
+		},
+
+		// Test case 3, ensure list items can be appended.
+		{
+			InputBytes: []byte(`args:
+- --v1
+`),
+			Path: "args",
+			Config: MergeConfig{
+				Fragment:     []byte(`["--v2"]`),
+				ListStrategy: ListStrategyAppend,
+			},
+			Expected: []byte(`args:
+- --v1
+- --v2
+`),
+		},
+
+		// Test case 4, ensure lists are replaced by default and JSON fragments
+		// can be merged into the root of YAML documents.
+		{
+			InputBytes: []byte(`args:
+- --v1
+k1: v1
+`),
+			Config: MergeConfig{
+				Fragment: []byte(`{"args": ["--v2"], "k2": {"k3": "v3"}}`),
+			},
+			Expected: []byte(`args:
+- --v2
+k1: v1
+k2:
+  k3: v3
+`),
+		},
+
+		// Test case 5, ensure missing paths are created.
+		{
+			InputBytes: []byte(`k1: v1
+`),
+			Path: "k2.k3",
+			Config: MergeConfig{
+				Fragment: []byte(`k4: v4
+`),
+			},
+			Expected: []byte(`k1: v1
+k2:
+  k3:
+    k4: v4
+`),
+		},
+
+		// Test case 6, ensure objects whose only key is deleted keep their
+		// block style for the keys added by the same fragment.
+		{
+			InputBytes: []byte(`only:
+  one: 1
+`),
+			Path: "only",
+			Config: MergeConfig{
+				Fragment: []byte(`{"one": null, "two": {"x": 1}}`),
+			},
+			Expected: []byte(`only:
+  two:
+    x: 1
+`),
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes: tc.InputBytes,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		err = p.Merge(tc.Path, tc.Config)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		output, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(tc.Expected, output) {
+			t.Fatal("test", i+1, "expected", string(tc.Expected), "got", string(output))
+		}
+	}
+}
+
+func Test_Service_Merge_Error(t *testing.T) {
+	testCases := []struct {
+		Config       MergeConfig
+		ErrorMatcher func(error) bool
+	}{
+		// Test case 1, ensure unknown list strategies are rejected.
+		{
+			Config: MergeConfig{
+				Fragment:     []byte(`k1: v2`),
+				ListStrategy: "unknown",
+			},
+			ErrorMatcher: IsInvalidConfig,
+		},
+
+		// Test case 2, ensure empty fragments are rejected.
+		{
+			Config: MergeConfig{
+				Fragment: []byte(``),
+			},
+			ErrorMatcher: IsInvalidFormat,
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes: []byte(`k1: v1
+`),
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		err = p.Merge("", tc.Config)
+		if !tc.ErrorMatcher(err) {
+			t.Fatal("test", i+1, "expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Service_Set(t *testing.T) {
+	testCases := []struct {
+		InputBytes []byte
+		Path       string
+		Value      string
+		Expected   []byte
+	}{
+		// Test 1,
+		{
+			InputBytes: []byte(`{
+  "k1": "v1"
+}`),
+			Path:  "k1",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": "modified"
+}`),
+		},
+
+		// Test 2,
+		{
+			InputBytes: []byte(`{
+  "k1": "v1",
+  "k2": "v2"
+}`),
+			Path:  "k1",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": "modified",
+  "k2": "v2"
+}`),
+		},
+
+		// Test 3,
+		{
+			InputBytes: []byte(`{
+  "k1": {
+    "k2": "v2"
+  }
+}`),
+			Path:  "k1.k2",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": {
+    "k2": "modified"
+  }
+}`),
+		},
+
+		// Test 4,
+		{
+			InputBytes: []byte(`{
+  "k1": {
+    "k2": "v2"
+  },
+  "k3": "v3"
+}`),
+			Path:  "k1.k2",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": {
+    "k2": "modified"
+  },
+  "k3": "v3"
+}`),
+		},
+
+		// Test 5,
+		{
+			InputBytes: []byte(`{
+  "k1": {
+    "k2": {
+      "k3": "v3"
+    }
+  }
+}`),
+			Path:  "k1.k2.k3",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": {
+    "k2": {
+      "k3": "modified"
+    }
+  }
+}`),
+		},
+
+		// Test 6,
+		{
+			InputBytes: []byte(`[
+  {
+    "k1": "v1"
+  }
+]`),
+			Path:  "[0].k1",
+			Value: "modified",
+			Expected: []byte(`[
+  {
+    "k1": "modified"
+  }
+]`),
+		},
+
+		// Test 7,
+		{
+			InputBytes: []byte(`{
+  "k1": [
+    {
+      "k2": "v2"
+    }
+  ]
+}`),
+			Path:  "k1.[0].k2",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": [
+    {
+      "k2": "modified"
+    }
+  ]
+}`),
+		},
+
+		// Test 8,
+		{
+			InputBytes: []byte(`{
+  "k1": [
+    [
+      {
+        "k2": "v2"
+      }
+    ],
+    [
+      {
+        "k3": "v3"
+      }
+    ]
+  ]
+}`),
+			Path:  "k1.[1].[0].k3",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": [
+    [
+      {
+        "k2": "v2"
+      }
+    ],
+    [
+      {
+        "k3": "modified"
+      }
+    ]
+  ]
+}`),
+		},
+
+		// Test case 9, ensure the value of single paths with unnested inline JSON
+		// objects can be modified.
+		{
+			InputBytes: []byte(`{
+  "k1": ` + strconv.Quote(`{
+    "k2": "v2"
+  }`) + `
+}`),
+			Path:  "k1.k2",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": ` + strconv.Quote(`{
+    "k2": "modified"
+  }`) + `
+}`),
+		},
+
+		// Test case 10, ensure the value of single paths with nested inline JSON
+		// objects can be modified.
+		{
+			InputBytes: []byte(`{
+  "k1": ` + strconv.Quote(`{
+    "k2": {
+      "k3": "v3"
+    }
+  }`) + `
+}`),
+			Path:  "k1.k2.k3",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": ` + strconv.Quote(`{
+    "k2": {
+      "k3": "modified"
+    }
+  }`) + `
+}`),
+		},
+
+		// Test case 11, ensure the value of single paths with unnested inline JSON
+		// lists can be modified.
+		{
+			InputBytes: []byte(`{
+  "k1": ` + strconv.Quote(`[
+    {
+      "k2": "v2"
+    }
+  ]`) + `
+}`),
+			Path:  "k1.[0].k2",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": ` + strconv.Quote(`[
+    {
+      "k2": "modified"
+    }
+  ]`) + `
+}`),
+		},
+
+		// Test case 12, ensure the value of multiple paths with unnested inline
+		// JSON objects can be modified.
+		{
+			InputBytes: []byte(`{
+  "k1": ` + strconv.Quote(`{
+    "k2": "v2",
+    "k3": "v3"
+  }`) + `
+}`),
+			Path:  "k1.k2",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": ` + strconv.Quote(`{
+    "k2": "modified",
+    "k3": "v3"
+  }`) + `
+}`),
+		},
+
+		// Test case 13, ensure the value of multiple paths with nested inline JSON
+		// objects can be modified.
+		{
+			InputBytes: []byte(`{
+  "k1": ` + strconv.Quote(`{
+    "k2": {
+      "k3": "v3"
+    },
+    "k4": {
+      "k5": "v5"
+    }
+  }`) + `
+}`),
+			Path:  "k1.k2.k3",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": ` + strconv.Quote(`{
+    "k2": {
+      "k3": "modified"
+    },
+    "k4": {
+      "k5": "v5"
+    }
+  }`) + `
+}`),
+		},
+
+		// Test case 14, ensure the value of multiple paths with unnested inline
+		// JSON lists can be modified.
+		{
+			InputBytes: []byte(`{
+  "k1": ` + strconv.Quote(`[
+    {
+      "k2": "v2"
+    },
+    {
+      "k3": "v3"
+    }
+  ]`) + `
+}`),
+			Path:  "k1.[0].k2",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": ` + strconv.Quote(`[
+    {
+      "k2": "modified"
+    },
+    {
+      "k3": "v3"
+    }
+  ]`) + `
+}`),
+		},
+
+		// Test case 15, ensure the value of single paths with unnested inline YAML
+		// objects can be modified.
+		{
+			InputBytes: []byte(`{
+  "k1": "k2: v2"
+}`),
+			Path:  "k1.k2",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": ` + strconv.Quote(`k2: modified`) + `
+}`),
+		},
+
+		// Test case 16, ensure the value of single paths with nested inline YAML
+		// objects can be modified.
+		{
+			InputBytes: []byte(`{
+  "k1": ` + strconv.Quote(`k2:
+  k3: v3`) + `
+}`),
+			Path:  "k1.k2.k3",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": ` + strconv.Quote(`k2:
+  k3: modified`) + `
+}`),
+		},
+
+		// Test case 17, ensure the value of single paths with unnested inline YAML
+		// lists can be modified.
+		{
+			InputBytes: []byte(`{
+  "k1": "- k2: v2"
+}`),
+			Path:  "k1.[0].k2",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": ` + strconv.Quote(`- k2: modified`) + `
+}`),
+		},
+
+		// Test case 18, ensure the value of multiple paths with unnested inline
+		// YAML objects can be modified.
+		{
+			InputBytes: []byte(`{
+  "k1": ` + strconv.Quote(`k2: v2
+k3: v3`) + `
+}`),
+			Path:  "k1.k2",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": ` + strconv.Quote(`k2: modified
+k3: v3`) + `
+}`),
+		},
+
+		// Test case 19, ensure the value of multiple paths with nested inline YAML
+		// objects can be modified.
+		{
+			InputBytes: []byte(`{
+  "k1": ` + strconv.Quote(`k2:
+  k3: v3
+k4:
+  k5: v5`) + `
+}`),
+			Path:  "k1.k2.k3",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": ` + strconv.Quote(`k2:
+  k3: modified
+k4:
+  k5: v5`) + `
+}`),
+		},
+
+		// Test case 20, ensure the value of multiple paths with unnested inline
+		// YAML lists can be modified.
+		{
+			InputBytes: []byte(`{
+  "k1": ` + strconv.Quote(`- k2: v2
+- k3: v3`) + `
+}`),
+			Path:  "k1.[0].k2",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": ` + strconv.Quote(`- k2: modified
+- k3: v3`) + `
+}`),
+		},
+
+		// Test case 21, ensure the value of paths with separators inside keys can
+		// be modified.
+		{
+			InputBytes: []byte(`{
+  "k1": {
+    "k2.k3": {
+      "k4": "v4"
+    }
+  }
+}`),
+			Path:  `k1.k2\.k3.k4`,
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": {
+    "k2.k3": {
+      "k4": "modified"
+    }
+  }
+}`),
+		},
+
+		// Test case 22, ensure the value of a single unnested path can be modified,
+		// even though its value is empty.
+		{
+			InputBytes: []byte(`{
+  "k1": ""
+}`),
+			Path:  "k1",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": "modified"
+}`),
+		},
+
+		// Test case 23, ensure the value of a single nested path can be modified,
+		// even though its value is empty.
+		{
+			InputBytes: []byte(`{
+  "k1": {
+    "k2": {
+      "k3": ""
+    }
+  }
+}`),
+			Path:  "k1.k2.k3",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": {
+    "k2": {
+      "k3": "modified"
+    }
+  }
+}`),
+		},
+
+		// Test case 24, ensure the value of a single unnested path can be set,
+		// even though its key is missing.
+		{
+			InputBytes: []byte(`{
+  "k1": ""
+}`),
+			Path:  "k2",
+			Value: "added",
+			Expected: []byte(`{
+  "k1": "",
+  "k2": "added"
+}`),
+		},
+
+		// Test case 25, ensure the value of a multi level nested path can be set,
+		// even though its key is missing.
+		{
+			InputBytes: []byte(`{
+  "k1": {}
+}`),
+			Path:  "k1.s1.e3",
+			Value: "added",
+			Expected: []byte(`{
+  "k1": {
+    "s1": {
+      "e3": "added"
+    }
+  }
+}`),
+		},
+
+		// Test case 26, ensure the value of a multi level nested path can be set,
+		// even though its key is missing.
+		{
+			InputBytes: []byte(`{
+  "k1": ""
+}`),
+			Path:  "k2.s1.e3",
+			Value: "added",
+			Expected: []byte(`{
+  "k1": "",
+  "k2": {
+    "s1": {
+      "e3": "added"
+    }
+  }
+}`),
+		},
+
+		// Test case 27, ensure numbers are written as given when JSON documents
+		// are encoded again.
+		{
+			InputBytes: []byte(`{
+  "k1": 123456789012345678,
+  "k2": 1.10,
+  "k3": 1e3,
+  "k4": "v4"
+}`),
+			Path:  "k4",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": 123456789012345678,
+  "k2": 1.10,
+  "k3": 1e3,
+  "k4": "modified"
+}`),
+		},
+
+		// Test case 28, ensure numbers are written as given when embedded YAML
+		// documents are encoded again.
+		{
+			InputBytes: []byte(`{
+  "k1": "k2: 123456789012345678901234\nk3: 1.10\n"
+}`),
+			Path:  "k1.k4",
+			Value: "v4",
+			Expected: []byte(`{
+  "k1": "k2: 123456789012345678901234\nk3: 1.10\nk4: v4\n"
+}`),
+		},
+
+		// Test case 29, ensure JSON documents keep their key order, their
+		// indentation using tabs and their trailing newline.
+		{
+			InputBytes: []byte("{\n\t\"name\": \"app\",\n\t\"version\": \"1.0.0\",\n\t\"dependencies\": {\n\t\t\"b\": \"^1.0.0\",\n\t\t\"a\": \"^2.0.0\"\n\t}\n}\n"),
+			Path:       "version",
+			Value:      "1.1.0",
+			Expected:   []byte("{\n\t\"name\": \"app\",\n\t\"version\": \"1.1.0\",\n\t\"dependencies\": {\n\t\t\"b\": \"^1.0.0\",\n\t\t\"a\": \"^2.0.0\"\n\t}\n}\n"),
+		},
+
+		// Test case 30, ensure JSON documents keep their indentation width and
+		// new keys are added at the end.
+		{
+			InputBytes: []byte(`{
+    "k2": "v2",
+    "k1": "<v1>"
+}
+`),
+			Path:  "k0",
+			Value: "v0",
+			Expected: []byte(`{
+    "k2": "v2",
+    "k1": "<v1>",
+    "k0": "v0"
+}
+`),
+		},
+
+		// Test case 31, ensure compact JSON documents embedded in strings stay
+		// compact.
+		{
+			InputBytes: []byte(`{
+  "k1": "{\"k3\": \"v3\", \"k2\": [\"v2\"]}"
+}`),
+			Path:  "k1.k3",
+			Value: "modified",
+			Expected: []byte(`{
+  "k1": "{\"k3\": \"modified\", \"k2\": [\"v2\"]}"
+}`),
+		},
+
+		// Test case 32, ensure compact JSON documents keep the separators of
+		// all their members.
+		{
+			InputBytes: []byte(`{"k1": "é","k2": "v2", "k3":"v3"}
+`),
+			Path:  "k2",
+			Value: "modified",
+			Expected: []byte(`{"k1": "é","k2": "modified", "k3":"v3"}
+`),
+		},
+
+		// Test case 33, ensure JSON documents keep the escape sequences of
+		// all values not modified.
+		{
+			InputBytes: []byte(`{
+  "homepage": "https:\/\/example.com\/",
+  "name": "caf\u00e9",
+  "version": "1.0.0"
+}
+`),
+			Path:  "version",
+			Value: "1.1.0",
+			Expected: []byte(`{
+  "homepage": "https:\/\/example.com\/",
+  "name": "caf\u00e9",
+  "version": "1.1.0"
+}
+`),
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes: tc.InputBytes,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		err = p.Set(tc.Path, tc.Value)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		output, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(tc.Expected, output) {
+			t.Fatal("test", i+1, "expected", string(tc.Expected), "got", string(output))
+		}
+	}
+}
+
+func Test_Service_Set_YAML(t *testing.T) {
+	testCases := []struct {
+		InputBytes   []byte
+		Path         string
+		Value        interface{}
+		Base64       bool
+		SplitAliases bool
+		Template     bool
+		Expected     []byte
+	}{
+		// Test case 1, ensure comments, blank lines, key order and quoting styles
+		// are preserved when modifying a nested value.
+		{
+			InputBytes: []byte(`# head comment
+apiVersion: "helm.toolkit.fluxcd.io/v2beta1"
+
+kind: HelmRelease # line comment
+spec:
+  values:
+    image:
+      tag: '8469445410f8a74d72af0cf430ed8dd44fb6b8fa' # tag
+    name: "apiserver"
+`),
+			Path:  "spec.values.image.tag",
+			Value: "modified",
+			Expected: []byte(`# head comment
+apiVersion: "helm.toolkit.fluxcd.io/v2beta1"
+
+kind: HelmRelease # line comment
+spec:
+  values:
+    image:
+      tag: 'modified' # tag
+    name: "apiserver"
+`),
+		},
+
+		// Test case 2, ensure the indentation of lists is preserved.
+		{
+			InputBytes: []byte(`k1:
+- v1
+- v2
+k2:
+    - v3
+`),
+			Path:  "k1.[1]",
+			Value: "modified",
+			Expected: []byte(`k1:
+- v1
+- modified
+k2:
+    - v3
+`),
+		},
+
+		// Test case 3, ensure string values looking like other types remain
+		// strings.
+		{
+			InputBytes: []byte(`k1: v1
+`),
+			Path:  "k1",
+			Value: "3",
+			Expected: []byte(`k1: "3"
+`),
+		},
+
+		// Test case 4, ensure empty values can be set.
+		{
+			InputBytes: []byte(`k1:
+k2: v2
+`),
+			Path:  "k1",
+			Value: "added",
+			Expected: []byte(`k1: added
+k2: v2
+`),
+		},
+
+		// Test case 5, ensure missing keys are added with the indentation of the
+		// document, right after the last key of their mapping.
+		{
+			InputBytes: []byte(`k1:
+    k2: v2 # comment
+
+# comment
+k3: v3
+`),
+			Path:  "k1.s1.e3",
+			Value: "added",
+			Expected: []byte(`k1:
+    k2: v2 # comment
+    s1:
+        e3: added
+
+# comment
+k3: v3
+`),
+		},
+
+		// Test case 6, ensure values in flow collections can be modified and
+		// added.
+		{
+			InputBytes: []byte(`k1: {k2: v2, k3: [v3, v4]}
+`),
+			Path:  "k1.k5",
+			Value: "added",
+			Expected: []byte(`k1: {k2: v2, k3: [v3, v4], k5: added}
+`),
+		},
+
+		// Test case 7, ensure the style of block scalars is preserved.
+		{
+			InputBytes: []byte(`k1: |
+  line1
+  line2
+k2: v2
+`),
+			Path:  "k1",
+			Value: "modified1\nmodified2\n",
+			Expected: []byte(`k1: |
+  modified1
+  modified2
+k2: v2
+`),
+		},
+
+		// Test case 8, ensure values can be modified within lists of objects.
+		{
+			InputBytes: []byte(`- name: n1
+  image: i1
+- name: n2
+  image: i2
+`),
+			Path:  "[1].image",
+			Value: "modified",
+			Expected: []byte(`- name: n1
+  image: i1
+- name: n2
+  image: modified
+`),
+		},
+
+		// Test case 9, ensure scalars can be replaced with whole structures.
+		{
+			InputBytes: []byte(`k1: v1
+k2: v2
+`),
+			Path: "k1",
+			Value: map[string]interface{}{
+				"k3": "v3",
+				"k4": []string{"v4"},
+			},
+			Expected: []byte(`k1:
+  k3: v3
+  k4:
+    - v4
+k2: v2
+`),
+		},
+
+		// Test case 10, ensure whole structures can be replaced with scalars.
+		{
+			InputBytes: []byte(`k1:
+  k2: v2
+  k3: v3
+k4: v4
+`),
+			Path:  "k1",
+			Value: "modified",
+			Expected: []byte(`k1: modified
+k4: v4
+`),
+		},
+
+		// Test case 11, ensure all values matched by a wildcard are changed.
+		{
+			InputBytes: []byte(`containers:
+- name: c1
+  image: i1 # first
+- name: c2
+  image: i2
+`),
+			Path:  "containers.*.image",
+			Value: "modified",
+			Expected: []byte(`containers:
+- name: c1
+  image: modified # first
+- name: c2
+  image: modified
+`),
+		},
+
+		// Test case 12, ensure all values matched by a recursive wildcard are
+		// changed, no matter how deeply they are nested.
+		{
+			InputBytes: []byte(`tag: v1
+k1:
+  image:
+    tag: v1
+  k2:
+  - image:
+      tag: v1
+    name: n1
+`),
+			Path:  "**.tag",
+			Value: "v2",
+			Expected: []byte(`tag: v2
+k1:
+  image:
+    tag: v2
+  k2:
+  - image:
+      tag: v2
+    name: n1
+`),
+		},
+
+		// Test case 13, ensure list items selected by a predicate are changed.
+		{
+			InputBytes: []byte(`containers:
+- name: sidecar
+  image: s1
+- name: app # main
+  image: a1
+`),
+			Path:  "containers[name=app].image",
+			Value: "a2",
+			Expected: []byte(`containers:
+- name: sidecar
+  image: s1
+- name: app # main
+  image: a2
+`),
+		},
+
+		// Test case 14, ensure list items are created when no item matches the
+		// predicate.
+		{
+			InputBytes: []byte(`containers:
+- name: app
+  env:
+  - name: LOG_LEVEL
+    value: info
+`),
+			Path:  "containers[name=app].env[name=DEBUG].value",
+			Value: "true",
+			Expected: []byte(`containers:
+- name: app
+  env:
+  - name: LOG_LEVEL
+    value: info
+  - name: DEBUG
+    value: "true"
+`),
+		},
+
+		// Test case 15, ensure missing lists are created for predicates.
+		{
+			InputBytes: []byte(`spec:
+  containers: []
+`),
+			Path:  "spec.containers[name=app].ports[containerPort=80].protocol",
+			Value: "TCP",
+			Expected: []byte(`spec:
+  containers: [{name: app, ports: [{containerPort: 80, protocol: TCP}]}]
+`),
+		},
+
+		// Test case 16, ensure the last list item can be changed using a
+		// negative index.
+		{
+			InputBytes: []byte(`k1:
+- v1
+- v2
+`),
+			Path:  "k1.[-1]",
+			Value: "modified",
+			Expected: []byte(`k1:
+- v1
+- modified
+`),
+		},
+
+		// Test case 17, ensure new items can be appended to lists.
+		{
+			InputBytes: []byte(`imagePullSecrets:
+- name: s1 # first
+`),
+			Path:  "imagePullSecrets[+].name",
+			Value: "s2",
+			Expected: []byte(`imagePullSecrets:
+- name: s1 # first
+- name: s2
+`),
+		},
+
+		// Test case 18, ensure new items can be appended to flow lists using
+		// [append].
+		{
+			InputBytes: []byte(`k1: [v1, v2]
+`),
+			Path:  "k1.[append]",
+			Value: "v3",
+			Expected: []byte(`k1: [v1, v2, v3]
+`),
+		},
+
+		// Test case 19, ensure new items can be inserted at a given index.
+		{
+			InputBytes: []byte(`k1:
+  - v1
+  - v3
+`),
+			Path:  "k1.[+1]",
+			Value: "v2",
+			Expected: []byte(`k1:
+  - v1
+  - v2
+  - v3
+`),
+		},
+
+		// Test case 20, ensure missing intermediate lists are created.
+		{
+			InputBytes: []byte(`spec:
+  args:
+  - --debug
+`),
+			Path:  "spec.imagePullSecrets.[0].name",
+			Value: "s1",
+			Expected: []byte(`spec:
+  args:
+  - --debug
+  imagePullSecrets:
+  - name: s1
+`),
+		},
+
+		// Test case 21, ensure anchors are modified when setting aliases and
+		// merged keys, so that the document keeps its references.
+		{
+			InputBytes: []byte(`base: &base
+  image: app:v1 # image
+  resources: &resources
+    cpu: 1
+tag: &tag v1
+a:
+  <<: *base
+  name: a
+b:
+  <<: *base
+  resources: *resources
+c: *tag
+`),
+			Path:  "a.image",
+			Value: "app:v2",
+			Expected: []byte(`base: &base
+  image: app:v2 # image
+  resources: &resources
+    cpu: 1
+tag: &tag v1
+a:
+  <<: *base
+  name: a
+b:
+  <<: *base
+  resources: *resources
+c: *tag
+`),
+		},
+
+		// Test case 22, ensure anchored scalars are modified when setting their
+		// aliases.
+		{
+			InputBytes: []byte(`tag: &tag v1
+a: *tag
+`),
+			Path:  "a",
+			Value: "v2",
+			Expected: []byte(`tag: &tag v2
+a: *tag
+`),
+		},
+
+		// Test case 23, ensure merged keys can be split off, keeping the other
+		// keys of the merged value.
+		{
+			InputBytes: []byte(`base: &base
+  resources:
+    cpu: 1
+    memory: 1Gi
+a:
+  <<: *base
+  name: a
+`),
+			Path:         "a.resources.cpu",
+			Value:        2,
+			SplitAliases: true,
+			Expected: []byte(`base: &base
+  resources:
+    cpu: 1
+    memory: 1Gi
+a:
+  <<: *base
+  name: a
+  resources:
+    cpu: 2
+    memory: 1Gi
+`),
+		},
+
+		// Test case 24, ensure aliases can be split off.
+		{
+			InputBytes: []byte(`resources: &resources
+  cpu: 1
+  memory: 1Gi
+a:
+  resources: *resources
+b:
+  resources: *resources
+`),
+			Path:         "b.resources.cpu",
+			Value:        2,
+			SplitAliases: true,
+			Expected: []byte(`resources: &resources
+  cpu: 1
+  memory: 1Gi
+a:
+  resources: *resources
+b:
+  resources:
+    cpu: 2
+    memory: 1Gi
+`),
+		},
+
+		// Test case 25, ensure anchors survive modifications of embedded
+		// documents.
+		{
+			InputBytes: []byte(`config.yaml: |
+  base: &base
+    k1: v1
+  a:
+    <<: *base
+`),
+			Path:  "config\\.yaml.a.k2",
+			Value: "v2",
+			Expected: []byte(`config.yaml: |
+  base: &base
+    k1: v1
+  a:
+    <<: *base
+    k2: v2
+`),
+		},
+
+		// Test case 26, ensure JSON documents embedded in YAML documents keep
+		// their key order and indentation.
+		{
+			InputBytes: []byte(`data:
+  config.json: |
+    {
+        "k2": 1,
+        "k1": "v1"
+    }
+`),
+			Path:  "data.config\\.json.k1",
+			Value: "modified",
+			Expected: []byte(`data:
+  config.json: |
+    {
+        "k2": 1,
+        "k1": "modified"
+    }
+`),
+		},
+
+		// Test case 27, ensure modifications of embedded YAML documents keep
+		// their comments and key order.
+		{
+			InputBytes: []byte(`data:
+  config.yaml: |
+    # image settings
+    image:
+      tag: v1 # tag
+      repository: app
+  other: v1
+`),
+			Path:  "data.config\\.yaml.image.tag",
+			Value: "v2",
+			Expected: []byte(`data:
+  config.yaml: |
+    # image settings
+    image:
+      tag: v2 # tag
+      repository: app
+  other: v1
+`),
+		},
+
+		// Test case 28, ensure folded block scalars containing embedded
+		// documents stay folded.
+		{
+			InputBytes: []byte(`config: >
+  k1: v1
+
+  k2: v2
+`),
+			Path:  "config.k2",
+			Value: "modified",
+			Expected: []byte(`config: >
+  k1: v1
+
+  k2: modified
+`),
+		},
+
+		// Test case 29, ensure block scalars keeping their trailing line breaks
+		// keep them.
+		{
+			InputBytes: []byte(`config: |+
+  k1: v1
+
+other: v1
+`),
+			Path:  "config.k1",
+			Value: "modified",
+			Expected: []byte(`config: |+
+  k1: modified
+
+other: v1
+`),
+		},
+
+		// Test case 30, ensure base64 encoded documents are encoded again after
+		// being modified.
+		{
+			InputBytes: []byte(`data:
+  config.json: eyJlbmRwb2ludCI6ICJodHRwOi8vYXBwIiwgInBvcnQiOiA4MH0=
+`),
+			Path:   "data.config\\.json.endpoint",
+			Value:  "http://modified",
+			Base64: true,
+			Expected: []byte(`data:
+  config.json: eyJlbmRwb2ludCI6ICJodHRwOi8vbW9kaWZpZWQiLCAicG9ydCI6IDgwfQ==
+`),
+		},
+
+		// Test case 31, ensure base64 encoded strings can be set using the
+		// base64 marker.
+		{
+			InputBytes: []byte(`data:
+  password: c2VjcmV0 # password
+`),
+			Path:  "data.password[base64]",
+			Value: "modified",
+			Expected: []byte(`data:
+  password: bW9kaWZpZWQ= # password
+`),
+		},
+
+		// Test case 32, ensure template actions are written back unchanged if
+		// configured.
+		{
+			InputBytes: []byte(`{{- if .Values.enabled }}
+metadata:
+  name: {{ include "fullname" . }}
+  labels:
+    {{- include "labels" . | nindent 4 }}
+spec:
+  replicas: {{ .Values.replicas }}
+  port: 8080
+{{- end }}
+`),
+			Path:     "spec.port",
+			Value:    "9090",
+			Template: true,
+			Expected: []byte(`{{- if .Values.enabled }}
+metadata:
+  name: {{ include "fullname" . }}
+  labels:
+    {{- include "labels" . | nindent 4 }}
+spec:
+  replicas: {{ .Values.replicas }}
+  port: "9090"
+{{- end }}
+`),
+		},
+
+		// Test case 33, ensure JSON documents embedded in YAML documents keep
+		// the lines of all values not modified, like inline objects.
+		{
+			InputBytes: []byte(`data:
+  config.json: |
+    {
+      "image": {"tag": "v1"},
+      "replicas": [1,2]
+    }
+`),
+			Path:  "data.config\\.json.image.tag",
+			Value: "v2",
+			Expected: []byte(`data:
+  config.json: |
+    {
+      "image": {"tag": "v2"},
+      "replicas": [1,2]
+    }
+`),
+		},
+		// Test case 34, ensure keys added after a key having its value
+		// rendered by a template action are added after the action.
+		{
+			InputBytes: []byte(`metadata:
+  labels:
+    {{- include "labels" . | nindent 4 }}
+spec:
+  port: 8080
+`),
+			Path:     "metadata.annotations.a",
+			Value:    "b",
+			Template: true,
+			Expected: []byte(`metadata:
+  labels:
+    {{- include "labels" . | nindent 4 }}
+  annotations:
+    a: b
+spec:
+  port: 8080
+`),
+		},
+
+		// Test case 35, ensure keys added after a key having template actions
+		// at the end of its value are added after the actions, and before the
+		// actions of the enclosing object.
+		{
+			InputBytes: []byte(`{{- if .Values.enabled }}
+metadata:
+  labels:
+    app: dsm
+    {{- with .Values.labels }}
+    {{- toYaml . | nindent 4 }}
+    {{- end }}
+{{- end }}
+`),
+			Path:     "metadata.annotations.a",
+			Value:    "b",
+			Template: true,
+			Expected: []byte(`{{- if .Values.enabled }}
+metadata:
+  labels:
+    app: dsm
+    {{- with .Values.labels }}
+    {{- toYaml . | nindent 4 }}
+    {{- end }}
+  annotations:
+    a: b
+{{- end }}
+`),
+		},
+
+		// Test case 36, ensure keys added after a key within a control block
+		// are added after the end of the block, so that they are not rendered
+		// conditionally.
+		{
+			InputBytes: []byte(`spec:
+  containers:
+    - name: app
+      {{- if .Values.env }}
+      env:
+        {{- toYaml .Values.env | nindent 8 }}
+      {{- end }}
+  restartPolicy: Always
+`),
+			Path:     "spec.containers.[0].image",
+			Value:    "app",
+			Template: true,
+			Expected: []byte(`spec:
+  containers:
+    - name: app
+      {{- if .Values.env }}
+      env:
+        {{- toYaml .Values.env | nindent 8 }}
+      {{- end }}
+      image: app
+  restartPolicy: Always
+`),
+		},
+
+		// Test case 37, ensure keys added to objects starting within a control
+		// block are added after the end of the block.
+		{
+			InputBytes: []byte(`spec:
+  {{- if .Values.autoscaling }}
+  replicas: 1
+  {{- else }}
+  paused: true
+{{- end }}
+status: {}
+`),
+			Path:     "spec.strategy",
+			Value:    "Recreate",
+			Template: true,
+			Expected: []byte(`spec:
+  {{- if .Values.autoscaling }}
+  replicas: 1
+  {{- else }}
+  paused: true
+{{- end }}
+  strategy: Recreate
+status: {}
+`),
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Base64:       tc.Base64,
+				Bytes:        tc.InputBytes,
+				SplitAliases: tc.SplitAliases,
+				Template:     tc.Template,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		err = p.Set(tc.Path, tc.Value)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		output, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(tc.Expected, output) {
+			t.Fatal("test", i+1, "expected", string(tc.Expected), "got", string(output))
+		}
+	}
+}
+
+func Test_Service_Set_TOML(t *testing.T) {
+	testCases := []struct {
+		InputBytes []byte
+		Path       string
+		Value      string
+		Expected   []byte
+	}{
+		// Test case 1, ensure strings keep their quotes and comments when
+		// being replaced.
+		{
+			InputBytes: []byte(`# manifest
+[package]
+name = 'dsm' # the name
+version = "0.1.0"
+`),
+			Path:  "package.name",
+			Value: "app",
+			Expected: []byte(`# manifest
+[package]
+name = 'app' # the name
+version = "0.1.0"
+`),
+		},
+
+		// Test case 2, ensure numbers keep the type they replace.
+		{
+			InputBytes: []byte(`[package]
+edition = 2021
+`),
+			Path:  "package.edition",
+			Value: "2024",
+			Expected: []byte(`[package]
+edition = 2024
+`),
+		},
+
+		// Test case 3, ensure keys are added after the last key of their table.
+		{
+			InputBytes: []byte(`[package]
+name = "dsm"
+
+[dependencies]
+serde = "1.0"
+`),
+			Path:  "package.version",
+			Value: "0.2.0",
+			Expected: []byte(`[package]
+name = "dsm"
+version = "0.2.0"
+
+[dependencies]
+serde = "1.0"
+`),
+		},
+
+		// Test case 4, ensure keys are added to inline tables and values are
+		// appended to inline arrays.
+		{
+			InputBytes: []byte(`[dependencies]
+serde = { version = "1.0", features = ["derive"] }
+`),
+			Path:  "dependencies.serde.features.[+]",
+			Value: "std",
+			Expected: []byte(`[dependencies]
+serde = { version = "1.0", features = ["derive", "std"] }
+`),
+		},
+
+		// Test case 5, ensure new tables are added at the end of the document.
+		{
+			InputBytes: []byte(`name = "dsm"
+
+[package]
+version = "0.1.0"
+`),
+			Path:  "profile.release.lto",
+			Value: "fat",
+			Expected: []byte(`name = "dsm"
+
+[package]
+version = "0.1.0"
+
+[profile.release]
+lto = "fat"
+`),
+		},
+
+		// Test case 6, ensure keys are added to the given table of an array of
+		// tables.
+		{
+			InputBytes: []byte(`[[bin]]
+name = "a"
+
+[[bin]]
+name = "b"
+`),
+			Path:  "bin.[0].path",
+			Value: "src/a.rs",
+			Expected: []byte(`[[bin]]
+name = "a"
+path = "src/a.rs"
+
+[[bin]]
+name = "b"
+`),
+		},
+
+		// Test case 7, ensure dates keep their type when being replaced.
+		{
+			InputBytes: []byte(`released = 1979-05-27T07:32:00Z
+`),
+			Path:  "released",
+			Value: "2020-01-01T00:00:00Z",
+			Expected: []byte(`released = 2020-01-01T00:00:00Z
+`),
+		},
+
+		// Test case 8, ensure multi line strings stay multi line strings.
+		{
+			InputBytes: []byte(`description = """
+first line
+"""
+`),
+			Path:  "description",
+			Value: "first line\nsecond line\n",
+			Expected: []byte(`description = """
+first line
+second line
+"""
+`),
+		},
+
+		// Test case 9, ensure tables appended to arrays of tables are written
+		// after the last table of the array and its sub tables, keeping the
+		// comments and numbers of the document.
+		{
+			InputBytes: []byte(`# app config
+name = "app"
+
+[[servers]]
+host = "a"
+port = 1_000
+
+[[servers]] # current
+host = "b"
+
+[servers.tls]
+enabled = true
+
+# numbers
+[numbers]
+max = 1_000
+`),
+			Path:  "servers.[+].host",
+			Value: "c",
+			Expected: []byte(`# app config
+name = "app"
+
+[[servers]]
+host = "a"
+port = 1_000
+
+[[servers]] # current
+host = "b"
+
+[servers.tls]
+enabled = true
+
+[[servers]]
+host = "c"
+
+# numbers
+[numbers]
+max = 1_000
+`),
+		},
+
+		// Test case 10, ensure tables appended to nested arrays of tables at
+		// the end of the document are written with their full header.
+		{
+			InputBytes: []byte(`[[app.servers]]
+host = "a"`),
+			Path:  "app.servers.[+].host",
+			Value: "b",
+			Expected: []byte(`[[app.servers]]
+host = "a"
+
+[[app.servers]]
+host = "b"
+`),
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes: tc.InputBytes,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		err = p.SetTyped(tc.Path, tc.Value, TypeAuto)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		output, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(tc.Expected, output) {
+			t.Fatal("test", i+1, "expected", string(tc.Expected), "got", string(output))
+		}
+	}
+}
+
+func Test_Service_Set_Flat(t *testing.T) {
+	testCases := []struct {
+		InputBytes []byte
+		Format     string
+		Path       string
+		Value      string
+		Expected   []byte
+	}{
+		// Test case 1, ensure dotenv values keep their quotes and comments
+		// when being replaced.
+		{
+			InputBytes: []byte(`# comment
+DB_HOST=localhost # host
+NAME='app'
+`),
+			Format: FormatDotenv,
+			Path:   `NAME`,
+			Value:  "api",
+			Expected: []byte(`# comment
+DB_HOST=localhost # host
+NAME='api'
+`),
+		},
+
+		// Test case 2, ensure unquoted dotenv values are quoted if necessary.
+		{
+			InputBytes: []byte(`GREETING=hello
+`),
+			Format: FormatDotenv,
+			Path:   `GREETING`,
+			Value:  "hello world",
+			Expected: []byte(`GREETING="hello world"
+`),
+		},
+
+		// Test case 3, ensure new dotenv keys are added at the end of the
+		// file like the keys before.
+		{
+			InputBytes: []byte(`export K1=v1
+export K2=v2`),
+			Format: FormatDotenv,
+			Path:   `K3`,
+			Value:  "v3",
+			Expected: []byte(`export K1=v1
+export K2=v2
+export K3=v3
+`),
+		},
+
+		// Test case 4, ensure values of Java properties files continued on
+		// several lines are replaced as a whole.
+		{
+			InputBytes: []byte(`# comment
+server.port = 8080
+description = first \
+  second
+`),
+			Format: FormatProperties,
+			Path:   `description`,
+			Value:  "third",
+			Expected: []byte(`# comment
+server.port = 8080
+description = third
+`),
+		},
+
+		// Test case 5, ensure new keys of Java properties files are escaped
+		// and written with the separator of the keys before.
+		{
+			InputBytes: []byte(`spring.datasource.url: jdbc:h2:mem:db
+`),
+			Format: FormatProperties,
+			Path:   `key with space`,
+			Value:  " value",
+			Expected: []byte(`spring.datasource.url: jdbc:h2:mem:db
+key\ with\ space: \ value
+`),
+		},
+
+		// Test case 6, ensure dotenv values containing $ are single quoted,
+		// since variables are expanded within double quotes.
+		{
+			InputBytes: []byte(`DB_PASS="secret"
+`),
+			Format: FormatDotenv,
+			Path:   `DB_PASS`,
+			Value:  "pa$word",
+			Expected: []byte(`DB_PASS='pa$word'
+`),
+		},
+
+		// Test case 7, ensure $ is escaped within double quoted dotenv values
+		// which cannot be single quoted.
+		{
+			InputBytes: []byte(`PRICE=1
+`),
+			Format: FormatDotenv,
+			Path:   `PRICE`,
+			Value:  "it's $5",
+			Expected: []byte(`PRICE="it's \$5"
+`),
+		},
+
+		// Test case 8, ensure characters of Java properties files outside of
+		// printable ASCII are written as unicode escape sequences.
+		{
+			InputBytes: []byte(`server.port=8080
+`),
+			Format: FormatProperties,
+			Path:   `greeting`,
+			Value:  "café 😀",
+			Expected: []byte(`server.port=8080
+greeting=caf\u00E9 \uD83D\uDE00
+`),
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes:  tc.InputBytes,
+				Format: tc.Format,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		err = p.SetTyped(tc.Path, tc.Value, TypeAuto)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		output, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(tc.Expected, output) {
+			t.Fatal("test", i+1, "expected", string(tc.Expected), "got", string(output))
+		}
+	}
+}
+
+func Test_Service_Set_XML(t *testing.T) {
+	testCases := []struct {
+		InputBytes []byte
+		Path       string
+		Value      string
+		Expected   []byte
+	}{
+		// Test case 1, ensure element text is replaced keeping namespaces,
+		// comments and whitespace.
+		{
+			InputBytes: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!-- comment -->
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+    <version>1.0.0</version> <!-- comment -->
+    <dependencies>
+        <dependency>
+            <artifactId>foo</artifactId>
+            <version>1.2</version>
+        </dependency>
+    </dependencies>
+</project>
+`),
+			Path:  `project.version`,
+			Value: "1.1.0",
+			Expected: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!-- comment -->
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+    <version>1.1.0</version> <!-- comment -->
+    <dependencies>
+        <dependency>
+            <artifactId>foo</artifactId>
+            <version>1.2</version>
+        </dependency>
+    </dependencies>
+</project>
+`),
+		},
+
+		// Test case 2, ensure elements occurring once can be selected like
+		// list items.
+		{
+			InputBytes: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!-- comment -->
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+    <version>1.0.0</version> <!-- comment -->
+    <dependencies>
+        <dependency>
+            <artifactId>foo</artifactId>
+            <version>1.2</version>
+        </dependency>
+    </dependencies>
+</project>
+`),
+			Path:  `project.dependencies.dependency[artifactId=foo].version`,
+			Value: "1.3",
+			Expected: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!-- comment -->
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+    <version>1.0.0</version> <!-- comment -->
+    <dependencies>
+        <dependency>
+            <artifactId>foo</artifactId>
+            <version>1.3</version>
+        </dependency>
+    </dependencies>
+</project>
+`),
+		},
+
+		// Test case 3, ensure elements are added next to the elements of the
+		// same name.
+		{
+			InputBytes: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!-- comment -->
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+    <version>1.0.0</version> <!-- comment -->
+    <dependencies>
+        <dependency>
+            <artifactId>foo</artifactId>
+            <version>1.2</version>
+        </dependency>
+    </dependencies>
+</project>
+`),
+			Path:  `project.dependencies.dependency[artifactId=bar].version`,
+			Value: "2.0",
+			Expected: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!-- comment -->
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+    <version>1.0.0</version> <!-- comment -->
+    <dependencies>
+        <dependency>
+            <artifactId>foo</artifactId>
+            <version>1.2</version>
+        </dependency>
+        <dependency>
+            <artifactId>bar</artifactId>
+            <version>2.0</version>
+        </dependency>
+    </dependencies>
+</project>
+`),
+		},
+
+		// Test case 4, ensure new child elements are added after the last
+		// child element.
+		{
+			InputBytes: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!-- comment -->
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+    <version>1.0.0</version> <!-- comment -->
+    <dependencies>
+        <dependency>
+            <artifactId>foo</artifactId>
+            <version>1.2</version>
+        </dependency>
+    </dependencies>
+</project>
+`),
+			Path:  `project.properties.java\.version`,
+			Value: "17",
+			Expected: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!-- comment -->
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+    <version>1.0.0</version> <!-- comment -->
+    <dependencies>
+        <dependency>
+            <artifactId>foo</artifactId>
+            <version>1.2</version>
+        </dependency>
+    </dependencies>
+    <properties>
+        <java.version>17</java.version>
+    </properties>
+</project>
+`),
+		},
+
+		// Test case 5, ensure attributes can be replaced.
+		{
+			InputBytes: []byte(`<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Include="Newtonsoft.Json" Version="12.0.1" />
+    <PackageReference Include="Serilog" Version="2.0.0" />
+  </ItemGroup>
+</Project>
+`),
+			Path:  `Project.ItemGroup.PackageReference[@Include=Serilog].@Version`,
+			Value: "3.0.0",
+			Expected: []byte(`<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Include="Newtonsoft.Json" Version="12.0.1" />
+    <PackageReference Include="Serilog" Version="3.0.0" />
+  </ItemGroup>
+</Project>
+`),
+		},
+
+		// Test case 6, ensure attributes are added at the end of the start
+		// tag.
+		{
+			InputBytes: []byte(`<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Include="Newtonsoft.Json" Version="12.0.1" />
+    <PackageReference Include="Serilog" Version="2.0.0" />
+  </ItemGroup>
+</Project>
+`),
+			Path:  `Project.ItemGroup.@Condition`,
+			Value: "'$(Configuration)' == 'Release'",
+			Expected: []byte(`<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup Condition="'$(Configuration)' == 'Release'">
+    <PackageReference Include="Newtonsoft.Json" Version="12.0.1" />
+    <PackageReference Include="Serilog" Version="2.0.0" />
+  </ItemGroup>
+</Project>
+`),
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes: tc.InputBytes,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		err = p.SetTyped(tc.Path, tc.Value, TypeAuto)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		output, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(tc.Expected, output) {
+			t.Fatal("test", i+1, "expected", string(tc.Expected), "got", string(output))
+		}
+	}
+}
+
+func Test_Service_Set_HCL(t *testing.T) {
+	testCases := []struct {
+		InputBytes []byte
+		Path       string
+		Value      string
+		Expected   []byte
+	}{
+		// Test case 1, ensure attributes are replaced keeping comments and the
+		// alignment of all other attributes.
+		{
+			InputBytes: []byte(`# comment
+
+region    = "eu-central-1" # comment
+image_tag = "v1.2.0"
+replicas  = 3
+`),
+			Path:  "image_tag",
+			Value: "v1.3.0",
+			Expected: []byte(`# comment
+
+region    = "eu-central-1" # comment
+image_tag = "v1.3.0"
+replicas  = 3
+`),
+		},
+
+		// Test case 2, ensure attributes of blocks are replaced keeping the
+		// comments within the blocks.
+		{
+			InputBytes: []byte(`resource "aws_instance" "web" {
+  # comment
+  instance_type = "t3.micro"
+  count         = var.replicas
+}
+`),
+			Path:  "resource.aws_instance.web.instance_type",
+			Value: "t3.large",
+			Expected: []byte(`resource "aws_instance" "web" {
+  # comment
+  instance_type = "t3.large"
+  count         = var.replicas
+}
+`),
+		},
+
+		// Test case 3, ensure values within objects are replaced, having the
+		// object formatted again.
+		{
+			InputBytes: []byte(`tags = {
+  team = "platform"
+  "cost-center" = 42
+}
+`),
+			Path:  "tags.team",
+			Value: "infra",
+			Expected: []byte(`tags = {
+  team          = "infra"
+  "cost-center" = 42
+}
+`),
+		},
+
+		// Test case 4, ensure values can be added to tuples.
+		{
+			InputBytes: []byte(`zones = ["a", "b"] # comment
+`),
+			Path:  "zones.[+2]",
+			Value: "c",
+			Expected: []byte(`zones = ["a", "b", "c"] # comment
+`),
+		},
+
+		// Test case 5, ensure attributes are added to blocks at the indentation
+		// of the blocks.
+		{
+			InputBytes: []byte(`module "vpc" {
+  source = "./vpc"
+}
+`),
+			Path:  "module.vpc.cidr",
+			Value: "10.0.0.0/16",
+			Expected: []byte(`module "vpc" {
+  source = "./vpc"
+  cidr = "10.0.0.0/16"
+}
+`),
+		},
+
+		// Test case 6, ensure blocks having the same type and labels can be
+		// selected like list items.
+		{
+			InputBytes: []byte(`ingress {
+  port = 80
+}
+ingress {
+  port = 443
+}
+`),
+			Path:  "ingress.[1].port",
+			Value: "8443",
+			Expected: []byte(`ingress {
+  port = 80
+}
+ingress {
+  port = 8443
+}
+`),
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes:  tc.InputBytes,
+				Format: FormatHCL,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		err = p.SetTyped(tc.Path, tc.Value, TypeAuto)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		output, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(tc.Expected, output) {
+			t.Fatal("test", i+1, "expected", string(tc.Expected), "got", string(output))
+		}
+	}
+}
+
+func Test_Service_Set_JSON5(t *testing.T) {
+	testCases := []struct {
+		InputBytes []byte
+		Path       string
+		Value      string
+		Type       string
+		Expected   []byte
+	}{
+		// Test case 1, ensure values are replaced keeping all comments.
+		{
+			InputBytes: []byte(`// comment
+{
+  "compilerOptions": {
+    "target": "ES2020", // comment
+    /* comment */
+    "strict": true
+  }
+}
+`),
+			Path:  "compilerOptions.target",
+			Value: "ES2022",
+			Type:  TypeAuto,
+			Expected: []byte(`// comment
+{
+  "compilerOptions": {
+    "target": "ES2022", // comment
+    /* comment */
+    "strict": true
+  }
+}
+`),
+		},
+
+		// Test case 2, ensure keys are added after the last member, keeping
+		// trailing commas.
+		{
+			InputBytes: []byte(`{
+  "compilerOptions": {
+    "target": "ES2020",
+    "strict": true,
+  },
+}
+`),
+			Path:  "compilerOptions.module",
+			Value: "esnext",
+			Type:  TypeAuto,
+			Expected: []byte(`{
+  "compilerOptions": {
+    "target": "ES2020",
+    "strict": true,
+    "module": "esnext",
+  },
+}
+`),
+		},
+
+		// Test case 3, ensure items are added after the last item, keeping the
+		// comment at the end of its line.
+		{
+			InputBytes: []byte(`{
+  "include": [
+    "src" // comment
+  ]
+}
+`),
+			Path:  "include.[+1]",
+			Value: "test",
+			Type:  TypeAuto,
+			Expected: []byte(`{
+  "include": [
+    "src", // comment
+    "test"
+  ]
+}
+`),
+		},
+
+		// Test case 4, ensure keys of JSON5 documents are added without quotes
+		// if the document writes its keys without quotes.
+		{
+			InputBytes: []byte(`{
+  // comment
+  extends: ['config:base'],
+  timeout: 0x10,
+}
+`),
+			Path:  "schedule",
+			Value: `["weekly"]`,
+			Type:  TypeJSON,
+			Expected: []byte(`{
+  // comment
+  extends: ['config:base'],
+  timeout: 0x10,
+  schedule: [
+    "weekly"
+  ],
+}
+`),
+		},
+
+		// Test case 5, ensure items are added to lists written on a single
+		// line.
+		{
+			InputBytes: []byte(`{
+  "lib": ["dom", "es2020"], // comment
+}
+`),
+			Path:  "lib.[+0]",
+			Value: "dom.iterable",
+			Type:  TypeAuto,
+			Expected: []byte(`{
+  "lib": ["dom.iterable", "dom", "es2020"], // comment
+}
+`),
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes:  tc.InputBytes,
+				Format: FormatJSON5,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		err = p.SetTyped(tc.Path, tc.Value, tc.Type)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		output, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(tc.Expected, output) {
+			t.Fatal("test", i+1, "expected", string(tc.Expected), "got", string(output))
+		}
+	}
+}
+
+func Test_Service_Set_JSONPath(t *testing.T) {
+	testCases := []struct {
+		InputBytes []byte
+		Path       string
+		Value      interface{}
+		Expected   []byte
+	}{
+		// Test case 1, ensure all values selected by a filter are changed.
+		{
+			InputBytes: []byte(`containers:
+- name: sidecar
+  image: s1
+- name: app # main
+  image: a1
+`),
+			Path:  "$.containers[?@.name=='app'].image",
+			Value: "a2",
+			Expected: []byte(`containers:
+- name: sidecar
+  image: s1
+- name: app # main
+  image: a2
+`),
+		},
+
+		// Test case 2, ensure singular queries create missing keys.
+		{
+			InputBytes: []byte(`spec:
+  replicas: 1
+`),
+			Path:  "$.spec['app.kubernetes.io/name']",
+			Value: "n1",
+			Expected: []byte(`spec:
+  replicas: 1
+  app.kubernetes.io/name: n1
+`),
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes:         tc.InputBytes,
+				QueryLanguage: QueryLanguageJSONPath,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		err = p.Set(tc.Path, tc.Value)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		output, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(tc.Expected, output) {
+			t.Fatal("test", i+1, "expected", string(tc.Expected), "got", string(output))
+		}
+	}
+}
+
+func Test_Service_Set_Error(t *testing.T) {
+	testCases := []struct {
+		InputBytes   []byte
+		Format       string
+		Path         string
+		ErrorMatcher func(error) bool
+	}{
+		// Test 1, when there is only 1 element in the list index [1] cannot be
+		// found.
+		{
+			InputBytes: []byte(`{
+  "k1": [
+    {
+      "k2": "v2"
+    }
+  ]
+}`),
+			Path:         "k1.[1].k2",
+			ErrorMatcher: IsNotFound,
+		},
+
+		// Test 2, when there are 2 elements in the list an item cannot be
+		// inserted at index [+3].
+		{
+			InputBytes: []byte(`k1:
+- v1
+- v2
+`),
+			Path:         "k1.[+3]",
+			ErrorMatcher: IsNotFound,
+		},
+
+		// Test 3, when the list k2 does not exist it cannot be created with an
+		// item at index [2].
+		{
+			InputBytes: []byte(`k1:
+  k3: v3
+`),
+			Path:         "k1.k2.[2].k4",
+			ErrorMatcher: IsNotFound,
+		},
+
+		// Test 4, HCL blocks cannot be created, since it is not known which
+		// keys of the path are labels.
+		{
+			InputBytes: []byte(`module "vpc" {
+  source = "./vpc"
+}
+`),
+			Format:       FormatHCL,
+			Path:         "module.db.source",
+			ErrorMatcher: IsInvalidFormat,
+		},
+
+		// Test 5, list items selected by a predicate cannot be created from
+		// values which are not objects, since the created items would not be
+		// selected by the predicate.
+		{
+			InputBytes: []byte(`containers:
+- name: app
+`),
+			Path:         "containers[name=new]",
+			ErrorMatcher: IsInvalidFormat,
+		},
+
+		// Test 6, the document root matched by a recursive wildcard cannot be
+		// set.
+		{
+			InputBytes: []byte(`k1:
+  k2: v2
+`),
+			Path:         "**",
+			ErrorMatcher: IsInvalidQuery,
+		},
+
+		// Test 7, the document root matched by a recursive wildcard cannot be
+		// set in JSON documents either.
+		{
+			InputBytes: []byte(`{
+  "k1": "v1"
+}
+`),
+			Path:         "**",
+			ErrorMatcher: IsInvalidQuery,
+		},
+	}
+
+	for i, tc := range testCases {
+		var err error
+
+		var p *Path
+		{
+			c := Config{
+				Bytes:  tc.InputBytes,
+				Format: tc.Format,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		err = p.Set(tc.Path, "value")
+		if !tc.ErrorMatcher(err) {
+			t.Fatal("test", i+1, "expected", true, "got", false)
+		}
+
+		output, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if string(output) != string(tc.InputBytes) {
+			t.Fatal("test", i+1, "expected", string(tc.InputBytes), "got", string(output))
+		}
+	}
+}
+
+func Test_Service_SetTyped(t *testing.T) {
+	testCases := []struct {
+		InputBytes []byte
+		Path       string
+		Value      string
+		Type       string
+		Expected   []byte
+	}{
+		// Test case 1, ensure the automatic type keeps numbers, booleans and
+		// strings typed in YAML documents.
+		{
+			InputBytes: []byte(`spec:
+  replicas: 1 # replicas
+  paused: true
+  tag: "2"
+`),
+			Path:  "spec.*",
+			Value: "3",
+			Type:  TypeAuto,
+			Expected: []byte(`spec:
+  replicas: 3 # replicas
+  paused: "3"
+  tag: "3"
+`),
 		},
 
-		// Test case 21, ensure the value of paths with separators inside keys can
-		// be modified.
+		// Test case 2, ensure the automatic type keeps numbers typed in JSON
+		// documents.
 		{
 			InputBytes: []byte(`{
-  "k1": {
-    "k2.k3": {
-      "k4": "v4"
-    }
+  "spec": {
+    "replicas": 1
   }
 }`),
-			Path:  `k1.k2\.k3.k4`,
-			Value: "modified",
+			Path:  "spec.replicas",
+			Value: "3",
+			Type:  TypeAuto,
 			Expected: []byte(`{
-  "k1": {
-    "k2.k3": {
-      "k4": "modified"
-    }
+  "spec": {
+    "replicas": 3
   }
 }`),
 		},
 
-		// Test case 22, ensure the value of a single unnested path can be modified,
-		// even though its value is empty.
+		// Test case 3, ensure values which cannot be converted to the type of
+		// the existing value are written as strings.
 		{
-			InputBytes: []byte(`{
-  "k1": ""
-}`),
-			Path:  "k1",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": "modified"
-}`),
+			InputBytes: []byte(`tag: 1
+`),
+			Path:  "tag",
+			Value: "8469445",
+			Type:  TypeAuto,
+			Expected: []byte(`tag: 8469445
+`),
 		},
 
-		// Test case 23, ensure the value of a single nested path can be modified,
-		// even though its value is empty.
+		// Test case 4, ensure numbers are written as given.
 		{
-			InputBytes: []byte(`{
-  "k1": {
-    "k2": {
-      "k3": ""
-    }
-  }
-}`),
-			Path:  "k1.k2.k3",
-			Value: "modified",
-			Expected: []byte(`{
-  "k1": {
-    "k2": {
-      "k3": "modified"
-    }
-  }
-}`),
+			InputBytes: []byte(`version: 1.9
+`),
+			Path:  "version",
+			Value: "1.10",
+			Type:  TypeFloat,
+			Expected: []byte(`version: 1.10
+`),
 		},
 
-		// Test case 24, ensure the value of a single unnested path can be set,
-		// even though its key is missing.
+		// Test case 5, ensure new keys can be typed explicitly.
 		{
-			InputBytes: []byte(`{
-  "k1": ""
-}`),
+			InputBytes: []byte(`k1: v1
+`),
 			Path:  "k2",
-			Value: "added",
-			Expected: []byte(`{
-  "k1": "",
-  "k2": "added"
-}`),
+			Value: "true",
+			Type:  TypeBool,
+			Expected: []byte(`k1: v1
+k2: true
+`),
 		},
 
-		// Test case 25, ensure the value of a multi level nested path can be set,
-		// even though its key is missing.
+		// Test case 6, ensure strings looking like other types are quoted.
 		{
-			InputBytes: []byte(`{
-  "k1": {}
-}`),
-			Path:  "k1.s1.e3",
-			Value: "added",
-			Expected: []byte(`{
-  "k1": {
-    "s1": {
-      "e3": "added"
-    }
-  }
-}`),
+			InputBytes: []byte(`replicas: 1
+`),
+			Path:  "replicas",
+			Value: "3",
+			Type:  TypeString,
+			Expected: []byte(`replicas: "3"
+`),
 		},
 
-		// Test case 26, ensure the value of a multi level nested path can be set,
-		// even though its key is missing.
+		// Test case 7, ensure null values can be written without value.
 		{
-			InputBytes: []byte(`{
-  "k1": ""
-}`),
-			Path:  "k2.s1.e3",
-			Value: "added",
-			Expected: []byte(`{
-  "k1": "",
-  "k2": {
-    "s1": {
-      "e3": "added"
-    }
-  }
-}`),
+			InputBytes: []byte(`k1: v1
+`),
+			Path:  "k1",
+			Value: "",
+			Type:  TypeNull,
+			Expected: []byte(`k1: null
+`),
+		},
+
+		// Test case 8, ensure JSON values are written in the style of YAML
+		// documents.
+		{
+			InputBytes: []byte(`k1: v1
+`),
+			Path:  "k2",
+			Value: `{"k3": ["v3"]}`,
+			Type:  TypeJSON,
+			Expected: []byte(`k1: v1
+k2:
+  k3:
+    - v3
+`),
+		},
+
+		// Test case 9, ensure YAML values can be written.
+		{
+			InputBytes: []byte(`k1: v1
+`),
+			Path: "k1",
+			Value: `k2: v2
+k3: [v3]
+`,
+			Type: TypeYAML,
+			Expected: []byte(`k1:
+  k2: v2
+  k3: [v3]
+`),
+		},
+
+		// Test case 10, ensure whole lists can be replaced.
+		{
+			InputBytes: []byte(`spec:
+  tolerations:
+  - key: k1
+    effect: NoSchedule
+  replicas: 1
+`),
+			Path: "spec.tolerations",
+			Value: `- key: k2
+  operator: Exists
+`,
+			Type: TypeYAML,
+			Expected: []byte(`spec:
+  tolerations:
+  - key: k2
+    operator: Exists
+  replicas: 1
+`),
+		},
+
+		// Test case 11, ensure objects can be written into documents embedded
+		// in strings.
+		{
+			InputBytes: []byte(`data:
+  config.yaml: |
+    k1: v1
+`),
+			Path:  "data.config\\.yaml.k2",
+			Value: `{"k3": ["v3"]}`,
+			Type:  TypeJSON,
+			Expected: []byte(`data:
+  config.yaml: |
+    k1: v1
+    k2:
+      k3:
+        - v3
+`),
+		},
+
+		// Test case 12, ensure list items selected by a predicate keep the
+		// predicate's key when being replaced as a whole.
+		{
+			InputBytes: []byte(`containers:
+- name: sidecar
+  image: s1
+- name: app
+  image: a1
+`),
+			Path:  "containers[name=app]",
+			Value: `{"image": "a2"}`,
+			Type:  TypeJSON,
+			Expected: []byte(`containers:
+- name: sidecar
+  image: s1
+- name: app
+  image: a2
+`),
 		},
 	}
 
@@ -1095,7 +4630,7 @@ k4:
 			}
 		}
 
-		err = p.Set(tc.Path, tc.Value)
+		err = p.SetTyped(tc.Path, tc.Value, tc.Type)
 		if err != nil {
 			t.Fatal("test", i+1, "expected", nil, "got", err)
 		}
@@ -1110,24 +4645,31 @@ k4:
 	}
 }
 
-func Test_Service_Set_Error(t *testing.T) {
+func Test_Service_SetTyped_Error(t *testing.T) {
 	testCases := []struct {
-		InputBytes   []byte
-		Path         string
+		Value        string
+		Type         string
 		ErrorMatcher func(error) bool
 	}{
-		// Test 1, when there is only 1 element in the list index [1] cannot be
-		// found.
+		// Test case 1, ensure values must match their type.
 		{
-			InputBytes: []byte(`{
-  "k1": [
-    {
-      "k2": "v2"
-    }
-  ]
-}`),
-			Path:         "k1.[1].k2",
-			ErrorMatcher: IsNotFound,
+			Value:        "v2",
+			Type:         TypeInt,
+			ErrorMatcher: IsInvalidFormat,
+		},
+
+		// Test case 2, ensure JSON values must be valid JSON.
+		{
+			Value:        "k2: v2",
+			Type:         TypeJSON,
+			ErrorMatcher: IsInvalidFormat,
+		},
+
+		// Test case 3, ensure unknown types are rejected.
+		{
+			Value:        "v2",
+			Type:         "unknown",
+			ErrorMatcher: IsInvalidConfig,
 		},
 	}
 
@@ -1137,7 +4679,8 @@ func Test_Service_Set_Error(t *testing.T) {
 		var p *Path
 		{
 			c := Config{
-				Bytes: tc.InputBytes,
+				Bytes: []byte(`k1: v1
+`),
 			}
 
 			p, err = New(c)
@@ -1146,7 +4689,235 @@ func Test_Service_Set_Error(t *testing.T) {
 			}
 		}
 
-		err = p.Set(tc.Path, "value")
+		err = p.SetTyped("k1", tc.Value, tc.Type)
+		if !tc.ErrorMatcher(err) {
+			t.Fatal("test", i+1, "expected", true, "got", false)
+		}
+	}
+}
+
+func Test_Service_Sops(t *testing.T) {
+	testCases := []struct {
+		Path     string
+		Value    string
+		Expected map[string]string
+		Contains []string
+	}{
+		// Test case 1, ensure documents which are not modified are written
+		// exactly as they were read.
+		{
+			Expected: map[string]string{
+				"data.password": "hunter2",
+				"data.port":     "5432",
+			},
+		},
+
+		// Test case 2, ensure modified values are encrypted again, while all
+		// other values keep their ciphertext.
+		{
+			Path:  "data.password",
+			Value: "s3cret",
+			Expected: map[string]string{
+				"data.password": "s3cret",
+				"data.port":     "5432",
+			},
+			Contains: []string{
+				"    port: ENC[AES256_GCM,data:xh0IFQ==,",
+			},
+		},
+
+		// Test case 3, ensure added values are encrypted.
+		{
+			Path:  "data.token",
+			Value: "abc",
+			Expected: map[string]string{
+				"data.password": "hunter2",
+				"data.token":    "abc",
+			},
+			Contains: []string{
+				"    password: ENC[AES256_GCM,data:w2vtwemuHg==,",
+				"    token: ENC[AES256_GCM,",
+			},
+		},
+
+		// Test case 4, ensure values having the unencrypted suffix are written
+		// as plaintext, while being covered by the MAC.
+		{
+			Path:  "data.note_unencrypted",
+			Value: "shown",
+			Expected: map[string]string{
+				"data.note_unencrypted": "shown",
+			},
+			Contains: []string{
+				"    password: ENC[AES256_GCM,data:w2vtwemuHg==,",
+				"    note_unencrypted: shown\n",
+			},
+		},
+
+		// Test case 5, ensure modified list items and booleans are encrypted
+		// again with the types sops records for them.
+		{
+			Path:  "data.hosts.[1]",
+			Value: "db-2",
+			Expected: map[string]string{
+				"data.debug":     "false",
+				"data.hosts.[0]": "db-0",
+				"data.hosts.[1]": "db-2",
+			},
+			Contains: []string{
+				"    debug: ENC[AES256_GCM,data:G79uRNY=,",
+				",type:str]\n",
+			},
+		},
+	}
+
+	os.Setenv("SOPS_AGE_KEY", sopsTestKey)
+	defer os.Unsetenv("SOPS_AGE_KEY")
+
+	for i, tc := range testCases {
+		s, err := sops.New(sops.Config{})
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		var p *Path
+		{
+			c := Config{
+				Bytes: sopsTestDocument,
+				Sops:  s,
+			}
+
+			p, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		if tc.Path != "" {
+			err = p.SetTyped(tc.Path, tc.Value, TypeAuto)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		output, err := p.OutputBytes()
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if tc.Path == "" && string(output) != string(sopsTestDocument) {
+			t.Fatal("test", i+1, "expected", string(sopsTestDocument), "got", string(output))
+		}
+		for _, c := range tc.Contains {
+			if !strings.Contains(string(output), c) {
+				t.Fatal("test", i+1, "expected", c, "got", string(output))
+			}
+		}
+
+		// Reading the output again verifies its MAC.
+		var e *Path
+		{
+			c := Config{
+				Bytes: output,
+				Sops:  s,
+			}
+
+			e, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		for k, v := range tc.Expected {
+			g, err := e.Get(k)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+			if fmt.Sprint(g) != v {
+				t.Fatal("test", i+1, "expected", v, "got", g)
+			}
+		}
+
+		// The output must be accepted by the sops binary too, if it is
+		// installed.
+		d, err := sopsDecrypt(output)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if d == nil {
+			continue
+		}
+
+		var x *Path
+		{
+			c := Config{
+				Bytes: d,
+			}
+
+			x, err = New(c)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+		}
+
+		for k, v := range tc.Expected {
+			g, err := x.Get(k)
+			if err != nil {
+				t.Fatal("test", i+1, "expected", nil, "got", err)
+			}
+			if fmt.Sprint(g) != v {
+				t.Fatal("test", i+1, "expected", v, "got", g)
+			}
+		}
+	}
+}
+
+func Test_Service_Sops_Error(t *testing.T) {
+	testCases := []struct {
+		InputBytes   []byte
+		Key          string
+		ErrorMatcher func(error) bool
+	}{
+		// Test case 1, ensure documents whose data key is not encrypted for
+		// any key available cause an error.
+		{
+			InputBytes:   sopsTestDocument,
+			Key:          "AGE-SECRET-KEY-10H6PJPECP96NFWSW7U56GJTGLH7LC9TPCMXT363WYFRRZKUDGWGQ3E5ZTW",
+			ErrorMatcher: sops.IsMissingKey,
+		},
+
+		// Test case 2, ensure documents having plaintext values modified
+		// without updating the MAC cause an error.
+		{
+			InputBytes:   []byte(strings.Replace(string(sopsTestDocument), "note_unencrypted: visible", "note_unencrypted: forged", 1)),
+			Key:          sopsTestKey,
+			ErrorMatcher: sops.IsDecryptionFailed,
+		},
+
+		// Test case 3, ensure documents having encrypted values modified cause
+		// an error.
+		{
+			InputBytes:   []byte(strings.Replace(string(sopsTestDocument), "data:w2vtwemuHg==", "data:w2vtwemuHw==", 1)),
+			Key:          sopsTestKey,
+			ErrorMatcher: sops.IsDecryptionFailed,
+		},
+	}
+
+	defer os.Unsetenv("SOPS_AGE_KEY")
+
+	for i, tc := range testCases {
+		os.Setenv("SOPS_AGE_KEY", tc.Key)
+
+		s, err := sops.New(sops.Config{})
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+
+		c := Config{
+			Bytes: tc.InputBytes,
+			Sops:  s,
+		}
+
+		_, err = New(c)
 		if !tc.ErrorMatcher(err) {
 			t.Fatal("test", i+1, "expected", true, "got", false)
 		}
@@ -1224,3 +4995,71 @@ func Test_Service_Validate(t *testing.T) {
 		}
 	}
 }
+
+// sopsDecrypt returns the given document decrypted by the sops binary, or nil
+// if the sops binary is not installed.
+func sopsDecrypt(b []byte) ([]byte, error) {
+	_, err := exec.LookPath("sops")
+	if err != nil {
+		return nil, nil
+	}
+
+	f, err := ioutil.TempFile("", "dsm-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(b)
+	if err != nil {
+		return nil, err
+	}
+	err = f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var o bytes.Buffer
+	c := exec.Command("sops", "-d", f.Name())
+	c.Stderr = &o
+
+	d, err := c.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err, o.String())
+	}
+
+	return d, nil
+}
+
+// sopsTestKey is the age identity the sops repository publishes for its own
+// tests in age/keysource_test.go, having the recipient
+// age1lzd99uklcjnc0e7d860axevet2cz99ce9pq6tzuzd05l5nr28ams36nvun.
+const sopsTestKey = "AGE-SECRET-KEY-1G0Q5K9TV4REQ3ZSQRMTMG8NSWQGYT0T7TZ33RAZEE0GZYVZN0APSU24RK7"
+
+// sopsTestDocument is a document encrypted by the sops 3.13.3 binary for
+// sopsTestKey, using sops encrypt --age <recipient> --unencrypted-suffix
+// _unencrypted.
+var sopsTestDocument = []byte(`data:
+    password: ENC[AES256_GCM,data:w2vtwemuHg==,iv:+2mkSYe+DEzyzHLiqGnXrdIw3LFbY289oicalTvTu4A=,tag:z55hGjjlRgsaU+N1TcwrWw==,type:str]
+    port: ENC[AES256_GCM,data:xh0IFQ==,iv:MOnasQXD2c4dXtRPJUU1hM7ZFR9ltblXp3bK53xxExs=,tag:vqvcH4SZMbyCWQkGVFz0Ug==,type:int]
+    debug: ENC[AES256_GCM,data:G79uRNY=,iv:tzDiRAngAcjJfsh05AZP4WT5ljbcj9J6++0yAezTlNY=,tag:8eckePHRnUgmZiADrNsU9g==,type:bool]
+    hosts:
+        - ENC[AES256_GCM,data:S/x3kw==,iv:00C5uHq2HkxsU4fqcCkEFN4cm8EMnXCxTr3d88kJGvU=,tag:rkit79v7qxIxfhvl5TkTnQ==,type:str]
+        - ENC[AES256_GCM,data:D1G/WQ==,iv:bRgP+K5N3PPCuxUlvKyAPhpkFzpCLIyCiqUf8v46cRo=,tag:TV+Pf5wILRBPvTdeC3uvTg==,type:str]
+    note_unencrypted: visible
+sops:
+    age:
+        - enc: |
+            -----BEGIN AGE ENCRYPTED FILE-----
+            YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBaUTVJL3ZDaW5wRWc2eUZh
+            VzJpYVBZLzdjRWFxUWU3S0xrOUZGeDE4NkRrClBZclV6R2F1VThndXVtc2lhYmdu
+            bGhWNUlURHNpbjNMSkFSNzBuK0xwbGcKLS0tIElLajlGRVp0cmVIS1BHVXc3cDFx
+            R1AwOWlZSDhiWHZoTnV4cUMwRzFpZVUKVypFEY8Gi5p7fcXN1wiNbrha3wgB8gXZ
+            ZE3FTBAxlrgtd/ZcYvbmAPdIrF94I0luqRjOFcPCvjhyNxGxfE3v1w==
+            -----END AGE ENCRYPTED FILE-----
+          recipient: age1lzd99uklcjnc0e7d860axevet2cz99ce9pq6tzuzd05l5nr28ams36nvun
+    lastmodified: "2026-10-17T03:11:19Z"
+    mac: ENC[AES256_GCM,data:H/uvjB2OFUUij0TXrqWHWkRJGmg8ODexd+2jwUui7Uo/7MmnPPrZtk06qvaVh0sLp0G79U/a6i7tX88bbFYvQSUg6mjm2zqj5vQ3dBTm1SEiJ4hy2JdAz7+hpbx1hVhKgwWL/WQcXGKPHvnlVK0w0TO+9NBNjezXWwlcaMW4Pzs=,iv:M3ZtLxoRnt1McJT2y72jJMlt5IBhiDCkA0cggWz1AoM=,tag:kKuvbdhNJzo53hvruI9blw==,type:str]
+    unencrypted_suffix: _unencrypted
+    version: 3.13.3
+`)
@@ -0,0 +1,130 @@
+package path
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	predicateExpression = regexp.MustCompile(`^\[([^=\[\]]+)=(.*)\]$`)
+)
+
+// predicate selects the items of a list which are objects holding a certain
+// value under a certain key. Kubernetes lists like containers or env are
+// keyed by name, so predicates like [name=app] select list items regardless
+// of their position.
+type predicate struct {
+	key    string
+	quoted bool
+	value  string
+}
+
+// predicateFromKey returns the predicate described by the given path segment,
+// if any. The value may be quoted in order to express e.g. surrounding spaces.
+func predicateFromKey(key string) (predicate, bool) {
+	m := predicateExpression.FindStringSubmatch(key)
+	if m == nil {
+		return predicate{}, false
+	}
+
+	pr := predicate{
+		key:   strings.TrimSpace(m[1]),
+		value: m[2],
+	}
+
+	v := pr.value
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		pr.quoted = true
+		pr.value = v[1 : len(v)-1]
+	}
+
+	return pr, true
+}
+
+// indices returns the indices of all items of the given sequence matching the
+// predicate.
+func (pr predicate) indices(n *yaml.Node) []int {
+	var l []int
+
+	for i, c := range n.Content {
+		if pr.matches(c) {
+			l = append(l, i)
+		}
+	}
+
+	return l
+}
+
+// item returns the list item to be written for the given node, which must be
+// an object. The item holds the predicate's key and value in addition to the
+// given node, so that the item is selected by the predicate once written.
+func (pr predicate) item(n *yaml.Node) (*yaml.Node, error) {
+	if resolve(n).Kind != yaml.MappingNode {
+		return nil, tracer.Maskf(invalidFormatError, "value of list items selected by [%s=%s] must be an object", pr.key, pr.value)
+	}
+	if lookup(resolve(n), pr.key) != nil {
+		if !pr.matches(n) {
+			return nil, tracer.Maskf(invalidFormatError, "value of list items selected by [%s=%s] must not define %s differently", pr.key, pr.value, pr.key)
+		}
+
+		return n, nil
+	}
+
+	// Unquoted values are typed the way YAML would type them, so that e.g.
+	// [port=80] creates a number.
+	v := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: pr.value}
+	if !pr.quoted {
+		var d yaml.Node
+		err := yaml.Unmarshal([]byte(pr.value), &d)
+		if err == nil && len(d.Content) == 1 && d.Content[0].Kind == yaml.ScalarNode {
+			v.Tag = d.Content[0].Tag
+		}
+	}
+
+	item := &yaml.Node{
+		Kind: yaml.MappingNode,
+		Tag:  "!!map",
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: pr.key},
+			v,
+		},
+	}
+
+	item.Content = append(item.Content, resolve(n).Content...)
+
+	return item, nil
+}
+
+// matches returns whether the given list item matches the predicate.
+func (pr predicate) matches(n *yaml.Node) bool {
+	n = resolve(n)
+	if n.Kind != yaml.MappingNode {
+		return false
+	}
+
+	v := lookup(n, pr.key)
+	if v == nil {
+		return false
+	}
+
+	v = resolve(v)
+	if v.Kind != yaml.ScalarNode {
+		return false
+	}
+
+	return v.Value == pr.value
+}
+
+// isPredicate returns whether the given path segments contain predicates.
+func isPredicate(split []string) bool {
+	for _, s := range split {
+		if _, ok := predicateFromKey(s); ok {
+			return true
+		}
+	}
+
+	return false
+}
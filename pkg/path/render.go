@@ -0,0 +1,316 @@
+package path
+
+import (
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// renderer writes YAML nodes as text that fits into an existing document. The
+// indentation settings are detected from the document a Path was created
+// from, so that new structures look like they have always been there.
+type renderer struct {
+	// indentless is true if block sequences inside of mappings are written at
+	// the same column as their parent key.
+	indentless bool
+	// step is the amount of spaces nested block structures are indented with.
+	step int
+}
+
+func newRenderer(n *yaml.Node) *renderer {
+	r := &renderer{
+		step: 2,
+	}
+
+	var mapping bool
+	var sequence bool
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if mapping && sequence {
+			return
+		}
+
+		if n.Kind == yaml.MappingNode && n.Style&yaml.FlowStyle == 0 {
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				k := n.Content[i]
+				v := n.Content[i+1]
+
+				if v.Style&yaml.FlowStyle != 0 || len(v.Content) == 0 {
+					continue
+				}
+
+				switch v.Kind {
+				case yaml.MappingNode:
+					if !mapping && v.Content[0].Line > k.Line && v.Content[0].Column > k.Column {
+						r.step = v.Content[0].Column - k.Column
+						mapping = true
+					}
+				case yaml.SequenceNode:
+					if !sequence && v.Line > k.Line {
+						r.indentless = v.Column == k.Column
+						sequence = true
+					}
+				}
+			}
+		}
+
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+
+	walk(n)
+
+	return r
+}
+
+// block returns the block representation of the given node. The first line
+// is not indented, all following lines are indented to the given column.
+func (r *renderer) block(n *yaml.Node, indent int) string {
+	var b strings.Builder
+
+	switch {
+	case n.Kind == yaml.MappingNode && len(n.Content) != 0 && n.Style&yaml.FlowStyle == 0:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i != 0 {
+				b.WriteString("\n" + spaces(indent))
+			}
+			b.WriteString(r.scalar(n.Content[i], false))
+			b.WriteString(":")
+			b.WriteString(r.value(n.Content[i+1], indent))
+		}
+	case n.Kind == yaml.SequenceNode && len(n.Content) != 0 && n.Style&yaml.FlowStyle == 0:
+		for i, c := range n.Content {
+			if i != 0 {
+				b.WriteString("\n" + spaces(indent))
+			}
+			b.WriteString("-")
+			b.WriteString(r.item(c, indent))
+		}
+	case isLiteral(n):
+		b.WriteString(r.literal(n.Value, indent, false))
+	default:
+		b.WriteString(r.flow(n))
+	}
+
+	return b.String()
+}
+
+// flow returns the flow representation of the given node.
+func (r *renderer) flow(n *yaml.Node) string {
+	var l []string
+
+	switch n.Kind {
+	case yaml.AliasNode:
+		return "*" + n.Value
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			l = append(l, r.scalar(n.Content[i], true)+": "+r.flow(n.Content[i+1]))
+		}
+		return "{" + strings.Join(l, ", ") + "}"
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			l = append(l, r.flow(c))
+		}
+		return "[" + strings.Join(l, ", ") + "]"
+	}
+
+	return r.scalar(n, true)
+}
+
+// inline returns the representation of the given node if it can be written
+// on a single line within block context.
+func (r *renderer) inline(n *yaml.Node) (string, bool) {
+	if isLiteral(n) {
+		return "", false
+	}
+	if isBlock(n) {
+		return "", false
+	}
+
+	return r.flow(n), true
+}
+
+// item returns the representation of the given node as sequence item, to be
+// written right after the sequence indicator at the given column.
+func (r *renderer) item(n *yaml.Node, indent int) string {
+	if s, ok := r.inline(n); ok {
+		return " " + s
+	}
+
+	return " " + r.block(n, indent+2)
+}
+
+// literal returns the given string as literal block scalar, or as folded block
+// scalar if folded is true. The content lines are indented to the given column.
+func (r *renderer) literal(s string, indent int, folded bool) string {
+	c := s
+	h := "|"
+	if folded {
+		h = ">"
+	}
+
+	switch {
+	case !strings.HasSuffix(s, "\n"):
+		h += "-"
+	case strings.HasSuffix(s, "\n\n"):
+		h += "+"
+		c = s[:len(s)-1]
+	default:
+		c = s[:len(s)-1]
+	}
+
+	lines := strings.Split(c, "\n")
+	if folded {
+		lines = fold(lines)
+	}
+
+	var b strings.Builder
+	b.WriteString(h)
+	for _, l := range lines {
+		b.WriteString("\n")
+		if l != "" {
+			b.WriteString(spaces(indent) + l)
+		}
+	}
+
+	return b.String()
+}
+
+// replace returns the representation of the given scalar, written in the
+// style of the old scalar it replaces.
+func (r *renderer) replace(o *yaml.Node, n *yaml.Node, indent int, flow bool) string {
+	if n.Tag != "!!str" {
+		return n.Value
+	}
+
+	switch {
+	case o.Style&yaml.DoubleQuotedStyle != 0:
+		return strconv.Quote(n.Value)
+	case o.Style&yaml.SingleQuotedStyle != 0:
+		if !canSingleQuote(n.Value) {
+			return strconv.Quote(n.Value)
+		}
+		return "'" + strings.ReplaceAll(n.Value, "'", "''") + "'"
+	case o.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 && canLiteral(n.Value):
+		return r.literal(n.Value, indent, o.Style&yaml.FoldedStyle != 0)
+	case isLiteral(n) && !flow:
+		return r.literal(n.Value, indent, false)
+	}
+
+	return r.scalar(n, flow)
+}
+
+// scalar returns the single line representation of the given scalar.
+func (r *renderer) scalar(n *yaml.Node, flow bool) string {
+	if n.Tag != "!!str" && n.Tag != "" {
+		return n.Value
+	}
+
+	switch {
+	case n.Style&yaml.SingleQuotedStyle != 0 && canSingleQuote(n.Value):
+		return "'" + strings.ReplaceAll(n.Value, "'", "''") + "'"
+	case canPlain(n.Value, flow) && n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) == 0:
+		return n.Value
+	}
+
+	return strconv.Quote(n.Value)
+}
+
+// value returns the representation of the given node as mapping value, to be
+// written right after the colon of a key at the given column.
+func (r *renderer) value(n *yaml.Node, indent int) string {
+	if s, ok := r.inline(n); ok {
+		return " " + s
+	}
+	if isLiteral(n) {
+		return " " + r.literal(n.Value, indent+r.step, false)
+	}
+	if n.Kind == yaml.SequenceNode && r.indentless {
+		return "\n" + spaces(indent) + r.block(n, indent)
+	}
+
+	return "\n" + spaces(indent+r.step) + r.block(n, indent+r.step)
+}
+
+func canLiteral(s string) bool {
+	if strings.HasPrefix(s, " ") || strings.HasPrefix(s, "\n") {
+		return false
+	}
+
+	for _, c := range s {
+		if c < ' ' && c != '\n' && c != '\t' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func canPlain(s string, flow bool) bool {
+	if s == "" {
+		return false
+	}
+	if flow && strings.ContainsAny(s, ",[]{}") {
+		return false
+	}
+
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		return false
+	}
+
+	return string(b) == s+"\n"
+}
+
+func canSingleQuote(s string) bool {
+	for _, c := range s {
+		if c < ' ' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fold returns the lines to write for the given lines of a folded block
+// scalar. Line breaks between lines not starting with white space are folded
+// into spaces when reading folded block scalars, so an empty line is added
+// between such lines in order to keep their line breaks.
+func fold(lines []string) []string {
+	isText := func(l string) bool {
+		return !strings.HasPrefix(l, " ") && !strings.HasPrefix(l, "\t")
+	}
+
+	var l []string
+	p := -1
+	for i, x := range lines {
+		if x != "" {
+			if p != -1 && isText(lines[p]) && isText(x) {
+				l = append(l, "")
+			}
+			p = i
+		}
+
+		l = append(l, x)
+	}
+
+	return l
+}
+
+// isBlock returns whether the given node is a non empty collection that is
+// not written in flow style.
+func isBlock(n *yaml.Node) bool {
+	return (n.Kind == yaml.MappingNode || n.Kind == yaml.SequenceNode) && len(n.Content) != 0 && n.Style&yaml.FlowStyle == 0
+}
+
+// isLiteral returns whether the given node is a multi line string that is best
+// written as literal block scalar.
+func isLiteral(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.Tag == "!!str" && n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) == 0 && strings.Contains(n.Value, "\n") && canLiteral(n.Value)
+}
+
+func spaces(n int) string {
+	return strings.Repeat(" ", n)
+}
@@ -0,0 +1,314 @@
+package path
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/xh3b4sd/dsm/pkg/sops"
+)
+
+const (
+	// sopsKey is the top level key holding the metadata of documents
+	// encrypted with SOPS.
+	sopsKey = "sops"
+)
+
+// sopsDocument describes a YAML or JSON document encrypted with SOPS, which
+// is read and modified as plaintext.
+type sopsDocument struct {
+	// bytes are the encrypted bytes the document was read from.
+	bytes []byte
+	// key is the data key all values of the document are encrypted with.
+	key []byte
+	// mac is the code the document was read with, before being encrypted.
+	mac      string
+	metadata sops.Metadata
+	// plain are the decrypted bytes the document was read as.
+	plain []byte
+	// values are the encrypted values of the document together with their
+	// plaintext, by their location within the document.
+	values map[string]sopsValue
+}
+
+type sopsValue struct {
+	encrypted string
+	plain     interface{}
+}
+
+// decryptSops decrypts all values of the configured document, in case it is
+// a YAML or JSON document having SOPS metadata, and verifies its MAC. Other
+// documents are left as they are.
+func (p *Path) decryptSops(s *sops.Sops) error {
+	if !p.isYAML() && !p.isJSON {
+		return nil
+	}
+
+	m := sopsMetadata(p.node)
+	if m == nil {
+		return nil
+	}
+
+	d := &sopsDocument{
+		bytes:  p.bytes,
+		values: map[string]sopsValue{},
+	}
+
+	err := m.Decode(&d.metadata)
+	if err != nil {
+		return tracer.Maskf(invalidFormatError, "%s must be valid SOPS metadata", sopsKey)
+	}
+
+	d.key, err = s.DataKey(d.metadata)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	mac := sops.NewMAC(d.metadata)
+
+	err = walkSops(p.root(), nil, "", func(n *yaml.Node, keys []string, location string) error {
+		var v interface{}
+		err := n.Decode(&v)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+		if v == nil {
+			return nil
+		}
+
+		encrypted := d.metadata.Encrypted(keys)
+		if encrypted {
+			e, ok := v.(string)
+			if !ok {
+				return tracer.Maskf(invalidFormatError, "value of %s must be encrypted", strings.Join(keys, p.separator))
+			}
+
+			v, err = sops.Decrypt(e, d.key, sops.AdditionalData(keys))
+			if err != nil {
+				return tracer.Mask(err)
+			}
+
+			d.values[location] = sopsValue{encrypted: e, plain: v}
+
+			if e != "" {
+				var r yaml.Node
+				err = r.Encode(v)
+				if err != nil {
+					return tracer.Mask(err)
+				}
+
+				err = p.replace(n, &r)
+				if err != nil {
+					return tracer.Mask(err)
+				}
+			}
+		}
+
+		err = mac.Add(v, encrypted)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	d.mac = mac.Sum()
+
+	err = d.metadata.Verify(d.mac, d.key)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	err = p.apply()
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	d.plain = p.bytes
+	p.sops = d
+
+	return nil
+}
+
+// encryptSops returns the configured document encrypted again. Values which
+// did not change keep their ciphertext, so that the document only differs in
+// the values actually modified. The MAC and the time of the last modification
+// are only updated if the MAC changed.
+func (p *Path) encryptSops() ([]byte, error) {
+	d := p.sops
+
+	if bytes.Equal(p.bytes, d.plain) {
+		return d.bytes, nil
+	}
+
+	var e *Path
+	{
+		c := Config{
+			Bytes:     p.bytes,
+			Format:    p.language,
+			Separator: p.separator,
+		}
+
+		var err error
+		e, err = New(c)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	mac := sops.NewMAC(d.metadata)
+
+	err := walkSops(e.root(), nil, "", func(n *yaml.Node, keys []string, location string) error {
+		var v interface{}
+		err := n.Decode(&v)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+		if v == nil {
+			return nil
+		}
+
+		encrypted := d.metadata.Encrypted(keys)
+
+		err = mac.Add(v, encrypted)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		if !encrypted {
+			return nil
+		}
+
+		s, ok := d.values[location]
+		if !ok || !reflect.DeepEqual(s.plain, v) {
+			s.encrypted, err = sops.Encrypt(v, d.key, sops.AdditionalData(keys))
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+
+		if s.encrypted == "" {
+			return nil
+		}
+
+		var r yaml.Node
+		err = r.Encode(s.encrypted)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		// Encrypted values are written as plain scalars, even where their
+		// plaintext was written as block scalar. Their untagged nodes are
+		// rendered as they are.
+		if n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			r.Tag = ""
+		}
+
+		err = e.replace(n, &r)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	if mac.Sum() != d.mac {
+		l, m, err := sops.Sign(mac.Sum(), d.key, time.Now())
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		s := sopsMetadata(e.node)
+		if s == nil {
+			return nil, tracer.Maskf(invalidFormatError, "%s must not be removed", sopsKey)
+		}
+
+		for _, kv := range [][2]string{{"lastmodified", l}, {"mac", m}} {
+			k, v := kv[0], kv[1]
+
+			n := lookup(s, k)
+			if n == nil {
+				return nil, tracer.Maskf(invalidFormatError, "%s.%s must not be removed", sopsKey, k)
+			}
+
+			var r yaml.Node
+			err = r.Encode(v)
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+
+			err = e.replace(n, &r)
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+		}
+	}
+
+	err = e.apply()
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return e.bytes, nil
+}
+
+// sopsMetadata returns the SOPS metadata of the given document, if any.
+func sopsMetadata(n *yaml.Node) *yaml.Node {
+	if len(n.Content) == 0 || n.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+
+	m := lookup(n.Content[0], sopsKey)
+	if m == nil || m.Kind != yaml.MappingNode || lookup(m, "mac") == nil {
+		return nil
+	}
+
+	return m
+}
+
+// walkSops calls the given function for all scalar values of the given node
+// except the SOPS metadata, in the order they appear in the document. The
+// function is given the keys leading to each value, which exclude list
+// indices as SOPS uses them, and the location of each value, which includes
+// list indices.
+func walkSops(n *yaml.Node, keys []string, location string, f func(n *yaml.Node, keys []string, location string) error) error {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i].Value
+			if location == "" && k == sopsKey {
+				continue
+			}
+
+			err := walkSops(n.Content[i+1], with(keys, k), location+"\x00"+k, f)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			err := walkSops(c, keys, location+"\x00["+strconv.Itoa(i)+"]", f)
+			if err != nil {
+				return tracer.Mask(err)
+			}
+		}
+	case yaml.ScalarNode:
+		err := f(n, keys, location)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,405 @@
+package path
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// source provides byte level access to the YAML document a Path was created
+// from. Node positions reported by the parser are translated into byte
+// offsets, so that modifications can be spliced into the original bytes
+// without touching anything else in the document.
+type source struct {
+	bytes []byte
+	lines []int
+}
+
+func newSource(b []byte) *source {
+	s := &source{
+		bytes: b,
+		lines: []int{0},
+	}
+
+	for i, c := range b {
+		if c == '\n' {
+			s.lines = append(s.lines, i+1)
+		}
+	}
+
+	return s
+}
+
+// column returns the zero based rune column of the given offset.
+func (s *source) column(o int) int {
+	return utf8.RuneCount(s.bytes[s.lineStart(o):o])
+}
+
+// content returns the offset at which the actual content of the given node
+// begins, skipping node properties like anchors and tags.
+func (s *source) content(n *yaml.Node) int {
+	o := s.start(n)
+
+	for o < len(s.bytes) && (s.bytes[o] == '&' || s.bytes[o] == '!') {
+		for o < len(s.bytes) && !isWhitespace(s.bytes[o]) {
+			o++
+		}
+
+		if n.Kind == yaml.ScalarNode {
+			o = s.skipSpace(o)
+		} else {
+			o = s.skipWhitespace(o)
+		}
+	}
+
+	return o
+}
+
+// end returns the offset right after the last byte of the given node.
+func (s *source) end(n *yaml.Node) int {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return s.start(n)
+		}
+		return s.end(n.Content[len(n.Content)-1])
+	case yaml.AliasNode:
+		return s.content(n) + 1 + len(n.Value)
+	case yaml.ScalarNode:
+		return s.scalarEnd(n)
+	}
+
+	if n.Style&yaml.FlowStyle != 0 {
+		return s.flowEnd(s.content(n))
+	}
+	if len(n.Content) == 0 {
+		return s.content(n)
+	}
+
+	return s.end(n.Content[len(n.Content)-1])
+}
+
+// indent returns the column at which the content of the line containing the
+// given offset begins, ignoring any sequence indicators.
+func (s *source) indent(o int) int {
+	i := s.lineStart(o)
+	for {
+		i = s.skipSpace(i)
+		if i+1 < len(s.bytes) && s.bytes[i] == '-' && isWhitespace(s.bytes[i+1]) {
+			i++
+			continue
+		}
+		break
+	}
+
+	return s.column(i)
+}
+
+// indicator returns the offset of the sequence indicator preceding the
+// sequence item starting at the given offset.
+func (s *source) indicator(o int) int {
+	i := o
+	for i > 0 && isWhitespace(s.bytes[i-1]) {
+		i--
+	}
+	if i > 0 && s.bytes[i-1] == '-' {
+		return i - 1
+	}
+
+	return o
+}
+
+func (s *source) lineEnd(o int) int {
+	i := bytes.IndexByte(s.bytes[o:], '\n')
+	if i == -1 {
+		return len(s.bytes)
+	}
+
+	return o + i
+}
+
+func (s *source) lineStart(o int) int {
+	return bytes.LastIndexByte(s.bytes[:o], '\n') + 1
+}
+
+// ownLine returns whether the given offset is only preceded by whitespace on
+// its line.
+func (s *source) ownLine(o int) bool {
+	return len(bytes.TrimLeft(s.bytes[s.lineStart(o):o], " \t")) == 0
+}
+
+// previous returns the offset right after the last code byte found on any
+// line before the line of the given offset. Blank lines and comments are
+// skipped.
+func (s *source) previous(o int) int {
+	l := s.lineStart(o)
+	for l > 0 {
+		e := l - 1
+		l = s.lineStart(e)
+
+		c := s.codeEnd(l, e)
+		if c > s.skipSpace(l) {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// previousByte returns the last code byte before the given offset.
+func (s *source) previousByte(o int) byte {
+	if !s.ownLine(o) {
+		b := bytes.TrimRight(s.bytes[s.lineStart(o):o], " \t")
+		return b[len(b)-1]
+	}
+
+	p := s.previous(o)
+	if p == 0 {
+		return 0
+	}
+
+	return s.bytes[p-1]
+}
+
+func (s *source) start(n *yaml.Node) int {
+	if n.Line < 1 {
+		return 0
+	}
+	if n.Line > len(s.lines) {
+		return len(s.bytes)
+	}
+
+	o := s.lines[n.Line-1]
+	for c := 1; c < n.Column && o < len(s.bytes) && s.bytes[o] != '\n'; c++ {
+		_, w := utf8.DecodeRune(s.bytes[o:])
+		o += w
+	}
+
+	return o
+}
+
+// blockEnd returns the end of the literal or folded block scalar whose header
+// begins at the given offset.
+func (s *source) blockEnd(o int) int {
+	e := s.lineEnd(o)
+
+	// The content of a block scalar must be indented further than its parent
+	// node. That is the key of a mapping, or the indicator of a sequence item.
+	var p int
+	{
+		i := s.skipSpace(s.lineStart(o))
+		p = s.column(i)
+		for i+1 < o && s.bytes[i] == '-' && isWhitespace(s.bytes[i+1]) {
+			i = s.skipSpace(i + 1)
+			if i < o {
+				p = s.column(i)
+			}
+		}
+	}
+
+	indent := -1
+	for l := e + 1; e < len(s.bytes); l = e + 1 {
+		f := s.lineEnd(l)
+		t := s.skipSpace(l)
+
+		// Blank lines may be part of the block scalar. We only know once we
+		// see the next line with content.
+		if t == f {
+			if f == len(s.bytes) {
+				break
+			}
+			for t == f && f < len(s.bytes) {
+				l = f + 1
+				f = s.lineEnd(l)
+				t = s.skipSpace(l)
+			}
+			if t == f {
+				break
+			}
+		}
+
+		c := s.column(t)
+		if indent == -1 {
+			if c <= p {
+				break
+			}
+			indent = c
+		}
+		if c < indent {
+			break
+		}
+
+		e = f
+	}
+
+	return e
+}
+
+// blockIndent returns the column at which the content lines of the block
+// scalar starting at the given offset are indented, or -1 if the block scalar
+// has no content.
+func (s *source) blockIndent(o int) int {
+	e := s.blockEnd(o)
+
+	for l := s.lineEnd(o) + 1; l < e; l = s.lineEnd(l) + 1 {
+		t := s.skipSpace(l)
+		if t != s.lineEnd(l) {
+			return s.column(t)
+		}
+	}
+
+	return -1
+}
+
+// codeEnd returns the offset right after the last code byte within the given
+// line boundaries, ignoring trailing whitespace and comments.
+func (s *source) codeEnd(l int, e int) int {
+	c := l
+	for i := l; i < e; i++ {
+		switch s.bytes[i] {
+		case '"', '\'':
+			if i == l || isWhitespace(s.bytes[i-1]) || isIndicator(s.bytes[i-1]) {
+				i = s.quotedEnd(i) - 1
+			}
+		case '#':
+			if i == l || isWhitespace(s.bytes[i-1]) {
+				return c
+			}
+		}
+
+		if !isWhitespace(s.bytes[i]) {
+			c = i + 1
+		}
+	}
+
+	return c
+}
+
+// flowEnd returns the end of the flow collection starting at the given offset.
+func (s *source) flowEnd(o int) int {
+	var depth int
+	for i := o; i < len(s.bytes); i++ {
+		switch s.bytes[i] {
+		case '"', '\'':
+			if isWhitespace(s.bytes[i-1]) || isIndicator(s.bytes[i-1]) {
+				i = s.quotedEnd(i) - 1
+			}
+		case '#':
+			if isWhitespace(s.bytes[i-1]) {
+				i = s.lineEnd(i)
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return len(s.bytes)
+}
+
+// plainEnd returns the end of the plain scalar starting at the given offset by
+// matching its parsed value against the source. Line folding of multi line
+// plain scalars is accounted for by treating any whitespace as equal.
+func (s *source) plainEnd(o int, v string) int {
+	i := o
+	j := 0
+	for j < len(v) && i < len(s.bytes) {
+		if s.bytes[i] == v[j] {
+			i++
+			j++
+			continue
+		}
+
+		if !isWhitespace(s.bytes[i]) {
+			break
+		}
+
+		for i < len(s.bytes) && isWhitespace(s.bytes[i]) {
+			i++
+		}
+		for j < len(v) && isWhitespace(v[j]) {
+			j++
+		}
+	}
+
+	return i
+}
+
+// quotedEnd returns the end of the single or double quoted scalar starting at
+// the given offset.
+func (s *source) quotedEnd(o int) int {
+	q := s.bytes[o]
+	for i := o + 1; i < len(s.bytes); i++ {
+		switch {
+		case q == '"' && s.bytes[i] == '\\':
+			i++
+		case q == '\'' && s.bytes[i] == '\'' && i+1 < len(s.bytes) && s.bytes[i+1] == '\'':
+			i++
+		case s.bytes[i] == q:
+			return i + 1
+		}
+	}
+
+	return len(s.bytes)
+}
+
+func (s *source) scalarEnd(n *yaml.Node) int {
+	o := s.content(n)
+
+	switch {
+	case n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0:
+		return s.quotedEnd(o)
+	case n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		e := s.blockEnd(o)
+
+		// Trailing blank lines belong to the content of block scalars using
+		// the keep chomping indicator, except for the last line break.
+		if h := s.bytes[o:s.lineEnd(o)]; bytes.ContainsRune(bytes.Fields(h)[0], '+') {
+			for i := e; i < len(s.bytes) && isWhitespace(s.bytes[i]); i++ {
+				if s.bytes[i] == '\n' {
+					e = i
+				}
+			}
+		}
+
+		return e
+	}
+
+	return s.plainEnd(o, n.Value)
+}
+
+func (s *source) skipSpace(o int) int {
+	for o < len(s.bytes) && (s.bytes[o] == ' ' || s.bytes[o] == '\t') {
+		o++
+	}
+
+	return o
+}
+
+func (s *source) skipWhitespace(o int) int {
+	for o < len(s.bytes) {
+		switch {
+		case isWhitespace(s.bytes[o]):
+			o++
+		case s.bytes[o] == '#':
+			o = s.lineEnd(o)
+		default:
+			return o
+		}
+	}
+
+	return o
+}
+
+func isIndicator(b byte) bool {
+	return b == ':' || b == ',' || b == '[' || b == '{' || b == '-' || b == '?'
+}
+
+func isWhitespace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
@@ -0,0 +1,116 @@
+package path
+
+import (
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/xh3b4sd/dsm/pkg/template"
+)
+
+// actionsEnd returns the offset keys added to the given block mapping are
+// written at, which is the end of the value of its last key. Lines following
+// the value which consist of masked template actions indented deeper than the
+// keys, like {{- include "labels" . | nindent 4 }}, render the value of the
+// last key and are skipped. Control blocks containing the last key, like
+// {{- if .Values.env }}, are skipped up to their {{- end }}, so that added
+// keys are not rendered conditionally.
+func (p *Path) actionsEnd(m *yaml.Node) (int, error) {
+	s := p.source
+
+	c := m.Content[0].Column - 1
+	e := s.lineEnd(s.end(m.Content[len(m.Content)-1]))
+	if p.template == nil {
+		return e, nil
+	}
+
+	for e < len(s.bytes) {
+		n := s.lineEnd(e + 1)
+		l := s.bytes[e+1 : n]
+		if !template.IsComment(l) || s.column(s.skipSpace(e+1)) <= c {
+			break
+		}
+
+		e = n
+	}
+
+	// The actions on their own lines right before the first key belong to
+	// the mapping, e.g. in case the mapping starts within a control block.
+	o := s.lineStart(s.start(m.Content[0]))
+	for o > 0 {
+		l := s.lineStart(o - 1)
+		if !template.IsComment(s.bytes[l:o-1]) || s.column(s.skipSpace(l)) < c {
+			break
+		}
+
+		o = l
+	}
+
+	var blocks int
+	for o < e {
+		n := s.lineEnd(o)
+		if l := s.bytes[o:n]; template.IsComment(l) {
+			blocks += template.Blocks(p.unmask(string(l)))
+			if blocks < 0 {
+				blocks = 0
+			}
+		}
+
+		o = n + 1
+	}
+
+	for blocks > 0 && e < len(s.bytes) {
+		n := s.lineEnd(e + 1)
+		l := s.bytes[e+1 : n]
+		if !template.IsComment(l) {
+			break
+		}
+
+		blocks += template.Blocks(p.unmask(string(l)))
+		e = n
+	}
+
+	if blocks > 0 {
+		return 0, tracer.Maskf(invalidFormatError, "keys must not be added within the template block containing key '%s'", p.unmask(m.Content[len(m.Content)-2].Value))
+	}
+
+	return e, nil
+}
+
+// unmask returns the given string with the placeholders of template actions
+// replaced by the actions they stand for, if Config.Template is set.
+func (p *Path) unmask(s string) string {
+	if p.template == nil {
+		return s
+	}
+
+	return p.template.Unmask(s)
+}
+
+// unmaskValue unmasks all strings of the given value, including the keys of
+// objects.
+func (p *Path) unmaskValue(v interface{}) interface{} {
+	if p.template == nil {
+		return v
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, x := range v {
+			m[p.unmask(k)] = p.unmaskValue(x)
+		}
+
+		return m
+	case []interface{}:
+		l := []interface{}{}
+		for _, x := range v {
+			l = append(l, p.unmaskValue(x))
+		}
+
+		return l
+	case string:
+		return p.unmask(v)
+	}
+
+	return v
+}
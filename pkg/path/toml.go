@@ -0,0 +1,1142 @@
+package path
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// tomlDateTimeTag is the tag of TOML dates and times, which are written
+	// back as they were given, without quotes.
+	tomlDateTimeTag = "!toml/datetime"
+)
+
+var (
+	tomlBareKeyExpression  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	tomlDateTimeExpression = regexp.MustCompile(`^(?:[0-9]{4}-[0-9]{2}-[0-9]{2}(?:[Tt ][0-9]{2}:[0-9]{2}:[0-9]{2}(?:\.[0-9]+)?(?:[Zz]|[+-][0-9]{2}:[0-9]{2})?)?|[0-9]{2}:[0-9]{2}:[0-9]{2}(?:\.[0-9]+)?)$`)
+	tomlFloatExpression    = regexp.MustCompile(`^[+-]?(?:(?:0|[1-9](?:_?[0-9])*)(?:\.[0-9](?:_?[0-9])*)?(?:[eE][+-]?[0-9](?:_?[0-9])*)?|inf|nan)$`)
+	tomlIntegerExpression  = regexp.MustCompile(`^(?:[+-]?(?:0|[1-9](?:_?[0-9])*)|0x[0-9A-Fa-f](?:_?[0-9A-Fa-f])*|0o[0-7](?:_?[0-7])*|0b[01](?:_?[01])*)$`)
+)
+
+// toml describes where the values of a TOML document are written, so that
+// modifications can be spliced into the original bytes, keeping comments and
+// the layout of tables. Modifications which cannot be spliced cause the whole
+// document to be encoded again.
+type toml struct {
+	// dirty is set if the document has to be encoded again as a whole.
+	dirty bool
+	// lines are the byte ranges of the key value pairs defining the given
+	// values, from the start of the line of the key to the line break
+	// following the value.
+	lines map[*yaml.Node]span
+	// tables are the tables defined by table headers, and the root table.
+	tables map[*yaml.Node]*tomlTable
+	// values are the byte ranges of the values written inline, which are all
+	// values but tables and arrays of tables.
+	values map[*yaml.Node]span
+}
+
+// tomlTable describes a table defined by a table header, or the root table.
+type tomlTable struct {
+	// end is the offset new key value pairs are written at, which is right
+	// after the last key value pair of the table.
+	end int
+	// indent is the indentation of the key value pairs of the table.
+	indent string
+	// keys are the keys of the table header, e.g. [server tls] for the header
+	// [server.tls].
+	keys []string
+}
+
+// span is a range of bytes within a document.
+type span struct {
+	start int
+	end   int
+}
+
+// tomlParser reads TOML documents as specified in TOML v1.0.0.
+type tomlParser struct {
+	b []byte
+	o int
+
+	// defined are the tables defined explicitly by table headers, which must
+	// not be defined twice.
+	defined map[*yaml.Node]bool
+	// inline are the arrays and tables written inline, which must not be
+	// extended by table headers or dotted keys.
+	inline map[*yaml.Node]bool
+	root   *yaml.Node
+	toml   *toml
+}
+
+// parseTOML returns the given TOML document as YAML node tree, together with
+// the description of where its values are written.
+func parseTOML(b []byte) (*yaml.Node, *toml, error) {
+	r := &tomlParser{
+		b:    b,
+		root: &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"},
+
+		defined: map[*yaml.Node]bool{},
+		inline:  map[*yaml.Node]bool{},
+		toml: &toml{
+			lines:  map[*yaml.Node]span{},
+			tables: map[*yaml.Node]*tomlTable{},
+			values: map[*yaml.Node]span{},
+		},
+	}
+
+	r.toml.tables[r.root] = &tomlTable{end: -1}
+
+	current := r.root
+	for {
+		l := r.o
+		r.space()
+		i := r.o
+
+		if r.o >= len(r.b) {
+			break
+		}
+
+		var v *yaml.Node
+		switch r.b[r.o] {
+		case '#', '\n', '\r':
+		case '[':
+			if r.toml.tables[r.root].end == -1 {
+				r.toml.tables[r.root].end = l
+			}
+
+			t, err := r.table()
+			if err != nil {
+				return nil, nil, tracer.Mask(err)
+			}
+
+			current = t
+		default:
+			var err error
+			v, err = r.keyValue(current)
+			if err != nil {
+				return nil, nil, tracer.Mask(err)
+			}
+		}
+
+		err := r.end()
+		if err != nil {
+			return nil, nil, tracer.Mask(err)
+		}
+
+		if v != nil {
+			r.toml.lines[v] = span{start: l, end: r.o}
+			if t := r.toml.tables[current]; t != nil {
+				t.end = r.o
+				t.indent = string(r.b[l:i])
+			}
+		}
+	}
+
+	if r.toml.tables[r.root].end == -1 {
+		r.toml.tables[r.root].end = len(b)
+	}
+
+	n := &yaml.Node{
+		Kind:    yaml.DocumentNode,
+		Content: []*yaml.Node{r.root},
+	}
+
+	return n, r.toml, nil
+}
+
+func (r *tomlParser) array() (*yaml.Node, error) {
+	n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+	r.inline[n] = true
+
+	r.o++
+	for {
+		r.whitespace()
+		if r.peek("]") {
+			r.o++
+			return n, nil
+		}
+
+		v, err := r.value()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+		n.Content = append(n.Content, v)
+
+		r.whitespace()
+		switch {
+		case r.peek(","):
+			r.o++
+		case r.peek("]"):
+		default:
+			return nil, r.fail("expected , or ] within array")
+		}
+	}
+}
+
+func (r *tomlParser) basicString() (string, error) {
+	r.o++
+
+	var b strings.Builder
+	for {
+		if r.o >= len(r.b) || r.b[r.o] == '\n' {
+			return "", r.fail("expected the end of the string")
+		}
+
+		c := r.b[r.o]
+		switch c {
+		case '"':
+			r.o++
+			return b.String(), nil
+		case '\\':
+			err := r.escape(&b)
+			if err != nil {
+				return "", tracer.Mask(err)
+			}
+		default:
+			b.WriteByte(c)
+			r.o++
+		}
+	}
+}
+
+// comment skips the comment at the current offset, if any.
+func (r *tomlParser) comment() {
+	if r.o < len(r.b) && r.b[r.o] == '#' {
+		for r.o < len(r.b) && r.b[r.o] != '\n' {
+			r.o++
+		}
+	}
+}
+
+// end skips the remainder of the current line, which must not contain
+// anything but white space and a comment.
+func (r *tomlParser) end() error {
+	r.space()
+	r.comment()
+
+	if r.o >= len(r.b) {
+		return nil
+	}
+
+	if r.b[r.o] == '\r' && r.o+1 < len(r.b) && r.b[r.o+1] == '\n' {
+		r.o++
+	}
+	if r.b[r.o] != '\n' {
+		return r.fail("expected the end of the line")
+	}
+
+	r.o++
+
+	return nil
+}
+
+// escape reads the escape sequence at the current offset into the given
+// builder.
+func (r *tomlParser) escape(b *strings.Builder) error {
+	if r.o+1 >= len(r.b) {
+		return r.fail("expected an escape sequence")
+	}
+
+	c := r.b[r.o+1]
+	r.o += 2
+
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case 'e':
+		b.WriteByte('\x1b')
+	case '"':
+		b.WriteByte('"')
+	case '\\':
+		b.WriteByte('\\')
+	case 'u', 'U':
+		l := 4
+		if c == 'U' {
+			l = 8
+		}
+		if r.o+l > len(r.b) {
+			return r.fail("expected a unicode escape sequence")
+		}
+
+		i, err := strconv.ParseUint(string(r.b[r.o:r.o+l]), 16, 32)
+		if err != nil || !utf8.ValidRune(rune(i)) {
+			return r.fail("expected a unicode escape sequence")
+		}
+
+		b.WriteRune(rune(i))
+		r.o += l
+	default:
+		return r.fail(fmt.Sprintf("invalid escape sequence \\%c", c))
+	}
+
+	return nil
+}
+
+func (r *tomlParser) fail(reason string) error {
+	l := bytes.Count(r.b[:r.o], []byte("\n")) + 1
+	return tracer.Maskf(invalidFormatError, "toml: %s at line %d", reason, l)
+}
+
+func (r *tomlParser) inlineTable() (*yaml.Node, error) {
+	n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: yaml.FlowStyle}
+	r.inline[n] = true
+
+	r.o++
+	r.space()
+	if r.peek("}") {
+		r.o++
+		return n, nil
+	}
+
+	for {
+		r.space()
+
+		_, err := r.keyValue(n)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		r.space()
+		switch {
+		case r.peek(","):
+			r.o++
+		case r.peek("}"):
+			r.o++
+			return n, nil
+		default:
+			return nil, r.fail("expected , or } within inline table")
+		}
+	}
+}
+
+// key returns the parts of the dotted key at the current offset.
+func (r *tomlParser) key() ([]string, error) {
+	var l []string
+	for {
+		r.space()
+
+		var k string
+		switch {
+		case r.peek("\""):
+			s, err := r.basicString()
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+			k = s
+		case r.peek("'"):
+			s, err := r.literalString()
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+			k = s
+		default:
+			s := r.o
+			for r.o < len(r.b) && tomlBareKeyExpression.Match(r.b[r.o:r.o+1]) {
+				r.o++
+			}
+			if s == r.o {
+				return nil, r.fail("expected a key")
+			}
+			k = string(r.b[s:r.o])
+		}
+
+		l = append(l, k)
+
+		r.space()
+		if !r.peek(".") {
+			return l, nil
+		}
+		r.o++
+	}
+}
+
+// keyValue reads the key value pair at the current offset into the given
+// table and returns its value.
+func (r *tomlParser) keyValue(t *yaml.Node) (*yaml.Node, error) {
+	keys, err := r.key()
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	if !r.peek("=") {
+		return nil, r.fail("expected = after a key")
+	}
+	r.o++
+	r.space()
+
+	v, err := r.value()
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	for _, k := range keys[:len(keys)-1] {
+		i := index(t, k)
+		if i == -1 {
+			c := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: t.Style & yaml.FlowStyle}
+			t.Content = append(t.Content, tomlKeyNode(k), c)
+			t = c
+			continue
+		}
+
+		c := t.Content[i+1]
+		if c.Kind != yaml.MappingNode || (r.inline[c] && t.Style&yaml.FlowStyle == 0) || r.defined[c] {
+			return nil, r.fail(fmt.Sprintf("key '%s' is defined already", k))
+		}
+		t = c
+	}
+
+	k := keys[len(keys)-1]
+	if index(t, k) != -1 {
+		return nil, r.fail(fmt.Sprintf("key '%s' is defined already", k))
+	}
+
+	t.Content = append(t.Content, tomlKeyNode(k), v)
+
+	return v, nil
+}
+
+func (r *tomlParser) literalString() (string, error) {
+	r.o++
+
+	s := r.o
+	for r.o < len(r.b) && r.b[r.o] != '\'' {
+		if r.b[r.o] == '\n' {
+			return "", r.fail("expected the end of the string")
+		}
+		r.o++
+	}
+	if r.o >= len(r.b) {
+		return "", r.fail("expected the end of the string")
+	}
+
+	v := string(r.b[s:r.o])
+	r.o++
+
+	return v, nil
+}
+
+// multilineString reads the multi line basic or literal string at the current
+// offset, depending on the given quote.
+func (r *tomlParser) multilineString(q byte) (string, error) {
+	d := strings.Repeat(string(q), 3)
+
+	r.o += 3
+	if r.peek("\r\n") {
+		r.o += 2
+	} else if r.peek("\n") {
+		r.o++
+	}
+
+	var b strings.Builder
+	for {
+		if r.o >= len(r.b) {
+			return "", r.fail("expected the end of the string")
+		}
+
+		if r.peek(d) {
+			// Up to two quotes right before the closing delimiter belong to
+			// the string.
+			for i := 0; i < 2 && r.o+3 < len(r.b) && r.b[r.o+3] == q; i++ {
+				b.WriteByte(q)
+				r.o++
+			}
+
+			r.o += 3
+			return b.String(), nil
+		}
+
+		c := r.b[r.o]
+		if q == '"' && c == '\\' {
+			// A line ending backslash trims all white space up to the next
+			// non white space character.
+			e := r.o + 1
+			for e < len(r.b) && (r.b[e] == ' ' || r.b[e] == '\t') {
+				e++
+			}
+			if e < len(r.b) && (r.b[e] == '\n' || r.b[e] == '\r') {
+				r.o = e
+				r.whitespace()
+				continue
+			}
+
+			err := r.escape(&b)
+			if err != nil {
+				return "", tracer.Mask(err)
+			}
+			continue
+		}
+
+		b.WriteByte(c)
+		r.o++
+	}
+}
+
+func (r *tomlParser) peek(s string) bool {
+	return bytes.HasPrefix(r.b[r.o:], []byte(s))
+}
+
+// scalar reads the boolean, number or date at the current offset.
+func (r *tomlParser) scalar() (*yaml.Node, error) {
+	s := r.o
+	for r.o < len(r.b) && (tomlBareKeyExpression.Match(r.b[r.o:r.o+1]) || strings.IndexByte(":.+", r.b[r.o]) != -1) {
+		r.o++
+	}
+
+	// Dates and times may be separated by a space.
+	if r.o-s == 10 && r.o+2 < len(r.b) && r.b[r.o] == ' ' && r.b[r.o+1] >= '0' && r.b[r.o+1] <= '9' {
+		e := r.o + 1
+		for e < len(r.b) && strings.IndexByte("0123456789Zz:.+-", r.b[e]) != -1 {
+			e++
+		}
+		if tomlDateTimeExpression.Match(r.b[s:e]) {
+			r.o = e
+		}
+	}
+
+	v := string(r.b[s:r.o])
+	switch {
+	case v == "true" || v == "false":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: v}, nil
+	case tomlIntegerExpression.MatchString(v):
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strings.TrimPrefix(strings.ReplaceAll(v, "_", ""), "+")}, nil
+	case tomlFloatExpression.MatchString(v):
+		v = strings.TrimPrefix(strings.ReplaceAll(v, "_", ""), "+")
+		v = strings.Replace(strings.Replace(v, "inf", ".inf", 1), "nan", ".nan", 1)
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: v}, nil
+	case tomlDateTimeExpression.MatchString(v):
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tomlDateTimeTag, Value: v}, nil
+	}
+
+	r.o = s
+
+	return nil, r.fail("expected a value")
+}
+
+// space skips spaces and tabs.
+func (r *tomlParser) space() {
+	for r.o < len(r.b) && (r.b[r.o] == ' ' || r.b[r.o] == '\t') {
+		r.o++
+	}
+}
+
+// table reads the table header at the current offset and returns the table
+// it defines.
+func (r *tomlParser) table() (*yaml.Node, error) {
+	array := r.peek("[[")
+	if array {
+		r.o += 2
+	} else {
+		r.o++
+	}
+
+	keys, err := r.key()
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	if array && !r.peek("]]") || !array && !r.peek("]") {
+		return nil, r.fail("expected the end of the table header")
+	}
+	if array {
+		r.o += 2
+	} else {
+		r.o++
+	}
+
+	t := r.root
+	for _, k := range keys[:len(keys)-1] {
+		i := index(t, k)
+		if i == -1 {
+			c := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			t.Content = append(t.Content, tomlKeyNode(k), c)
+			t = c
+			continue
+		}
+
+		c := t.Content[i+1]
+		if c.Kind == yaml.SequenceNode && !r.inline[c] {
+			c = c.Content[len(c.Content)-1]
+		}
+		if c.Kind != yaml.MappingNode || r.inline[c] {
+			return nil, r.fail(fmt.Sprintf("key '%s' is defined already", k))
+		}
+		t = c
+	}
+
+	k := keys[len(keys)-1]
+	i := index(t, k)
+
+	var c *yaml.Node
+	switch {
+	case array && i == -1:
+		l := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		t.Content = append(t.Content, tomlKeyNode(k), l)
+		c = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		l.Content = append(l.Content, c)
+	case array && t.Content[i+1].Kind == yaml.SequenceNode && !r.inline[t.Content[i+1]]:
+		l := t.Content[i+1]
+		c = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		l.Content = append(l.Content, c)
+	case !array && i == -1:
+		c = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		t.Content = append(t.Content, tomlKeyNode(k), c)
+	case !array && t.Content[i+1].Kind == yaml.MappingNode && !r.inline[t.Content[i+1]] && !r.defined[t.Content[i+1]]:
+		c = t.Content[i+1]
+	default:
+		return nil, r.fail(fmt.Sprintf("table '%s' is defined already", strings.Join(keys, ".")))
+	}
+
+	r.defined[c] = true
+
+	// New key value pairs are written right after the header, unless the
+	// table defines key value pairs itself.
+	e := r.o
+	for e < len(r.b) && r.b[e] != '\n' {
+		e++
+	}
+	if e < len(r.b) {
+		e++
+	}
+
+	r.toml.tables[c] = &tomlTable{end: e, keys: keys}
+
+	return c, nil
+}
+
+// value reads the value at the current offset.
+func (r *tomlParser) value() (*yaml.Node, error) {
+	s := r.o
+
+	var n *yaml.Node
+	switch {
+	case r.peek("\"\"\""):
+		v, err := r.multilineString('"')
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+		n = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Style: yaml.DoubleQuotedStyle | yaml.LiteralStyle, Value: v}
+	case r.peek("'''"):
+		v, err := r.multilineString('\'')
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+		n = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Style: yaml.SingleQuotedStyle | yaml.LiteralStyle, Value: v}
+	case r.peek("\""):
+		v, err := r.basicString()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+		n = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Style: yaml.DoubleQuotedStyle, Value: v}
+	case r.peek("'"):
+		v, err := r.literalString()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+		n = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Style: yaml.SingleQuotedStyle, Value: v}
+	case r.peek("["):
+		var err error
+		n, err = r.array()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	case r.peek("{"):
+		var err error
+		n, err = r.inlineTable()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	default:
+		var err error
+		n, err = r.scalar()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+	}
+
+	r.toml.values[n] = span{start: s, end: r.o}
+
+	return n, nil
+}
+
+// whitespace skips white space, line breaks and comments.
+func (r *tomlParser) whitespace() {
+	for {
+		r.space()
+		r.comment()
+
+		if r.o < len(r.b) && (r.b[r.o] == '\n' || r.b[r.o] == '\r') {
+			r.o++
+			continue
+		}
+
+		return
+	}
+}
+
+func tomlKeyNode(k string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}
+}
+
+// addTOML writes the given value added to the given list of a TOML document at
+// the given index. Tables appended to arrays of tables are written as new
+// table headers after the last table of the array, including its sub tables.
+func (p *Path) addTOML(n *yaml.Node, i int, value *yaml.Node) error {
+	if _, ok := p.toml.values[n]; ok {
+		err := p.updateTOML(n)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	}
+
+	if i == 0 || i != len(n.Content)-1 || !isTOMLTable(value) {
+		p.toml.dirty = true
+		return nil
+	}
+
+	t, ok := p.toml.tables[n.Content[i-1]]
+	if !ok {
+		p.toml.dirty = true
+		return nil
+	}
+
+	var b strings.Builder
+	{
+		m := &yaml.Node{
+			Kind: yaml.MappingNode,
+			Content: []*yaml.Node{
+				tomlKeyNode(t.keys[len(t.keys)-1]),
+				{Kind: yaml.SequenceNode, Content: []*yaml.Node{value}},
+			},
+		}
+
+		err := writeTOMLTable(&b, m, t.keys[:len(t.keys)-1])
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	e := edit{
+		start: p.tomlEnd(n.Content[i-1]),
+		text:  b.String(),
+	}
+	e.end = e.start
+
+	if e.start == len(p.bytes) && e.start > 0 && p.bytes[e.start-1] != '\n' {
+		e.text = "\n" + e.text
+	}
+
+	p.addTOMLEdit(e)
+
+	return nil
+}
+
+// addTOMLEdit adds the given edit to the edits of a TOML document. Edits
+// replacing a value written inline replace the edits within the value, since
+// they write the value as modified so far. Text inserted at the same offset is
+// written in the order it was inserted. All other overlapping edits cause the
+// document to be encoded again as a whole.
+func (p *Path) addTOMLEdit(e edit) {
+	var l []edit
+	for _, x := range p.edits {
+		switch {
+		case e.start == e.end && x.start == x.end && e.start == x.start:
+			e.text = x.text + e.text
+			continue
+		case e.start != e.end && x.start >= e.start && x.end <= e.end:
+			continue
+		case e.start == x.start || (e.start < x.end && x.start < e.end):
+			p.toml.dirty = true
+		}
+
+		l = append(l, x)
+	}
+
+	p.edits = append(l, e)
+}
+
+// insertTOML writes the given key and value added to the given mapping of a
+// TOML document. Key value pairs are written after the last key value pair of
+// their table, and tables are written as new table headers right there.
+func (p *Path) insertTOML(m *yaml.Node, key *yaml.Node, value *yaml.Node) error {
+	if _, ok := p.toml.values[m]; ok {
+		err := p.updateTOML(m)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	}
+
+	t, ok := p.toml.tables[m]
+	if !ok {
+		p.toml.dirty = true
+		return nil
+	}
+
+	var b strings.Builder
+	err := writeTOMLTable(&b, &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{key, value}}, t.keys)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	e := edit{
+		start: t.end,
+		end:   t.end,
+		text:  b.String(),
+	}
+
+	// Tables added to the root table are written at the end of the document,
+	// like the tables written before. Key value pairs written right before a
+	// table header are separated from it by an empty line.
+	if isTOMLTable(value) || isTOMLTableArray(value) {
+		if len(t.keys) == 0 {
+			e.start = len(p.bytes)
+			e.end = len(p.bytes)
+		}
+	} else {
+		e.text = t.indent + e.text
+		if e.start < len(p.bytes) && p.bytes[e.start] == '[' {
+			e.text += "\n"
+		}
+	}
+	if e.start == len(p.bytes) && e.start > 0 && p.bytes[e.start-1] != '\n' {
+		e.text = "\n" + e.text
+	}
+
+	p.addTOMLEdit(e)
+
+	return nil
+}
+
+// removeTOML removes the given value removed from the given collection of a
+// TOML document. Key value pairs are removed together with their line.
+func (p *Path) removeTOML(n *yaml.Node, removed *yaml.Node) error {
+	if _, ok := p.toml.values[n]; ok {
+		err := p.updateTOML(n)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		return nil
+	}
+
+	l, ok := p.toml.lines[removed]
+	if !ok || n.Kind != yaml.MappingNode || (len(n.Content) == 0 && p.toml.tables[n] == nil) {
+		p.toml.dirty = true
+		return nil
+	}
+
+	p.addTOMLEdit(edit{start: l.start, end: l.end})
+
+	return nil
+}
+
+// replaceTOML writes the given value in place of the given node of a TOML
+// document. Strings keep the quotes of the strings they replace.
+func (p *Path) replaceTOML(n *yaml.Node, value *yaml.Node) error {
+	s, ok := p.toml.values[n]
+	if !ok {
+		p.toml.dirty = true
+		return nil
+	}
+
+	var t string
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!str" && value.Kind == yaml.ScalarNode && value.Tag == "!!str" {
+		t = tomlString(value.Value, n.Style)
+	} else {
+		var err error
+		t, err = tomlValue(value)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	p.addTOMLEdit(edit{start: s.start, end: s.end, text: t})
+
+	return nil
+}
+
+// updateTOML writes the given modified value of a TOML document again, which
+// must be written inline.
+func (p *Path) updateTOML(n *yaml.Node) error {
+	t, err := tomlValue(n)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	s := p.toml.values[n]
+	p.addTOMLEdit(edit{start: s.start, end: s.end, text: t})
+
+	return nil
+}
+
+// tomlEnd returns the offset right after the last key value pair of the given
+// table of a TOML document, or of any of its sub tables.
+func (p *Path) tomlEnd(n *yaml.Node) int {
+	var e int
+	if t, ok := p.toml.tables[n]; ok {
+		e = t.end
+	}
+
+	for _, c := range n.Content {
+		if x := p.tomlEnd(c); x > e {
+			e = x
+		}
+	}
+
+	return e
+}
+
+// encodeTOML returns the given document encoded as TOML. Mappings written in
+// block style become tables and lists of such mappings become arrays of
+// tables. All other values are written inline.
+func encodeTOML(n *yaml.Node) ([]byte, error) {
+	var b strings.Builder
+
+	err := writeTOMLTable(&b, n.Content[0], nil)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return []byte(strings.TrimLeft(b.String(), "\n")), nil
+}
+
+func isTOMLTable(n *yaml.Node) bool {
+	n = resolve(n)
+	return n.Kind == yaml.MappingNode && n.Style&yaml.FlowStyle == 0
+}
+
+func isTOMLTableArray(n *yaml.Node) bool {
+	n = resolve(n)
+	if n.Kind != yaml.SequenceNode || n.Style&yaml.FlowStyle != 0 || len(n.Content) == 0 {
+		return false
+	}
+
+	for _, c := range n.Content {
+		if !isTOMLTable(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// tomlKey returns the given key as TOML key, quoting it if necessary.
+func tomlKey(k string) string {
+	if tomlBareKeyExpression.MatchString(k) {
+		return k
+	}
+
+	return tomlString(k, 0)
+}
+
+// tomlString returns the given string as TOML string. Literal strings are
+// written if the given style is single quoted and the string can be written
+// that way. Multi line strings are written if the given style is literal and
+// the string contains line breaks.
+func tomlString(s string, style yaml.Style) string {
+	multiline := style&yaml.LiteralStyle != 0 && strings.Contains(s, "\n")
+
+	if style&yaml.SingleQuotedStyle != 0 && strings.IndexFunc(s, func(c rune) bool { return c != '\t' && c != '\n' && (c < 0x20 || c == 0x7f) }) == -1 {
+		switch {
+		case multiline && !strings.Contains(s, "'''") && !strings.HasSuffix(s, "'"):
+			return "'''\n" + s + "'''"
+		case !multiline && !strings.ContainsAny(s, "'\n"):
+			return "'" + s + "'"
+		}
+	}
+
+	var b strings.Builder
+	for _, c := range s {
+		switch {
+		case c == '"':
+			b.WriteString(`\"`)
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c == '\n' && multiline:
+			b.WriteRune(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteRune(c)
+		case c < 0x20 || c == 0x7f:
+			b.WriteString(fmt.Sprintf(`\u%04X`, c))
+		default:
+			b.WriteRune(c)
+		}
+	}
+
+	if multiline {
+		return `"""` + "\n" + b.String() + `"""`
+	}
+
+	return `"` + b.String() + `"`
+}
+
+// tomlValue returns the given node as TOML value written inline.
+func tomlValue(n *yaml.Node) (string, error) {
+	n = resolve(n)
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		var l []string
+		for _, c := range pairs(n) {
+			v, err := tomlValue(c[1])
+			if err != nil {
+				return "", tracer.Mask(err)
+			}
+
+			l = append(l, tomlKey(c[0].Value)+" = "+v)
+		}
+
+		if len(l) == 0 {
+			return "{}", nil
+		}
+
+		return "{ " + strings.Join(l, ", ") + " }", nil
+	case yaml.SequenceNode:
+		var l []string
+		for _, c := range n.Content {
+			v, err := tomlValue(c)
+			if err != nil {
+				return "", tracer.Mask(err)
+			}
+
+			l = append(l, v)
+		}
+
+		return "[" + strings.Join(l, ", ") + "]", nil
+	}
+
+	switch n.Tag {
+	case "!!bool":
+		return strings.ToLower(n.Value), nil
+	case "!!float":
+		switch strings.ToLower(n.Value) {
+		case ".inf", "+.inf":
+			return "inf", nil
+		case "-.inf":
+			return "-inf", nil
+		case ".nan":
+			return "nan", nil
+		}
+
+		if tomlFloatExpression.MatchString(n.Value) && strings.ContainsAny(n.Value, ".eE") {
+			return n.Value, nil
+		}
+
+		f, err := strconv.ParseFloat(n.Value, 64)
+		if err != nil {
+			return "", tracer.Maskf(invalidFormatError, "'%s' is not a TOML float", n.Value)
+		}
+
+		v := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(v, ".eEn") {
+			v += ".0"
+		}
+
+		return v, nil
+	case "!!int":
+		if tomlIntegerExpression.MatchString(n.Value) {
+			return n.Value, nil
+		}
+
+		var i int64
+		err := n.Decode(&i)
+		if err != nil {
+			return "", tracer.Maskf(invalidFormatError, "'%s' is not a TOML integer", n.Value)
+		}
+
+		return strconv.FormatInt(i, 10), nil
+	case "!!null":
+		return "", tracer.Maskf(invalidFormatError, "TOML documents cannot contain null values")
+	case tomlDateTimeTag, "!!timestamp":
+		if tomlDateTimeExpression.MatchString(n.Value) {
+			return n.Value, nil
+		}
+	}
+
+	return tomlString(n.Value, n.Style), nil
+}
+
+// writeTOMLTable writes the key value pairs of the given mapping, followed by
+// its sub tables, whose headers are prefixed with the given keys.
+func writeTOMLTable(b *strings.Builder, m *yaml.Node, keys []string) error {
+	var tables [][2]*yaml.Node
+	for _, c := range pairs(m) {
+		v := resolve(c[1])
+		if isTOMLTable(v) || isTOMLTableArray(v) {
+			tables = append(tables, [2]*yaml.Node{c[0], v})
+			continue
+		}
+
+		s, err := tomlValue(v)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		b.WriteString(tomlKey(c[0].Value) + " = " + s + "\n")
+	}
+
+	for _, c := range tables {
+		k := with(keys, c[0].Value)
+
+		var h []string
+		for _, x := range k {
+			h = append(h, tomlKey(x))
+		}
+
+		if isTOMLTableArray(c[1]) {
+			for _, i := range c[1].Content {
+				b.WriteString("\n[[" + strings.Join(h, ".") + "]]\n")
+
+				err := writeTOMLTable(b, resolve(i), k)
+				if err != nil {
+					return tracer.Mask(err)
+				}
+			}
+
+			continue
+		}
+
+		// Tables containing nothing but sub tables are defined implicitly by
+		// their sub tables.
+		var values bool
+		for _, x := range pairs(c[1]) {
+			if !isTOMLTable(x[1]) && !isTOMLTableArray(x[1]) {
+				values = true
+			}
+		}
+
+		if values || len(c[1].Content) == 0 {
+			b.WriteString("\n[" + strings.Join(h, ".") + "]\n")
+		}
+
+		err := writeTOMLTable(b, c[1], k)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	return nil
+}
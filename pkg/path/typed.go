@@ -0,0 +1,218 @@
+package path
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// TypeAuto converts values to the type of the values they replace, if
+	// possible, and keeps them strings otherwise.
+	TypeAuto  = "auto"
+	TypeBool  = "bool"
+	TypeFloat = "float"
+	TypeInt   = "int"
+	// TypeJSON parses values as JSON documents, which can be objects and
+	// lists as well.
+	TypeJSON = "json"
+	TypeNull = "null"
+	// TypeString writes values as strings, quoting them if necessary, which
+	// was the only behaviour of Set before values could be typed.
+	TypeString = "string"
+	// TypeYAML parses values as YAML documents, which can be objects and
+	// lists as well.
+	TypeYAML = "yaml"
+)
+
+// Types are all the types values can be converted to when using SetTyped.
+var Types = []string{
+	TypeAuto,
+	TypeBool,
+	TypeFloat,
+	TypeInt,
+	TypeJSON,
+	TypeNull,
+	TypeString,
+	TypeYAML,
+}
+
+// SetTyped sets the given value under the given path after converting it to
+// the given type. Numbers are written as given, so that e.g. 1.10 does not
+// become 1.1.
+func (p *Path) SetTyped(path string, value string, t string) error {
+	err := p.setTyped(path, value, t, nil)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+// SetExpanded sets the given value like SetTyped, after expanding it using the
+// given function. Values of the types json and yaml are parsed first and only
+// their strings are expanded, so that expanded text cannot change the
+// structure of the value, e.g. by closing a string and adding keys.
+func (p *Path) SetExpanded(path string, value string, t string, expand func(string) (string, error)) error {
+	if t != TypeJSON && t != TypeYAML {
+		v, err := expand(value)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		value = v
+		expand = nil
+	}
+
+	err := p.setTyped(path, value, t, expand)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+// setTyped sets the given value converted to the given type. The strings of
+// the converted value are expanded using the given function, if any.
+func (p *Path) setTyped(path string, value string, t string, expand func(string) (string, error)) error {
+	if !containsString(Types, t) {
+		return tracer.Maskf(invalidConfigError, "type must be one of %v", Types)
+	}
+
+	err := p.setWith(path, func(existing *yaml.Node) (*yaml.Node, error) {
+		if t != TypeAuto {
+			n, err := typed(value, t)
+			if err != nil {
+				return nil, tracer.Mask(err)
+			}
+
+			if expand != nil {
+				err = expandStrings(n, expand)
+				if err != nil {
+					return nil, tracer.Mask(err)
+				}
+			}
+
+			return n, nil
+		}
+
+		if existing != nil {
+			existing = resolve(existing)
+			if existing.Kind == yaml.ScalarNode {
+				var l []string
+				switch existing.Tag {
+				case "!!bool":
+					l = []string{TypeBool}
+				case "!!float":
+					l = []string{TypeFloat}
+				case "!!int":
+					l = []string{TypeInt, TypeFloat}
+				case "!!null":
+					l = []string{TypeNull}
+				case tomlDateTimeTag:
+					if tomlDateTimeExpression.MatchString(value) {
+						return &yaml.Node{Kind: yaml.ScalarNode, Tag: tomlDateTimeTag, Value: value}, nil
+					}
+				}
+
+				for _, x := range l {
+					n, err := typed(value, x)
+					if err == nil {
+						return n, nil
+					}
+				}
+			}
+		}
+
+		return typed(value, TypeString)
+	})
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	return nil
+}
+
+// expandStrings replaces the values of all strings of the given node, including
+// the keys of objects, using the given function.
+func expandStrings(n *yaml.Node, expand func(string) (string, error)) error {
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!str" {
+		v, err := expand(n.Value)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+
+		n.Value = v
+	}
+
+	for _, c := range n.Content {
+		err := expandStrings(c, expand)
+		if err != nil {
+			return tracer.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+// typed returns the node representing the given value converted to the given
+// type.
+func typed(value string, t string) (*yaml.Node, error) {
+	switch t {
+	case TypeBool, TypeFloat, TypeInt, TypeNull:
+		if t == TypeNull && value == "" {
+			value = "null"
+		}
+
+		var n yaml.Node
+		err := yaml.Unmarshal([]byte(value), &n)
+		if err != nil || len(n.Content) == 0 || n.Content[0].Kind != yaml.ScalarNode {
+			return nil, tracer.Maskf(invalidFormatError, "'%s' is not of type %s", value, t)
+		}
+
+		c := n.Content[0]
+		switch {
+		case t == TypeBool && c.Tag == "!!bool":
+		case t == TypeFloat && c.Tag == "!!float":
+		case t == TypeFloat && c.Tag == "!!int":
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return nil, tracer.Maskf(invalidFormatError, "'%s' is not of type %s", value, t)
+			}
+		case t == TypeInt && c.Tag == "!!int":
+		case t == TypeNull && c.Tag == "!!null":
+		default:
+			return nil, tracer.Maskf(invalidFormatError, "'%s' is not of type %s", value, t)
+		}
+
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: c.Tag, Value: c.Value}, nil
+	case TypeJSON, TypeYAML:
+		if t == TypeJSON && !json.Valid([]byte(value)) {
+			return nil, tracer.Maskf(invalidFormatError, "'%s' is not of type %s", value, t)
+		}
+
+		n, err := unmarshal([]byte(value))
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+		if len(n.Content) == 0 {
+			return nil, tracer.Maskf(invalidFormatError, "'%s' is not of type %s", value, t)
+		}
+
+		c := clone(n.Content[0])
+		if t == TypeJSON {
+			strip(c)
+		}
+
+		return c, nil
+	}
+
+	var n yaml.Node
+	err := n.Encode(value)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	return &n, nil
+}
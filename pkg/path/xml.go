@@ -0,0 +1,616 @@
+package path
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// xmlAttributePrefix is the prefix of the keys of XML attributes, e.g.
+	// @version for the attribute version.
+	xmlAttributePrefix = "@"
+	// xmlTextKey is the key of the text of XML elements which have attributes
+	// or child elements as well.
+	xmlTextKey = "#text"
+)
+
+// xmlDocument describes where the elements and attributes of an XML document
+// are written, so that modifications can be spliced into the original bytes,
+// keeping namespaces, comments and whitespace. Elements containing text only
+// are strings. All other elements are objects, having their attributes and
+// child elements as keys. Child elements occurring more than once are lists.
+type xmlDocument struct {
+	// attributes are the byte ranges of the attributes of the given values,
+	// including the whitespace in front of them.
+	attributes map[*yaml.Node]span
+	// elements are the elements the given values are read from.
+	elements map[*yaml.Node]*xmlElement
+	// lists are the lists of a single element, created for elements
+	// occurring once when addressing them as lists.
+	lists map[*yaml.Node]*yaml.Node
+	// unit is the indentation of child elements relative to their parents.
+	unit string
+	// values are the byte ranges of the values of attributes, and of the text
+	// of elements.
+	values map[*yaml.Node]span
+}
+
+// xmlElement describes an XML element within the original bytes.
+type xmlElement struct {
+	// end is the byte range of the end tag, which is empty for elements
+	// closed by their start tag, like <module/>.
+	end span
+	// indent is the indentation of the element, if it starts a line.
+	indent string
+	// line is whether the element starts a line.
+	line bool
+	name string
+	// start is the byte range of the start tag.
+	start span
+	// text is the byte range of the text of the element, without the
+	// whitespace around it.
+	text span
+}
+
+// xmlFrame is an element being read by parseXML.
+type xmlFrame struct {
+	attributes []*yaml.Node
+	children   []string
+	element    *xmlElement
+	named      map[string][]*yaml.Node
+	text       strings.Builder
+}
+
+// parseXML returns the given XML document as YAML node tree, together with the
+// description of where its elements and attributes are written.
+func parseXML(b []byte) (*yaml.Node, *xmlDocument, error) {
+	x := &xmlDocument{
+		attributes: map[*yaml.Node]span{},
+		elements:   map[*yaml.Node]*xmlElement{},
+		lists:      map[*yaml.Node]*yaml.Node{},
+		values:     map[*yaml.Node]span{},
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+
+	d := xml.NewDecoder(bytes.NewReader(b))
+
+	var stack []*xmlFrame
+	for {
+		o := int(d.InputOffset())
+
+		t, err := d.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, tracer.Maskf(invalidFormatError, "xml: %s", err.Error())
+		}
+
+		s := span{start: o, end: int(d.InputOffset())}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && len(root.Content) != 0 {
+				return nil, nil, tracer.Maskf(invalidFormatError, "xml: document must have a single root element")
+			}
+
+			e := &xmlElement{
+				name:  xmlName(t.Name),
+				start: s,
+			}
+
+			l := lineStart(b, s.start)
+			if len(bytes.Trim(b[l:s.start], " \t")) == 0 {
+				e.indent = string(b[l:s.start])
+				e.line = true
+			}
+
+			if x.unit == "" && e.line && len(stack) != 0 {
+				p := stack[len(stack)-1].element
+				if p.line && len(e.indent) > len(p.indent) && strings.HasPrefix(e.indent, p.indent) {
+					x.unit = e.indent[len(p.indent):]
+				}
+			}
+
+			f := &xmlFrame{
+				element: e,
+				named:   map[string][]*yaml.Node{},
+			}
+
+			spans := xmlAttributes(b[s.start:s.end])
+			for i, a := range t.Attr {
+				v := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: a.Value}
+				if i < len(spans) {
+					x.attributes[v] = span{start: s.start + spans[i][0].start, end: s.start + spans[i][0].end}
+					x.values[v] = span{start: s.start + spans[i][1].start, end: s.start + spans[i][1].end}
+				}
+
+				f.attributes = append(f.attributes, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: xmlAttributePrefix + xmlName(a.Name)}, v)
+			}
+
+			stack = append(stack, f)
+		case xml.EndElement:
+			if len(stack) == 0 || stack[len(stack)-1].element.name != xmlName(t.Name) {
+				return nil, nil, tracer.Maskf(invalidFormatError, "xml: unexpected end element </%s>", xmlName(t.Name))
+			}
+
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			f.element.end = s
+
+			n := x.node(f)
+			if len(stack) == 0 {
+				root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: f.element.name}, n)
+				continue
+			}
+
+			p := stack[len(stack)-1]
+			if len(p.named[f.element.name]) == 0 {
+				p.children = append(p.children, f.element.name)
+			}
+			p.named[f.element.name] = append(p.named[f.element.name], n)
+		case xml.CharData:
+			if len(stack) == 0 {
+				continue
+			}
+
+			f := stack[len(stack)-1]
+			f.text.Write(t)
+
+			r := b[s.start:s.end]
+			i := len(r) - len(bytes.TrimLeft(r, " \t\r\n"))
+			j := len(bytes.TrimRight(r, " \t\r\n"))
+			if i < j {
+				if f.element.text.end == 0 {
+					f.element.text.start = s.start + i
+				}
+				f.element.text.end = s.start + j
+			}
+		}
+	}
+
+	if len(stack) != 0 {
+		return nil, nil, tracer.Maskf(invalidFormatError, "xml: missing end element </%s>", stack[len(stack)-1].element.name)
+	}
+	if len(root.Content) == 0 {
+		return nil, nil, tracer.Maskf(invalidFormatError, "xml: document must have a root element")
+	}
+
+	if x.unit == "" {
+		x.unit = "  "
+	}
+
+	n := &yaml.Node{
+		Kind:    yaml.DocumentNode,
+		Content: []*yaml.Node{root},
+	}
+
+	return n, x, nil
+}
+
+// node returns the value of the given element read completely. Elements
+// having neither attributes nor child elements are strings.
+func (x *xmlDocument) node(f *xmlFrame) *yaml.Node {
+	e := f.element
+
+	text := strings.TrimSpace(f.text.String())
+	if e.text.end == 0 {
+		e.text = span{start: e.start.end, end: e.start.end}
+	}
+
+	if len(f.attributes) == 0 && len(f.children) == 0 {
+		n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: text}
+		x.elements[n] = e
+
+		return n
+	}
+
+	n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	n.Content = append(n.Content, f.attributes...)
+
+	if text != "" {
+		v := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: text}
+		x.values[v] = e.text
+		n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: xmlTextKey}, v)
+	}
+
+	for _, c := range f.children {
+		v := f.named[c][0]
+		if len(f.named[c]) > 1 {
+			v = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: f.named[c]}
+		}
+
+		n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: c}, v)
+	}
+
+	x.elements[n] = e
+
+	return n
+}
+
+// addXML writes the given value added to the given list of elements of an XML
+// document, which is at index i of the list already. The new element is
+// written next to the elements of the list.
+func (p *Path) addXML(n *yaml.Node, i int, value *yaml.Node) error {
+	var e *xmlElement
+	for _, c := range n.Content {
+		if x, ok := p.xml.elements[c]; ok {
+			e = x
+			break
+		}
+	}
+	if e == nil {
+		return tracer.Maskf(invalidFormatError, "XML lists must contain elements")
+	}
+
+	var t edit
+	if i+1 < len(n.Content) {
+		x := p.xml.elements[n.Content[i+1]]
+		t.start = x.start.start
+		t.text = p.renderXML(e.name, value, x.indent)
+		if x.line {
+			t.start = lineStart(p.bytes, x.start.start)
+			t.text = x.indent + t.text + "\n"
+		}
+	} else {
+		x := p.xml.elements[n.Content[i-1]]
+		t.start = xmlEnd(x)
+		t.text = p.renderXML(e.name, value, x.indent)
+		if x.line {
+			t.text = "\n" + x.indent + t.text
+		}
+	}
+	t.end = t.start
+
+	p.addXMLEdit(t)
+
+	return nil
+}
+
+// addXMLEdit adds the given edit to the edits of an XML document. Text
+// inserted at the same offset is written in the order it was inserted.
+func (p *Path) addXMLEdit(e edit) {
+	var l []edit
+	for _, x := range p.edits {
+		if e.start == e.end && x.start == x.end && e.start == x.start {
+			e.text = x.text + e.text
+			continue
+		}
+
+		l = append(l, x)
+	}
+
+	p.edits = append(l, e)
+}
+
+// insertXML writes the given key and value added to the given element of an
+// XML document. Attributes are written at the end of the start tag. Child
+// elements are written after the last child element.
+func (p *Path) insertXML(m *yaml.Node, key *yaml.Node, value *yaml.Node) error {
+	e, ok := p.xml.elements[m]
+	if !ok {
+		return tracer.Maskf(invalidFormatError, "XML documents must have a single root element")
+	}
+
+	if strings.HasPrefix(key.Value, xmlAttributePrefix) {
+		if value.Kind != yaml.ScalarNode {
+			return tracer.Maskf(invalidFormatError, "XML attributes must be strings")
+		}
+
+		o := e.start.end - 1
+		if e.end.start == e.end.end {
+			o--
+		}
+
+		p.addXMLEdit(edit{start: o, end: o, text: " " + strings.TrimPrefix(key.Value, xmlAttributePrefix) + `="` + escapeXML(xmlValue(value), true) + `"`})
+
+		return nil
+	}
+
+	// Text next to child elements, and child elements of elements closed by
+	// their start tag, are written by writing the whole element again.
+	if key.Value == xmlTextKey || e.end.start == e.end.end {
+		p.replaceXMLElement(e, m)
+		return nil
+	}
+
+	var last *xmlElement
+	for i := 1; i < len(m.Content)-2; i += 2 {
+		v := m.Content[i]
+		if v.Kind == yaml.SequenceNode && len(v.Content) != 0 {
+			v = v.Content[len(v.Content)-1]
+		}
+		if x, ok := p.xml.elements[v]; ok {
+			last = x
+		}
+	}
+
+	indent := e.indent + p.xml.unit
+	if last != nil && last.line {
+		indent = last.indent
+	}
+
+	var t edit
+	switch {
+	case last != nil && last.line:
+		t.start = xmlEnd(last)
+		t.text = "\n" + indent + p.renderXML(key.Value, value, indent)
+	case last != nil:
+		t.start = xmlEnd(last)
+		t.text = p.renderXML(key.Value, value, indent)
+	default:
+		t.start = e.end.start
+		t.text = "\n" + indent + p.renderXML(key.Value, value, indent) + "\n" + e.indent
+		if l := lineStart(p.bytes, e.end.start); len(bytes.Trim(p.bytes[l:e.end.start], " \t")) == 0 {
+			t.start = l
+			t.text = indent + p.renderXML(key.Value, value, indent) + "\n"
+		}
+	}
+	t.end = t.start
+
+	p.addXMLEdit(t)
+
+	return nil
+}
+
+// listed returns the given element as list of one element, if the given key
+// addresses list items, since elements occurring once are not read as lists.
+// The list is the same for every call.
+func (p *Path) listed(n *yaml.Node, key string) *yaml.Node {
+	if p.xml == nil || n.Kind != yaml.MappingNode || p.xml.elements[n] == nil {
+		return n
+	}
+
+	_, predicate := predicateFromKey(key)
+	_, position := positionFromKey(key, 1)
+	if !predicate && !position && !indexExpression.MatchString(key) {
+		return n
+	}
+
+	l, ok := p.xml.lists[n]
+	if !ok {
+		l = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{n}}
+		p.xml.lists[n] = l
+	}
+
+	return l
+}
+
+// removeXML removes the given value removed from the given element or list of
+// an XML document. Elements are removed together with their line.
+func (p *Path) removeXML(n *yaml.Node, removed *yaml.Node) error {
+	if s, ok := p.xml.attributes[removed]; ok {
+		p.addXMLEdit(edit{start: s.start, end: s.end})
+		return nil
+	}
+
+	l := []*yaml.Node{removed}
+	if removed.Kind == yaml.SequenceNode {
+		l = removed.Content
+	}
+
+	for _, r := range l {
+		e, ok := p.xml.elements[r]
+		if !ok {
+			if x, ok := p.xml.elements[n]; ok {
+				p.replaceXMLElement(x, n)
+				return nil
+			}
+
+			return tracer.Maskf(invalidFormatError, "XML documents must have a single root element")
+		}
+
+		t := edit{start: e.start.start, end: xmlEnd(e)}
+		if e.line {
+			t.start = lineStart(p.bytes, e.start.start)
+			if t.end < len(p.bytes) && p.bytes[t.end] == '\n' {
+				t.end++
+			}
+		}
+
+		p.addXMLEdit(t)
+	}
+
+	return nil
+}
+
+// renderXML returns the given value written as XML element of the given name,
+// with child elements indented relative to the given indentation.
+func (p *Path) renderXML(name string, n *yaml.Node, indent string) string {
+	n = resolve(n)
+
+	switch n.Kind {
+	case yaml.SequenceNode:
+		var l []string
+		for _, c := range n.Content {
+			l = append(l, p.renderXML(name, c, indent))
+		}
+
+		return strings.Join(l, "\n"+indent)
+	case yaml.MappingNode:
+		var b strings.Builder
+		b.WriteString("<" + name)
+
+		var text string
+		var children []string
+		for _, c := range pairs(n) {
+			k := c[0].Value
+
+			switch {
+			case strings.HasPrefix(k, xmlAttributePrefix):
+				b.WriteString(" " + strings.TrimPrefix(k, xmlAttributePrefix) + `="` + escapeXML(xmlValue(c[1]), true) + `"`)
+			case k == xmlTextKey:
+				text = escapeXML(xmlValue(c[1]), false)
+			default:
+				children = append(children, p.renderXML(k, c[1], indent+p.xml.unit))
+			}
+		}
+
+		switch {
+		case len(children) != 0:
+			b.WriteString(">" + text)
+			for _, c := range children {
+				b.WriteString("\n" + indent + p.xml.unit + c)
+			}
+			b.WriteString("\n" + indent + "</" + name + ">")
+		case text != "":
+			b.WriteString(">" + text + "</" + name + ">")
+		default:
+			b.WriteString("/>")
+		}
+
+		return b.String()
+	}
+
+	if n.Tag == "!!null" {
+		return "<" + name + "/>"
+	}
+
+	return "<" + name + ">" + escapeXML(n.Value, false) + "</" + name + ">"
+}
+
+// replaceXML writes the given value in place of the given value of an XML
+// document. Strings keep the elements and attributes they are written in.
+// Elements and lists of elements are written again as a whole otherwise.
+func (p *Path) replaceXML(n *yaml.Node, value *yaml.Node) error {
+	if s, ok := p.xml.values[n]; ok {
+		if value.Kind != yaml.ScalarNode {
+			return tracer.Maskf(invalidFormatError, "XML attributes must be strings")
+		}
+
+		_, attribute := p.xml.attributes[n]
+		p.addXMLEdit(edit{start: s.start, end: s.end, text: escapeXML(xmlValue(value), attribute)})
+
+		return nil
+	}
+
+	if e, ok := p.xml.elements[n]; ok {
+		if n.Kind == yaml.ScalarNode && value.Kind == yaml.ScalarNode && e.end.start != e.end.end {
+			p.addXMLEdit(edit{start: e.text.start, end: e.text.end, text: escapeXML(xmlValue(value), false)})
+		} else {
+			p.replaceXMLElement(e, value)
+		}
+
+		return nil
+	}
+
+	if n.Kind == yaml.SequenceNode && len(n.Content) != 0 {
+		first, ok := p.xml.elements[n.Content[0]]
+		last, ko := p.xml.elements[n.Content[len(n.Content)-1]]
+		if ok && ko {
+			t := edit{
+				start: first.start.start,
+				end:   xmlEnd(last),
+				text:  p.renderXML(first.name, value, first.indent),
+			}
+
+			p.addXMLEdit(t)
+
+			return nil
+		}
+	}
+
+	return tracer.Maskf(invalidFormatError, "XML documents must have a single root element")
+}
+
+// replaceXMLElement writes the given element again as a whole, using the
+// given value.
+func (p *Path) replaceXMLElement(e *xmlElement, value *yaml.Node) {
+	p.addXMLEdit(edit{start: e.start.start, end: xmlEnd(e), text: p.renderXML(e.name, value, e.indent)})
+}
+
+// escapeXML returns the given string with the characters escaped which cannot
+// be written literally in XML text, or in XML attributes.
+func escapeXML(s string, attribute bool) string {
+	r := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	if attribute {
+		r = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "\n", "&#10;")
+	}
+
+	return r.Replace(s)
+}
+
+// isXML returns whether the given bytes look like an XML document, which is
+// the case if they start with a tag.
+func isXML(b []byte) bool {
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+	b = bytes.TrimLeft(b, " \t\r\n")
+
+	return len(b) != 0 && b[0] == '<'
+}
+
+// lineStart returns the offset of the line containing the given offset.
+func lineStart(b []byte, o int) int {
+	return bytes.LastIndexByte(b[:o], '\n') + 1
+}
+
+// xmlAttributes returns the byte ranges of the attributes of the given start
+// tag, each with the whitespace in front of it, and the byte range of its
+// value within the quotes.
+func xmlAttributes(b []byte) [][2]span {
+	var l [][2]span
+
+	o := bytes.IndexAny(b, " \t\r\n/>")
+	for o != -1 && o < len(b) {
+		s := o
+		o = skip(b, o, " \t\r\n")
+		if o >= len(b) || b[o] == '/' || b[o] == '>' {
+			break
+		}
+
+		e := bytes.IndexByte(b[o:], '=')
+		if e == -1 {
+			break
+		}
+		o = skip(b, o+e+1, " \t\r\n")
+		if o >= len(b) {
+			break
+		}
+
+		q := b[o]
+		v := o + 1
+		e = bytes.IndexByte(b[v:], q)
+		if e == -1 {
+			break
+		}
+		o = v + e + 1
+
+		l = append(l, [2]span{{start: s, end: o}, {start: v, end: v + e}})
+	}
+
+	return l
+}
+
+// xmlEnd returns the offset following the given element.
+func xmlEnd(e *xmlElement) int {
+	if e.end.end > e.start.end {
+		return e.end.end
+	}
+
+	return e.start.end
+}
+
+// xmlName returns the given name with its namespace prefix, if any, e.g.
+// xsi:schemaLocation.
+func xmlName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+
+	return n.Space + ":" + n.Local
+}
+
+// xmlValue returns the given scalar as XML text. Null values are empty.
+func xmlValue(n *yaml.Node) string {
+	if n.Tag == "!!null" {
+		return ""
+	}
+
+	return n.Value
+}
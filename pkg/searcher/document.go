@@ -0,0 +1,12 @@
+package searcher
+
+// Document is a single YAML document found by the Searcher. Files may contain
+// multiple documents, so that Index describes the position of the document
+// within the stream of documents of its file. Format is the format of the
+// document as configured or as detected by the extension of its file, if any.
+type Document struct {
+	Bytes  []byte
+	File   string
+	Format string
+	Index  int
+}
@@ -13,3 +13,11 @@ var invalidConfigError = &tracer.Error{
 func IsInvalidConfig(err error) bool {
 	return errors.Is(err, invalidConfigError)
 }
+
+var invalidFormatError = &tracer.Error{
+	Kind: "invalidFormatError",
+}
+
+func IsInvalidFormat(err error) bool {
+	return errors.Is(err, invalidFormatError)
+}
@@ -1,30 +1,50 @@
 package searcher
 
 import (
-	"bytes"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/spf13/afero"
 	"github.com/xh3b4sd/tracer"
 
 	"github.com/xh3b4sd/dsm/pkg/path"
+	"github.com/xh3b4sd/dsm/pkg/stream"
+	"github.com/xh3b4sd/dsm/pkg/template"
 )
 
 type Config struct {
 	FileSystem afero.Fs
 
+	// Format is the format of all files, one of path.Formats, overriding the
+	// format detected by their extension, e.g. json5 for tsconfig.json files
+	// having comments.
+	Format string
+	// Glob selects all documents of the files matching the given pattern,
+	// e.g. *.env, so that documents without kind and metadata name, like
+	// dotenv files, can be selected. The pattern is matched against the file
+	// path relative to Source and against the file name. Name and Resource
+	// may be empty if Glob is given, and narrow the selection down otherwise.
+	Glob     string
 	Name     string
 	Resource string
 	Source   string
+	// Template causes Go template actions like {{ .Values.image }} to be
+	// treated as opaque tokens, so that e.g. Helm chart templates can be
+	// searched.
+	Template bool
 }
 
 type Searcher struct {
 	fileSystem afero.Fs
 
+	format   string
+	glob     string
 	name     string
 	resource string
 	source   string
+	template bool
 }
 
 func New(config Config) (*Searcher, error) {
@@ -32,10 +52,28 @@ func New(config Config) (*Searcher, error) {
 		return nil, tracer.Maskf(invalidConfigError, "%T.FileSystem must not be empty", config)
 	}
 
-	if config.Name == "" {
+	if config.Format != "" {
+		var ok bool
+		for _, f := range path.Formats {
+			if config.Format == f {
+				ok = true
+			}
+		}
+
+		if !ok {
+			return nil, tracer.Maskf(invalidConfigError, "%T.Format must be one of %s", config, strings.Join(path.Formats, ", "))
+		}
+	}
+	if config.Glob != "" {
+		_, err := filepath.Match(config.Glob, "")
+		if err != nil {
+			return nil, tracer.Maskf(invalidConfigError, "%T.Glob must be a valid pattern", config)
+		}
+	}
+	if config.Glob == "" && config.Name == "" {
 		return nil, tracer.Maskf(invalidConfigError, "%T.Name must not be empty", config)
 	}
-	if config.Resource == "" {
+	if config.Glob == "" && config.Resource == "" {
 		return nil, tracer.Maskf(invalidConfigError, "%T.Resource must not be empty", config)
 	}
 	if config.Source == "" {
@@ -45,35 +83,51 @@ func New(config Config) (*Searcher, error) {
 	s := &Searcher{
 		fileSystem: config.FileSystem,
 
+		format:   config.Format,
+		glob:     config.Glob,
 		name:     config.Name,
 		resource: config.Resource,
 		source:   config.Source,
+		template: config.Template,
 	}
 
 	return s, nil
 }
 
-func (s *Searcher) Search() (map[string][]byte, error) {
-	files, err := s.files(".yaml")
+func (s *Searcher) Search() ([]Document, error) {
+	files, err := s.files(".csproj", ".env", ".fsproj", ".hcl", ".json", ".json5", ".jsonc", ".properties", ".tfvars", ".toml", ".vbproj", ".xml", ".yaml")
 	if err != nil {
 		return nil, tracer.Mask(err)
 	}
 
-	filtered := map[string][]byte{}
-	for p, b := range files {
+	var filtered []Document
+	for _, d := range files {
+		if s.glob != "" && !s.matches(d.File) {
+			continue
+		}
+
 		var newPath *path.Path
 		{
 			c := path.Config{
-				Bytes: b,
+				Bytes:    d.Bytes,
+				Format:   d.Format,
+				Template: s.template,
 			}
 
+			// Documents which cannot be parsed are skipped while looking for
+			// resources. Files selected by glob are meant to be worked with,
+			// so that failing to parse them is an error.
 			newPath, err = path.New(c)
-			if err != nil {
+			if path.IsInvalidFormat(err) && s.glob == "" {
+				continue
+			} else if path.IsInvalidFormat(err) {
+				return nil, tracer.Maskf(invalidFormatError, "file %s must be valid: %s", d.File, err.Error())
+			} else if err != nil {
 				return nil, tracer.Mask(err)
 			}
 		}
 
-		{
+		if s.name != "" {
 			v, err := newPath.Get("metadata.name")
 			if path.IsNotFound(err) {
 				continue
@@ -86,9 +140,11 @@ func (s *Searcher) Search() (map[string][]byte, error) {
 			}
 		}
 
-		{
+		if s.resource != "" {
 			v, err := newPath.Get("kind")
-			if err != nil {
+			if path.IsNotFound(err) {
+				continue
+			} else if err != nil {
 				return nil, tracer.Mask(err)
 			}
 
@@ -97,14 +153,48 @@ func (s *Searcher) Search() (map[string][]byte, error) {
 			}
 		}
 
-		filtered[p] = b
+		filtered = append(filtered, d)
 	}
 
+	sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].File < filtered[j].File })
+
 	return filtered, nil
 }
 
-func (s *Searcher) files(exts ...string) (map[string][]byte, error) {
-	files := map[string][]byte{}
+// Split returns the documents of the given file like stream.Split does. Files
+// other than YAML files, like TOML files, consist of a single document.
+// Template actions are masked while splitting the stream if Config.Template
+// is set, since they would not be parsed as YAML otherwise.
+func (s *Searcher) Split(file string, b []byte) ([][]byte, error) {
+	if f := s.formatOf(file); f != "" && f != path.FormatYAML {
+		return [][]byte{b}, nil
+	}
+
+	if !s.template {
+		l, err := stream.Split(b)
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+
+		return l, nil
+	}
+
+	m, a := template.Mask(b)
+
+	l, err := stream.Split(m)
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	for i := range l {
+		l[i] = []byte(a.Unmask(string(l[i])))
+	}
+
+	return l, nil
+}
+
+func (s *Searcher) files(exts ...string) ([]Document, error) {
+	var files []Document
 	{
 		walkFunc := func(r string, i os.FileInfo, err error) error {
 			if err != nil {
@@ -126,13 +216,17 @@ func (s *Searcher) files(exts ...string) (map[string][]byte, error) {
 			}
 
 			// We do not want to track files with the wrong extension. We are
-			// interested in protocol buffer files having the ".proto"
-			// extension.
+			// interested in YAML, JSON, TOML, XML, HCL, dotenv and properties
+			// files. Dotenv files may be named e.g. .env.production as well.
+			var ok bool
 			for _, e := range exts {
-				if filepath.Ext(i.Name()) != e {
-					return nil
+				if filepath.Ext(i.Name()) == e || (e == ".env" && strings.HasPrefix(i.Name(), ".env.")) {
+					ok = true
 				}
 			}
+			if !ok {
+				return nil
+			}
 
 			p := filepath.Join(filepath.Dir(r), i.Name())
 
@@ -141,8 +235,24 @@ func (s *Searcher) files(exts ...string) (map[string][]byte, error) {
 				return tracer.Mask(err)
 			}
 
-			for _, s := range bytes.Split(b, []byte("---")) {
-				files[p] = s
+			// Template files which cannot be parsed even with their actions
+			// masked are skipped, like the documents which cannot be parsed.
+			l, err := s.Split(p, b)
+			if s.template && err != nil {
+				return nil
+			} else if err != nil {
+				return tracer.Mask(err)
+			}
+
+			for i, b := range l {
+				d := Document{
+					Bytes:  b,
+					File:   p,
+					Format: s.formatOf(p),
+					Index:  i,
+				}
+
+				files = append(files, d)
 			}
 
 			return nil
@@ -156,3 +266,57 @@ func (s *Searcher) files(exts ...string) (map[string][]byte, error) {
 
 	return files, nil
 }
+
+// formatOf returns the format of the given file, which is Config.Format if
+// given, or the format detected by the extension of the file otherwise.
+func (s *Searcher) formatOf(file string) string {
+	if s.format != "" {
+		return s.format
+	}
+
+	return format(file)
+}
+
+// matches returns whether the given file matches Config.Glob, either by its
+// path relative to Config.Source or by its name.
+func (s *Searcher) matches(file string) bool {
+	r, err := filepath.Rel(s.source, file)
+	if err != nil {
+		r = file
+	}
+
+	for _, f := range []string{r, filepath.Base(file)} {
+		ok, err := filepath.Match(s.glob, f)
+		if err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// format returns the format of the given file as detected by its extension, or
+// an empty string if the format is to be detected from the content of the
+// file.
+func format(file string) string {
+	n := filepath.Base(file)
+
+	switch {
+	case filepath.Ext(n) == ".env" || strings.HasPrefix(n, ".env."):
+		return path.FormatDotenv
+	case filepath.Ext(n) == ".hcl" || filepath.Ext(n) == ".tfvars":
+		return path.FormatHCL
+	case filepath.Ext(n) == ".json":
+		return path.FormatJSON
+	case filepath.Ext(n) == ".json5" || filepath.Ext(n) == ".jsonc":
+		return path.FormatJSON5
+	case filepath.Ext(n) == ".properties":
+		return path.FormatProperties
+	case filepath.Ext(n) == ".toml":
+		return path.FormatTOML
+	case filepath.Ext(n) == ".xml" || strings.HasSuffix(filepath.Ext(n), "proj"):
+		return path.FormatXML
+	}
+
+	return ""
+}
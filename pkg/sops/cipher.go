@@ -0,0 +1,255 @@
+package sops
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+const (
+	// nonceSize is the size of the IVs SOPS encrypts values with, which is
+	// larger than the standard nonce size of AES-GCM.
+	nonceSize = 32
+)
+
+var (
+	encryptedExpression = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.+),iv:(.+),tag:(.+),type:(.+)\]`)
+)
+
+// macOnlyEncryptedInitialization is written to the MAC of documents whose
+// MAC covers encrypted values only, so that it always differs from the MAC
+// covering all values.
+var macOnlyEncryptedInitialization = []byte{0x8a, 0x3f, 0xd2, 0xad, 0x54, 0xce, 0x66, 0x52, 0x7b, 0x10, 0x34, 0xf3, 0xd1, 0x47, 0xbe, 0xb, 0xb, 0x97, 0x5b, 0x3b, 0xf4, 0x4f, 0x72, 0xc6, 0xfd, 0xad, 0xec, 0x81, 0x76, 0xf2, 0x7d, 0x69}
+
+// MAC computes the message authentication code of a document from its
+// plaintext values, which have to be added in the order they appear in the
+// document.
+type MAC struct {
+	hash hash.Hash
+	only bool
+}
+
+func NewMAC(m Metadata) *MAC {
+	h := sha512.New()
+	if m.MACOnlyEncrypted {
+		h.Write(macOnlyEncryptedInitialization)
+	}
+
+	return &MAC{
+		hash: h,
+		only: m.MACOnlyEncrypted,
+	}
+}
+
+// Add adds the given plaintext value to the code. Values which are not
+// encrypted are skipped if the code covers encrypted values only.
+func (m *MAC) Add(value interface{}, encrypted bool) error {
+	if m.only && !encrypted {
+		return nil
+	}
+
+	b, err := plaintext(value)
+	if err != nil {
+		return tracer.Mask(err)
+	}
+
+	m.hash.Write(b)
+
+	return nil
+}
+
+// Sum returns the code as SOPS writes it before encrypting it.
+func (m *MAC) Sum() string {
+	return fmt.Sprintf("%X", m.hash.Sum(nil))
+}
+
+// Sign returns the lastmodified and mac values of the metadata of a document
+// modified at the given time, having the given code.
+func Sign(sum string, key []byte, t time.Time) (string, string, error) {
+	l := t.UTC().Format(time.RFC3339)
+
+	m, err := Encrypt(sum, key, l)
+	if err != nil {
+		return "", "", tracer.Mask(err)
+	}
+
+	return l, m, nil
+}
+
+// AdditionalData returns the data SOPS authenticates a value with besides the
+// value itself, which are the keys leading to the value.
+func AdditionalData(keys []string) string {
+	return strings.Join(keys, ":") + ":"
+}
+
+// Decrypt returns the plaintext of the given encrypted value as the type it
+// was encrypted as, e.g. int or bool. Empty strings are not encrypted.
+func Decrypt(value string, key []byte, data string) (interface{}, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	m := encryptedExpression.FindStringSubmatch(value)
+	if m == nil {
+		return nil, tracer.Maskf(decryptionFailedError, "value must be encrypted like ENC[AES256_GCM,data:...]")
+	}
+
+	var d, iv, tag []byte
+	{
+		var err error
+
+		d, err = base64.StdEncoding.DecodeString(m[1])
+		if err != nil {
+			return nil, tracer.Maskf(decryptionFailedError, "data must be base64 encoded")
+		}
+		iv, err = base64.StdEncoding.DecodeString(m[2])
+		if err != nil {
+			return nil, tracer.Maskf(decryptionFailedError, "iv must be base64 encoded")
+		}
+		tag, err = base64.StdEncoding.DecodeString(m[3])
+		if err != nil {
+			return nil, tracer.Maskf(decryptionFailedError, "tag must be base64 encoded")
+		}
+	}
+
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, tracer.Maskf(decryptionFailedError, "data key must be 32 bytes")
+	}
+
+	g, err := cipher.NewGCMWithNonceSize(c, len(iv))
+	if err != nil {
+		return nil, tracer.Mask(err)
+	}
+
+	b, err := g.Open(nil, iv, append(d, tag...), []byte(data))
+	if err != nil {
+		return nil, tracer.Maskf(decryptionFailedError, "value must be authentic")
+	}
+
+	var v interface{}
+	switch m[4] {
+	case "str":
+		v = string(b)
+	case "int":
+		v, err = strconv.Atoi(string(b))
+	case "float":
+		v, err = strconv.ParseFloat(string(b), 64)
+	case "bytes":
+		v = b
+	case "bool":
+		v, err = strconv.ParseBool(string(b))
+	case "time":
+		var t time.Time
+		err = t.UnmarshalText(b)
+		v = t
+	default:
+		return nil, tracer.Maskf(decryptionFailedError, "type %s is not supported", m[4])
+	}
+	if err != nil {
+		return nil, tracer.Maskf(decryptionFailedError, "value must be of type %s", m[4])
+	}
+
+	return v, nil
+}
+
+// Encrypt returns the given plaintext value encrypted with the given data key
+// the way SOPS does, using a random IV. Empty strings are not encrypted.
+func Encrypt(value interface{}, key []byte, data string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	var t string
+	switch value.(type) {
+	case string:
+		t = "str"
+	case []byte:
+		t = "bytes"
+	case int:
+		t = "int"
+	case float64:
+		t = "float"
+	case bool:
+		t = "bool"
+	case time.Time:
+		t = "time"
+	}
+
+	b, err := plaintext(value)
+	if err != nil {
+		return "", tracer.Mask(err)
+	}
+
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return "", tracer.Maskf(decryptionFailedError, "data key must be 32 bytes")
+	}
+
+	g, err := cipher.NewGCMWithNonceSize(c, nonceSize)
+	if err != nil {
+		return "", tracer.Mask(err)
+	}
+
+	iv := make([]byte, nonceSize)
+	_, err = rand.Read(iv)
+	if err != nil {
+		return "", tracer.Mask(err)
+	}
+
+	o := g.Seal(nil, iv, b, []byte(data))
+	d, tag := o[:len(o)-aes.BlockSize], o[len(o)-aes.BlockSize:]
+
+	e := fmt.Sprintf(
+		"ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(d),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(tag),
+		t,
+	)
+
+	return e, nil
+}
+
+// IsEncrypted returns whether the given value is encrypted by SOPS.
+func IsEncrypted(value string) bool {
+	return encryptedExpression.MatchString(value)
+}
+
+// plaintext returns the bytes SOPS encrypts and authenticates the given value
+// as. Booleans are written like True and False for historical reasons.
+func plaintext(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case int:
+		return []byte(strconv.Itoa(v)), nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64)), nil
+	case bool:
+		if v {
+			return []byte("True"), nil
+		}
+		return []byte("False"), nil
+	case time.Time:
+		b, err := v.MarshalText()
+		if err != nil {
+			return nil, tracer.Mask(err)
+		}
+		return b, nil
+	}
+
+	return nil, tracer.Maskf(unsupportedValueError, "values of type %T cannot be encrypted", value)
+}
@@ -0,0 +1,47 @@
+package sops
+
+import (
+	"errors"
+
+	"github.com/xh3b4sd/tracer"
+)
+
+var decryptionFailedError = &tracer.Error{
+	Kind: "decryptionFailedError",
+}
+
+func IsDecryptionFailed(err error) bool {
+	return errors.Is(err, decryptionFailedError)
+}
+
+var invalidConfigError = &tracer.Error{
+	Kind: "invalidConfigError",
+}
+
+func IsInvalidConfig(err error) bool {
+	return errors.Is(err, invalidConfigError)
+}
+
+var missingKeyError = &tracer.Error{
+	Kind: "missingKeyError",
+}
+
+func IsMissingKey(err error) bool {
+	return errors.Is(err, missingKeyError)
+}
+
+var unsupportedMetadataError = &tracer.Error{
+	Kind: "unsupportedMetadataError",
+}
+
+func IsUnsupportedMetadata(err error) bool {
+	return errors.Is(err, unsupportedMetadataError)
+}
+
+var unsupportedValueError = &tracer.Error{
+	Kind: "unsupportedValueError",
+}
+
+func IsUnsupportedValue(err error) bool {
+	return errors.Is(err, unsupportedValueError)
+}
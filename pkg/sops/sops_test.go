@@ -0,0 +1,209 @@
+package sops
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+
+	"filippo.io/age"
+	agearmor "filippo.io/age/armor"
+)
+
+func Test_Sops_DataKey(t *testing.T) {
+	i, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := bytes.Repeat([]byte{7}, 32)
+
+	testCases := []struct {
+		Recipients   []age.Recipient
+		ErrorMatcher func(error) bool
+	}{
+		// Test case 1, ensure data keys are decrypted using the age identity
+		// given in the environment.
+		{
+			Recipients: []age.Recipient{i.Recipient()},
+		},
+
+		// Test case 2, ensure data keys encrypted for more than one recipient
+		// are decrypted using the age identity available.
+		{
+			Recipients: []age.Recipient{o.Recipient(), i.Recipient()},
+		},
+
+		// Test case 3, ensure data keys which are not encrypted for the age
+		// identity available cause an error.
+		{
+			Recipients:   []age.Recipient{o.Recipient()},
+			ErrorMatcher: IsMissingKey,
+		},
+	}
+
+	os.Setenv("SOPS_AGE_KEY", i.String())
+	defer os.Unsetenv("SOPS_AGE_KEY")
+
+	for j, tc := range testCases {
+		var m Metadata
+		for _, r := range tc.Recipients {
+			var b bytes.Buffer
+			a := agearmor.NewWriter(&b)
+			w, err := age.Encrypt(a, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = w.Write(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = w.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = a.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m.Age = append(m.Age, AgeKey{Enc: b.String()})
+		}
+
+		s, err := New(Config{})
+		if err != nil {
+			t.Fatal("test", j+1, "expected", nil, "got", err)
+		}
+
+		output, err := s.DataKey(m)
+		if tc.ErrorMatcher != nil {
+			if !tc.ErrorMatcher(err) {
+				t.Fatal("test", j+1, "expected", true, "got", false)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal("test", j+1, "expected", nil, "got", err)
+		}
+		if !bytes.Equal(output, key) {
+			t.Fatal("test", j+1, "expected", key, "got", output)
+		}
+	}
+}
+
+func Test_Sops_Encrypt(t *testing.T) {
+	testCases := []struct {
+		Value interface{}
+	}{
+		// Test case 1, ensure strings are decrypted as strings.
+		{
+			Value: "hunter2",
+		},
+
+		// Test case 2, ensure integers are decrypted as integers.
+		{
+			Value: 5432,
+		},
+
+		// Test case 3, ensure floats are decrypted as floats.
+		{
+			Value: 1.5,
+		},
+
+		// Test case 4, ensure booleans are decrypted as booleans, even though
+		// they are encrypted like True and False.
+		{
+			Value: false,
+		},
+
+		// Test case 5, ensure empty strings are not encrypted.
+		{
+			Value: "",
+		},
+	}
+
+	key := bytes.Repeat([]byte{7}, 32)
+
+	for i, tc := range testCases {
+		e, err := Encrypt(tc.Value, key, AdditionalData([]string{"data", "k"}))
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if IsEncrypted(e) != (tc.Value != "") {
+			t.Fatal("test", i+1, "expected", tc.Value != "", "got", IsEncrypted(e))
+		}
+
+		output, err := Decrypt(e, key, AdditionalData([]string{"data", "k"}))
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(output, tc.Value) {
+			t.Fatal("test", i+1, "expected", tc.Value, "got", output)
+		}
+
+		if tc.Value != "" {
+			_, err = Decrypt(e, key, AdditionalData([]string{"data", "x"}))
+			if !IsDecryptionFailed(err) {
+				t.Fatal("test", i+1, "expected", true, "got", false)
+			}
+		}
+	}
+}
+
+func Test_Sops_Metadata_Encrypted(t *testing.T) {
+	testCases := []struct {
+		Metadata Metadata
+		Keys     []string
+		Expected bool
+	}{
+		// Test case 1, ensure values are encrypted by default.
+		{
+			Metadata: Metadata{},
+			Keys:     []string{"data", "password"},
+			Expected: true,
+		},
+
+		// Test case 2, ensure values having a key with the unencrypted suffix
+		// anywhere in their path are not encrypted.
+		{
+			Metadata: Metadata{UnencryptedSuffix: "_unencrypted"},
+			Keys:     []string{"config_unencrypted", "password"},
+			Expected: false,
+		},
+
+		// Test case 3, ensure only values matching the encrypted regex are
+		// encrypted.
+		{
+			Metadata: Metadata{EncryptedRegex: "^(data|stringData)$"},
+			Keys:     []string{"metadata", "name"},
+			Expected: false,
+		},
+
+		// Test case 4, ensure values matching the encrypted regex are
+		// encrypted.
+		{
+			Metadata: Metadata{EncryptedRegex: "^(data|stringData)$"},
+			Keys:     []string{"stringData", "password"},
+			Expected: true,
+		},
+
+		// Test case 5, ensure values matching the unencrypted regex are not
+		// encrypted.
+		{
+			Metadata: Metadata{UnencryptedRegex: "^public"},
+			Keys:     []string{"public", "key"},
+			Expected: false,
+		},
+	}
+
+	for i, tc := range testCases {
+		output := tc.Metadata.Encrypted(tc.Keys)
+		if output != tc.Expected {
+			t.Fatal("test", i+1, "expected", tc.Expected, "got", output)
+		}
+	}
+}
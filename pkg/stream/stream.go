@@ -0,0 +1,81 @@
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/xh3b4sd/tracer"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Join returns the YAML stream consisting of the given documents. Joining the
+// documents returned by Split results in the original stream.
+func Join(documents [][]byte) []byte {
+	return bytes.Join(documents, nil)
+}
+
+// Split returns the documents of the given YAML stream. The stream is decoded
+// document by document, so that document markers are only recognized where
+// the YAML parser recognizes them, and not e.g. within block scalars. Every
+// document starts with its own directives and start marker, if any. Comments
+// and end markers following a document belong to that document. That way
+// documents can be modified individually and joined again without losing any
+// part of the stream.
+func Split(b []byte) ([][]byte, error) {
+	var lines []int
+	{
+		d := yaml.NewDecoder(bytes.NewReader(b))
+
+		for {
+			var n yaml.Node
+			err := d.Decode(&n)
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return nil, tracer.Mask(err)
+			}
+
+			lines = append(lines, n.Line)
+		}
+	}
+
+	var offsets []int
+	{
+		offsets = append(offsets, 0)
+		for i, c := range b {
+			if c == '\n' {
+				offsets = append(offsets, i+1)
+			}
+		}
+	}
+
+	var documents [][]byte
+	{
+		var s int
+		for i, l := range lines {
+			if i == 0 {
+				continue
+			}
+
+			e := offsets[l-1]
+
+			// Directives are declared right before the start marker of the
+			// document they belong to.
+			for e > 0 {
+				o := bytes.LastIndexByte(b[:e-1], '\n') + 1
+				if b[o] != '%' {
+					break
+				}
+				e = o
+			}
+
+			documents = append(documents, b[s:e])
+			s = e
+		}
+
+		documents = append(documents, b[s:])
+	}
+
+	return documents, nil
+}
@@ -0,0 +1,141 @@
+package stream
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Stream_Split(t *testing.T) {
+	testCases := []struct {
+		InputBytes []byte
+		Expected   [][]byte
+	}{
+		// Test case 1, ensure a single document is returned as is.
+		{
+			InputBytes: []byte(`k1: v1
+`),
+			Expected: [][]byte{
+				[]byte(`k1: v1
+`),
+			},
+		},
+
+		// Test case 2, ensure all documents of a stream are returned.
+		{
+			InputBytes: []byte(`k1: v1
+---
+k2: v2
+---
+k3: v3
+`),
+			Expected: [][]byte{
+				[]byte(`k1: v1
+`),
+				[]byte(`---
+k2: v2
+`),
+				[]byte(`---
+k3: v3
+`),
+			},
+		},
+
+		// Test case 3, ensure a leading separator and leading comments belong to
+		// the first document.
+		{
+			InputBytes: []byte(`# comment
+---
+k1: v1
+`),
+			Expected: [][]byte{
+				[]byte(`# comment
+---
+k1: v1
+`),
+			},
+		},
+
+		// Test case 4, ensure separators within block scalars, comments and
+		// strings do not split documents.
+		{
+			InputBytes: []byte(`k1: |
+  ---
+  v1
+k2: "--- v2" # ---
+---
+k3: v3
+`),
+			Expected: [][]byte{
+				[]byte(`k1: |
+  ---
+  v1
+k2: "--- v2" # ---
+`),
+				[]byte(`---
+k3: v3
+`),
+			},
+		},
+
+		// Test case 5, ensure empty documents are returned.
+		{
+			InputBytes: []byte(`---
+---
+k1: v1
+---
+`),
+			Expected: [][]byte{
+				[]byte(`---
+`),
+				[]byte(`---
+k1: v1
+`),
+				[]byte(`---
+`),
+			},
+		},
+
+		// Test case 6, ensure end markers belong to the document they end and
+		// directives belong to the document they are declared for.
+		{
+			InputBytes: []byte(`k1: v1
+...
+%YAML 1.1
+--- # comment
+k2: v2
+`),
+			Expected: [][]byte{
+				[]byte(`k1: v1
+...
+`),
+				[]byte(`%YAML 1.1
+--- # comment
+k2: v2
+`),
+			},
+		},
+
+		// Test case 7, ensure streams without documents are returned as is.
+		{
+			InputBytes: []byte(`# comment
+`),
+			Expected: [][]byte{
+				[]byte(`# comment
+`),
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		output, err := Split(tc.InputBytes)
+		if err != nil {
+			t.Fatal("test", i+1, "expected", nil, "got", err)
+		}
+		if !reflect.DeepEqual(tc.Expected, output) {
+			t.Fatal("test", i+1, "expected", tc.Expected, "got", output)
+		}
+		if !reflect.DeepEqual(tc.InputBytes, Join(output)) {
+			t.Fatal("test", i+1, "expected", string(tc.InputBytes), "got", string(Join(output)))
+		}
+	}
+}
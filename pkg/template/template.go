@@ -0,0 +1,107 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	actionExpression  = regexp.MustCompile(`(?s)\{\{.*?\}\}`)
+	closeExpression   = regexp.MustCompile(`\{\{-?\s*end\b`)
+	commentExpression = regexp.MustCompile(`^[ \t]*#__dsm_template_[0-9]+__[ \t]*$`)
+	openExpression    = regexp.MustCompile(`\{\{-?\s*(?:block|define|if|range|with)\b`)
+)
+
+// Actions restores the template actions replaced by Mask.
+type Actions struct {
+	replacer *strings.Replacer
+}
+
+// Mask replaces the Go template actions of the given bytes, like the
+// {{ .Values.image }} expressions of Helm charts, with placeholders, so that
+// the bytes can be parsed as YAML. Actions within lines are replaced with plain
+// scalars, which keeps them usable as keys and values. Lines consisting of
+// actions only, like {{- if .Values.enabled }}, are replaced with comments. The
+// returned Actions write all actions back unchanged.
+func Mask(b []byte) ([]byte, Actions) {
+	spans := actionExpression.FindAllIndex(b, -1)
+
+	var m []byte
+	var l []string
+
+	var o int
+	for i := 0; i < len(spans); i++ {
+		s := spans[i][0]
+		e := spans[i][1]
+
+		// Actions following each other on a line of their own are replaced
+		// with a single comment.
+		var line bool
+		if isBlank(b[bytes.LastIndexByte(b[:s], '\n')+1 : s]) {
+			j := i
+			for j+1 < len(spans) && isBlank(b[spans[j][1]:spans[j+1][0]]) {
+				j++
+			}
+
+			r := b[spans[j][1]:]
+			if n := bytes.IndexByte(r, '\n'); n != -1 {
+				r = r[:n]
+			}
+
+			if isBlank(r) {
+				e = spans[j][1]
+				i = j
+				line = true
+			}
+		}
+
+		p := fmt.Sprintf("__dsm_template_%d__", len(l)/2)
+		if line {
+			p = "#" + p
+		}
+
+		m = append(m, b[o:s]...)
+		m = append(m, p...)
+		l = append(l, p, string(b[s:e]))
+
+		o = e
+	}
+
+	m = append(m, b[o:]...)
+
+	a := Actions{
+		replacer: strings.NewReplacer(l...),
+	}
+
+	return m, a
+}
+
+// Blocks returns the amount of control blocks, like {{ if .Values.enabled }},
+// which are opened by the given actions and not closed by them. The amount is
+// negative if the actions close more blocks than they open.
+func Blocks(actions string) int {
+	return len(openExpression.FindAllString(actions, -1)) - len(closeExpression.FindAllString(actions, -1))
+}
+
+// IsComment returns whether the given line consists of a comment written by
+// Mask in place of a line of actions only.
+func IsComment(line []byte) bool {
+	return commentExpression.Match(line)
+}
+
+// Unmask returns the given string with all placeholders written by Mask
+// replaced by the template actions they stand for.
+func (a Actions) Unmask(s string) string {
+	if a.replacer == nil {
+		return s
+	}
+
+	return a.replacer.Replace(s)
+}
+
+// isBlank returns whether the given bytes consist of spaces and tabs only.
+func isBlank(b []byte) bool {
+	return len(bytes.Trim(b, " \t")) == 0
+}
@@ -0,0 +1,144 @@
+package template
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Template_Mask(t *testing.T) {
+	testCases := []struct {
+		InputBytes []byte
+		Expected   []byte
+	}{
+		// Test case 1, ensure bytes without actions are returned as is.
+		{
+			InputBytes: []byte(`k1: v1
+`),
+			Expected: []byte(`k1: v1
+`),
+		},
+
+		// Test case 2, ensure actions within lines are replaced with plain
+		// scalars.
+		{
+			InputBytes: []byte(`k1: {{ .Values.k1 }}
+k2: "{{ .Values.a }}:{{ .Values.b }}"
+{{ .Values.k3 }}: v3
+`),
+			Expected: []byte(`k1: __dsm_template_0__
+k2: "__dsm_template_1__:__dsm_template_2__"
+__dsm_template_3__: v3
+`),
+		},
+
+		// Test case 3, ensure lines consisting of actions only are replaced
+		// with comments.
+		{
+			InputBytes: []byte(`{{- if .Values.enabled }}
+k1:
+  {{- include "labels" . | nindent 2 }} {{ "" }}
+  k2: v2
+{{- end }}`),
+			Expected: []byte(`#__dsm_template_0__
+k1:
+  #__dsm_template_1__
+  k2: v2
+#__dsm_template_2__`),
+		},
+
+		// Test case 4, ensure actions spanning multiple lines are replaced.
+		{
+			InputBytes: []byte(`{{- /*
+comment
+*/ -}}
+k1: v1
+`),
+			Expected: []byte(`#__dsm_template_0__
+k1: v1
+`),
+		},
+	}
+
+	for i, tc := range testCases {
+		output, a := Mask(tc.InputBytes)
+		if !reflect.DeepEqual(tc.Expected, output) {
+			t.Fatal("test", i+1, "expected", string(tc.Expected), "got", string(output))
+		}
+		if a.Unmask(string(output)) != string(tc.InputBytes) {
+			t.Fatal("test", i+1, "expected", string(tc.InputBytes), "got", a.Unmask(string(output)))
+		}
+	}
+}
+
+func Test_Template_Blocks(t *testing.T) {
+	testCases := []struct {
+		Actions  string
+		Expected int
+	}{
+		// Test case 1, ensure actions opening control blocks are counted.
+		{
+			Actions:  `{{- if .Values.env }}`,
+			Expected: 1,
+		},
+
+		// Test case 2, ensure actions closing control blocks are counted.
+		{
+			Actions:  `{{- end }}`,
+			Expected: -1,
+		},
+
+		// Test case 3, ensure blocks opened and closed by the same actions,
+		// and actions continuing blocks, are not counted.
+		{
+			Actions:  `{{- with .Values.labels }}{{ toYaml . }}{{ end }}{{- else }}`,
+			Expected: 0,
+		},
+
+		// Test case 4, ensure other actions are not counted.
+		{
+			Actions:  `{{- include "labels" . | nindent 4 }}`,
+			Expected: 0,
+		},
+	}
+
+	for i, tc := range testCases {
+		output := Blocks(tc.Actions)
+		if output != tc.Expected {
+			t.Fatal("test", i+1, "expected", tc.Expected, "got", output)
+		}
+	}
+}
+
+func Test_Template_IsComment(t *testing.T) {
+	testCases := []struct {
+		Line     []byte
+		Expected bool
+	}{
+		// Test case 1, ensure comments written in place of actions are
+		// detected.
+		{
+			Line:     []byte(`    #__dsm_template_3__`),
+			Expected: true,
+		},
+
+		// Test case 2, ensure placeholders written in place of actions within
+		// lines are not detected.
+		{
+			Line:     []byte(`  name: __dsm_template_3__`),
+			Expected: false,
+		},
+
+		// Test case 3, ensure other comments are not detected.
+		{
+			Line:     []byte(`  # __dsm_template_3__`),
+			Expected: false,
+		},
+	}
+
+	for i, tc := range testCases {
+		output := IsComment(tc.Line)
+		if output != tc.Expected {
+			t.Fatal("test", i+1, "expected", tc.Expected, "got", output)
+		}
+	}
+}